@@ -0,0 +1,49 @@
+package grpcapp
+
+import (
+	"context"
+	"log"
+	"net"
+
+	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/config"
+
+	"github.com/ruziba3vich/shared/events"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type (
+	GRPCApp struct {
+		service controlrpc.ControllerServiceServer
+	}
+)
+
+func New(service controlrpc.ControllerServiceServer) *GRPCApp {
+	return &GRPCApp{
+		service: service,
+	}
+}
+
+func (a *GRPCApp) RUN(cfg *config.Config, logger *log.Logger) error {
+	listener, err := net.Listen(cfg.Protocol, cfg.Port)
+	if err != nil {
+		logger.Printf("ERROR WHILE CREATING A LISTENER %s\n", err.Error())
+		return err
+	}
+	serverRegisterer := grpc.NewServer(grpc.UnaryInterceptor(actorInterceptor))
+	controlrpc.RegisterControllerServiceServer(serverRegisterer, a.service)
+	logger.Printf("--- SERVER HAS STARTED TO RUN ON PORT %s\n", cfg.Port)
+	return serverRegisterer.Serve(listener)
+}
+
+// actorInterceptor attributes the events emitted while handling a call to the
+// user the gateway forwarded in the metadata
+func actorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if actor := md.Get(events.ActorHeader); len(actor) > 0 {
+			ctx = events.WithActor(ctx, actor[0])
+		}
+	}
+	return handler(ctx, req)
+}
@@ -2,19 +2,33 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	grpcapp "ruziba3vich/github.com/control/app"
 	"ruziba3vich/github.com/control/internal/config"
+	"ruziba3vich/github.com/control/internal/driver"
+	"ruziba3vich/github.com/control/internal/migrations"
 	"ruziba3vich/github.com/control/internal/models"
 	"ruziba3vich/github.com/control/internal/msgbroker"
+	"ruziba3vich/github.com/control/internal/presence"
+	"ruziba3vich/github.com/control/internal/service"
 	"ruziba3vich/github.com/control/internal/storage"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/shared/migrate"
+	"github.com/ruziba3vich/shared/outbox"
 )
 
 func main() {
+	migrateLocations := flag.String("migrate-locations", "", "place the devices that only have a free-text location into rooms of the given house and exit")
+	migrateOnly := flag.Bool("migrate", false, "apply the pending database migrations and exit; they are also applied on startup")
+	dryRun := flag.Bool("dry-run", false, "only report the pending migrations, and with -migrate-locations what it would change, instead of applying them, and exit")
+	flag.Parse()
+
 	logger := log.New(log.Writer(), "Service: ", log.LstdFlags)
 
 	// Load configuration
@@ -29,7 +43,28 @@ func main() {
 	if err != nil {
 		logger.Fatal(err)
 	}
-	storageService := storage.NewStorage(db, logger)
+	deviceDriver, err := newDriver(cfg.DriverConfig, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	storageService := storage.NewStorage(db, deviceDriver, logger)
+
+	migrator := migrate.New(db.Client.Database(cfg.DbConfig.MongoDB).Collection(migrate.CollectionName), migrations.All(db), logger)
+	if err := runMigrations(context.Background(), migrator, logger, *dryRun); err != nil {
+		logger.Fatal(err)
+	}
+	if *migrateOnly || (*dryRun && len(*migrateLocations) == 0) {
+		return
+	}
+
+	if len(*migrateLocations) > 0 {
+		devices, rooms, err := storageService.MigrateLocations(context.Background(), *migrateLocations, *dryRun)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Placed %d devices, creating %d rooms (dry run: %t)", devices, rooms, *dryRun)
+		return
+	}
 
 	conn, err := amqp.Dial(cfg.GetRabbitMqURI())
 	if err != nil {
@@ -48,6 +83,9 @@ func main() {
 		models.TURNDEVICEOFFQUEUE,
 		models.ADDUSERQUEUE,
 		models.REMOVEUSERQUEUE,
+		models.BATTERYQUEUE,
+		models.SETDEVICESTATEQUEUE,
+		models.HEARTBEATQUEUE,
 	}
 	for _, q := range queues {
 		_, err := ch.QueueDeclare(
@@ -63,13 +101,47 @@ func main() {
 		}
 	}
 
-	msgs := make(chan amqp.Delivery)
-	msgBrokerService := msgbroker.NewService(msgs, storageService, logger)
+	relayCh, err := conn.Channel()
+	if err != nil {
+		logger.Fatalf("Failed to open a channel: %v", err)
+	}
+	defer relayCh.Close()
+
+	relay, err := outbox.NewRelay(db.OutboxCollection, relayCh, cfg.OutboxConfig.Exchange, cfg.OutboxConfig.PollInterval, cfg.OutboxConfig.BatchSize, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go relay.Run(relayCtx)
+
+	driverCtx, stopDriver := context.WithCancel(context.Background())
+	defer stopDriver()
+	if err := startDriver(driverCtx, deviceDriver, storageService, cfg.DriverConfig, logger); err != nil {
+		logger.Fatal(err)
+	}
+	go func() {
+		if err := storageService.WatchDevices(driverCtx); err != nil {
+			logger.Println(err)
+		}
+	}()
+	go presence.NewReaper(storageService, cfg.PresenceConfig.Timeout, cfg.PresenceConfig.SweepInterval, logger).Run(driverCtx)
+
+	msgBrokerService := msgbroker.NewService(storageService, ch, logger)
 
 	go FunctionToRunConsumer(ch, models.TURNDEVICEONQUEUE, logger, msgBrokerService, msgBrokerService.HandleTurnDeviceOn)
 	go FunctionToRunConsumer(ch, models.TURNDEVICEOFFQUEUE, logger, msgBrokerService, msgBrokerService.HandleTurnDeviceOff)
 	go FunctionToRunConsumer(ch, models.ADDUSERQUEUE, logger, msgBrokerService, msgBrokerService.HandleAddUserToHouse)
 	go FunctionToRunConsumer(ch, models.REMOVEUSERQUEUE, logger, msgBrokerService, msgBrokerService.HandleRemoveUserFromHouse)
+	go FunctionToRunConsumer(ch, models.BATTERYQUEUE, logger, msgBrokerService, msgBrokerService.HandleBatteryReport)
+	go FunctionToRunConsumer(ch, models.SETDEVICESTATEQUEUE, logger, msgBrokerService, msgBrokerService.HandleSetDeviceState)
+	go FunctionToRunConsumer(ch, models.HEARTBEATQUEUE, logger, msgBrokerService, msgBrokerService.HandleHeartbeat)
+
+	grpcserver := grpcapp.New(service.New(storageService, logger))
+
+	go func() {
+		logger.Fatal(grpcserver.RUN(cfg, logger))
+	}()
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt)
@@ -94,5 +166,70 @@ func FunctionToRunConsumer(ch *amqp.Channel, queueName models.TYPE, logger *log.
 	if err != nil {
 		logger.Fatalf("Failed to register a consumer: %v", err)
 	}
-	go msgBrokerService.ConsumeMessages(ch, string(queueName), logger, handler)
+	go msgBrokerService.ConsumeMessages(context.Background(), msgs, handler)
+}
+
+// newDriver builds the driver the controller talks to devices through
+func newDriver(cfg config.DriverConfig, logger *log.Logger) (driver.DeviceDriver, error) {
+	switch cfg.Name {
+	case "none":
+		return driver.Noop{}, nil
+	case "simulator":
+		return driver.NewSimulator(cfg.Simulator, logger), nil
+	case "mqtt":
+		return driver.NewMQTT(cfg.MQTT, logger), nil
+	}
+	return nil, fmt.Errorf("unknown device driver %q, expected none, simulator or mqtt", cfg.Name)
+}
+
+// startDriver gets the driver going until ctx is done: the simulator learns
+// the stored devices and starts moving them, the MQTT bridge connects,
+// starting the embedded broker first when one is configured
+func startDriver(ctx context.Context, deviceDriver driver.DeviceDriver, storageService *storage.Storage, cfg config.DriverConfig, logger *log.Logger) error {
+	switch d := deviceDriver.(type) {
+	case *driver.Simulator:
+		devices, err := storageService.DeviceStates(ctx)
+		if err != nil {
+			return err
+		}
+		d.Add(devices...)
+		go d.Run(ctx)
+	case *driver.MQTT:
+		if len(cfg.MQTT.EmbeddedBroker) > 0 {
+			broker, err := driver.StartBroker(cfg.MQTT.EmbeddedBroker)
+			if err != nil {
+				return err
+			}
+			logger.Printf("Embedded MQTT broker listening on %s", cfg.MQTT.EmbeddedBroker)
+			go func() {
+				<-ctx.Done()
+				broker.Close()
+			}()
+		}
+		if err := d.Connect(); err != nil {
+			return err
+		}
+		go func() {
+			<-ctx.Done()
+			d.Close()
+		}()
+	}
+	return nil
+}
+
+// runMigrations applies the pending migrations, or with dryRun lists them
+func runMigrations(ctx context.Context, migrator *migrate.Migrator, logger *log.Logger, dryRun bool) error {
+	applied, err := migrator.Run(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		for _, migration := range applied {
+			logger.Printf("Pending migration %d: %s\n", migration.Version, migration.Description)
+		}
+		logger.Printf("%d pending migrations\n", len(applied))
+	} else if len(applied) > 0 {
+		logger.Printf("Applied %d migrations\n", len(applied))
+	}
+	return nil
 }
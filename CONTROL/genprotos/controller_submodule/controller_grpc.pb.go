@@ -24,6 +24,22 @@ const (
 	ControllerService_AddUserToHouse_FullMethodName      = "/controller.ControllerService/AddUserToHouse"
 	ControllerService_RemoveUserFromHouse_FullMethodName = "/controller.ControllerService/RemoveUserFromHouse"
 	ControllerService_GetBatteryStatus_FullMethodName    = "/controller.ControllerService/GetBatteryStatus"
+	ControllerService_CreateHouse_FullMethodName         = "/controller.ControllerService/CreateHouse"
+	ControllerService_GetHouse_FullMethodName            = "/controller.ControllerService/GetHouse"
+	ControllerService_ListHouses_FullMethodName          = "/controller.ControllerService/ListHouses"
+	ControllerService_UpdateHouse_FullMethodName         = "/controller.ControllerService/UpdateHouse"
+	ControllerService_DeleteHouse_FullMethodName         = "/controller.ControllerService/DeleteHouse"
+	ControllerService_GetHouseMembers_FullMethodName     = "/controller.ControllerService/GetHouseMembers"
+	ControllerService_GetUserHouses_FullMethodName       = "/controller.ControllerService/GetUserHouses"
+	ControllerService_CreateRoom_FullMethodName          = "/controller.ControllerService/CreateRoom"
+	ControllerService_GetRoom_FullMethodName             = "/controller.ControllerService/GetRoom"
+	ControllerService_ListRooms_FullMethodName           = "/controller.ControllerService/ListRooms"
+	ControllerService_UpdateRoom_FullMethodName          = "/controller.ControllerService/UpdateRoom"
+	ControllerService_DeleteRoom_FullMethodName          = "/controller.ControllerService/DeleteRoom"
+	ControllerService_TurnRoomOff_FullMethodName         = "/controller.ControllerService/TurnRoomOff"
+	ControllerService_SetMemberRole_FullMethodName       = "/controller.ControllerService/SetMemberRole"
+	ControllerService_GetMemberRole_FullMethodName       = "/controller.ControllerService/GetMemberRole"
+	ControllerService_SetDeviceState_FullMethodName      = "/controller.ControllerService/SetDeviceState"
 )
 
 // ControllerServiceClient is the client API for ControllerService service.
@@ -35,6 +51,22 @@ type ControllerServiceClient interface {
 	AddUserToHouse(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*HouseResponse, error)
 	RemoveUserFromHouse(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*HouseResponse, error)
 	GetBatteryStatus(ctx context.Context, in *DeviceRequest, opts ...grpc.CallOption) (*BatteryResponse, error)
+	CreateHouse(ctx context.Context, in *CreateHouseRequest, opts ...grpc.CallOption) (*House, error)
+	GetHouse(ctx context.Context, in *HouseIdRequest, opts ...grpc.CallOption) (*House, error)
+	ListHouses(ctx context.Context, in *ListHousesRequest, opts ...grpc.CallOption) (*ListHousesResponse, error)
+	UpdateHouse(ctx context.Context, in *UpdateHouseRequest, opts ...grpc.CallOption) (*House, error)
+	DeleteHouse(ctx context.Context, in *HouseIdRequest, opts ...grpc.CallOption) (*HouseResponse, error)
+	GetHouseMembers(ctx context.Context, in *HouseIdRequest, opts ...grpc.CallOption) (*HouseMembersResponse, error)
+	GetUserHouses(ctx context.Context, in *UserHousesRequest, opts ...grpc.CallOption) (*ListHousesResponse, error)
+	CreateRoom(ctx context.Context, in *CreateRoomRequest, opts ...grpc.CallOption) (*Room, error)
+	GetRoom(ctx context.Context, in *RoomIdRequest, opts ...grpc.CallOption) (*Room, error)
+	ListRooms(ctx context.Context, in *ListRoomsRequest, opts ...grpc.CallOption) (*ListRoomsResponse, error)
+	UpdateRoom(ctx context.Context, in *UpdateRoomRequest, opts ...grpc.CallOption) (*Room, error)
+	DeleteRoom(ctx context.Context, in *RoomIdRequest, opts ...grpc.CallOption) (*HouseResponse, error)
+	TurnRoomOff(ctx context.Context, in *RoomIdRequest, opts ...grpc.CallOption) (*DeviceResponse, error)
+	SetMemberRole(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*HouseResponse, error)
+	GetMemberRole(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*MemberRoleResponse, error)
+	SetDeviceState(ctx context.Context, in *SetDeviceStateRequest, opts ...grpc.CallOption) (*DeviceState, error)
 }
 
 type controllerServiceClient struct {
@@ -95,6 +127,166 @@ func (c *controllerServiceClient) GetBatteryStatus(ctx context.Context, in *Devi
 	return out, nil
 }
 
+func (c *controllerServiceClient) CreateHouse(ctx context.Context, in *CreateHouseRequest, opts ...grpc.CallOption) (*House, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(House)
+	err := c.cc.Invoke(ctx, ControllerService_CreateHouse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetHouse(ctx context.Context, in *HouseIdRequest, opts ...grpc.CallOption) (*House, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(House)
+	err := c.cc.Invoke(ctx, ControllerService_GetHouse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) ListHouses(ctx context.Context, in *ListHousesRequest, opts ...grpc.CallOption) (*ListHousesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListHousesResponse)
+	err := c.cc.Invoke(ctx, ControllerService_ListHouses_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) UpdateHouse(ctx context.Context, in *UpdateHouseRequest, opts ...grpc.CallOption) (*House, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(House)
+	err := c.cc.Invoke(ctx, ControllerService_UpdateHouse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) DeleteHouse(ctx context.Context, in *HouseIdRequest, opts ...grpc.CallOption) (*HouseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HouseResponse)
+	err := c.cc.Invoke(ctx, ControllerService_DeleteHouse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetHouseMembers(ctx context.Context, in *HouseIdRequest, opts ...grpc.CallOption) (*HouseMembersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HouseMembersResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetHouseMembers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetUserHouses(ctx context.Context, in *UserHousesRequest, opts ...grpc.CallOption) (*ListHousesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListHousesResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetUserHouses_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) CreateRoom(ctx context.Context, in *CreateRoomRequest, opts ...grpc.CallOption) (*Room, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Room)
+	err := c.cc.Invoke(ctx, ControllerService_CreateRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetRoom(ctx context.Context, in *RoomIdRequest, opts ...grpc.CallOption) (*Room, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Room)
+	err := c.cc.Invoke(ctx, ControllerService_GetRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) ListRooms(ctx context.Context, in *ListRoomsRequest, opts ...grpc.CallOption) (*ListRoomsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRoomsResponse)
+	err := c.cc.Invoke(ctx, ControllerService_ListRooms_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) UpdateRoom(ctx context.Context, in *UpdateRoomRequest, opts ...grpc.CallOption) (*Room, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Room)
+	err := c.cc.Invoke(ctx, ControllerService_UpdateRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) DeleteRoom(ctx context.Context, in *RoomIdRequest, opts ...grpc.CallOption) (*HouseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HouseResponse)
+	err := c.cc.Invoke(ctx, ControllerService_DeleteRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) TurnRoomOff(ctx context.Context, in *RoomIdRequest, opts ...grpc.CallOption) (*DeviceResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeviceResponse)
+	err := c.cc.Invoke(ctx, ControllerService_TurnRoomOff_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) SetMemberRole(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*HouseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HouseResponse)
+	err := c.cc.Invoke(ctx, ControllerService_SetMemberRole_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) GetMemberRole(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*MemberRoleResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MemberRoleResponse)
+	err := c.cc.Invoke(ctx, ControllerService_GetMemberRole_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controllerServiceClient) SetDeviceState(ctx context.Context, in *SetDeviceStateRequest, opts ...grpc.CallOption) (*DeviceState, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeviceState)
+	err := c.cc.Invoke(ctx, ControllerService_SetDeviceState_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ControllerServiceServer is the server API for ControllerService service.
 // All implementations must embed UnimplementedControllerServiceServer
 // for forward compatibility
@@ -104,6 +296,22 @@ type ControllerServiceServer interface {
 	AddUserToHouse(context.Context, *UserRequest) (*HouseResponse, error)
 	RemoveUserFromHouse(context.Context, *UserRequest) (*HouseResponse, error)
 	GetBatteryStatus(context.Context, *DeviceRequest) (*BatteryResponse, error)
+	CreateHouse(context.Context, *CreateHouseRequest) (*House, error)
+	GetHouse(context.Context, *HouseIdRequest) (*House, error)
+	ListHouses(context.Context, *ListHousesRequest) (*ListHousesResponse, error)
+	UpdateHouse(context.Context, *UpdateHouseRequest) (*House, error)
+	DeleteHouse(context.Context, *HouseIdRequest) (*HouseResponse, error)
+	GetHouseMembers(context.Context, *HouseIdRequest) (*HouseMembersResponse, error)
+	GetUserHouses(context.Context, *UserHousesRequest) (*ListHousesResponse, error)
+	CreateRoom(context.Context, *CreateRoomRequest) (*Room, error)
+	GetRoom(context.Context, *RoomIdRequest) (*Room, error)
+	ListRooms(context.Context, *ListRoomsRequest) (*ListRoomsResponse, error)
+	UpdateRoom(context.Context, *UpdateRoomRequest) (*Room, error)
+	DeleteRoom(context.Context, *RoomIdRequest) (*HouseResponse, error)
+	TurnRoomOff(context.Context, *RoomIdRequest) (*DeviceResponse, error)
+	SetMemberRole(context.Context, *UserRequest) (*HouseResponse, error)
+	GetMemberRole(context.Context, *UserRequest) (*MemberRoleResponse, error)
+	SetDeviceState(context.Context, *SetDeviceStateRequest) (*DeviceState, error)
 	mustEmbedUnimplementedControllerServiceServer()
 }
 
@@ -126,6 +334,54 @@ func (UnimplementedControllerServiceServer) RemoveUserFromHouse(context.Context,
 func (UnimplementedControllerServiceServer) GetBatteryStatus(context.Context, *DeviceRequest) (*BatteryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetBatteryStatus not implemented")
 }
+func (UnimplementedControllerServiceServer) CreateHouse(context.Context, *CreateHouseRequest) (*House, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateHouse not implemented")
+}
+func (UnimplementedControllerServiceServer) GetHouse(context.Context, *HouseIdRequest) (*House, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHouse not implemented")
+}
+func (UnimplementedControllerServiceServer) ListHouses(context.Context, *ListHousesRequest) (*ListHousesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListHouses not implemented")
+}
+func (UnimplementedControllerServiceServer) UpdateHouse(context.Context, *UpdateHouseRequest) (*House, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateHouse not implemented")
+}
+func (UnimplementedControllerServiceServer) DeleteHouse(context.Context, *HouseIdRequest) (*HouseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteHouse not implemented")
+}
+func (UnimplementedControllerServiceServer) GetHouseMembers(context.Context, *HouseIdRequest) (*HouseMembersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetHouseMembers not implemented")
+}
+func (UnimplementedControllerServiceServer) GetUserHouses(context.Context, *UserHousesRequest) (*ListHousesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserHouses not implemented")
+}
+func (UnimplementedControllerServiceServer) CreateRoom(context.Context, *CreateRoomRequest) (*Room, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateRoom not implemented")
+}
+func (UnimplementedControllerServiceServer) GetRoom(context.Context, *RoomIdRequest) (*Room, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoom not implemented")
+}
+func (UnimplementedControllerServiceServer) ListRooms(context.Context, *ListRoomsRequest) (*ListRoomsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRooms not implemented")
+}
+func (UnimplementedControllerServiceServer) UpdateRoom(context.Context, *UpdateRoomRequest) (*Room, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateRoom not implemented")
+}
+func (UnimplementedControllerServiceServer) DeleteRoom(context.Context, *RoomIdRequest) (*HouseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteRoom not implemented")
+}
+func (UnimplementedControllerServiceServer) TurnRoomOff(context.Context, *RoomIdRequest) (*DeviceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TurnRoomOff not implemented")
+}
+func (UnimplementedControllerServiceServer) SetMemberRole(context.Context, *UserRequest) (*HouseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMemberRole not implemented")
+}
+func (UnimplementedControllerServiceServer) GetMemberRole(context.Context, *UserRequest) (*MemberRoleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMemberRole not implemented")
+}
+func (UnimplementedControllerServiceServer) SetDeviceState(context.Context, *SetDeviceStateRequest) (*DeviceState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetDeviceState not implemented")
+}
 func (UnimplementedControllerServiceServer) mustEmbedUnimplementedControllerServiceServer() {}
 
 // UnsafeControllerServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -229,6 +485,294 @@ func _ControllerService_GetBatteryStatus_Handler(srv interface{}, ctx context.Co
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ControllerService_CreateHouse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateHouseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).CreateHouse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_CreateHouse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).CreateHouse(ctx, req.(*CreateHouseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetHouse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HouseIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetHouse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetHouse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetHouse(ctx, req.(*HouseIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_ListHouses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListHousesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).ListHouses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_ListHouses_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).ListHouses(ctx, req.(*ListHousesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_UpdateHouse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateHouseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).UpdateHouse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_UpdateHouse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).UpdateHouse(ctx, req.(*UpdateHouseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_DeleteHouse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HouseIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).DeleteHouse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_DeleteHouse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).DeleteHouse(ctx, req.(*HouseIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetHouseMembers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HouseIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetHouseMembers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetHouseMembers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetHouseMembers(ctx, req.(*HouseIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetUserHouses_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserHousesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetUserHouses(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetUserHouses_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetUserHouses(ctx, req.(*UserHousesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_CreateRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).CreateRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_CreateRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).CreateRoom(ctx, req.(*CreateRoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetRoom(ctx, req.(*RoomIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_ListRooms_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRoomsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).ListRooms(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_ListRooms_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).ListRooms(ctx, req.(*ListRoomsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_UpdateRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).UpdateRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_UpdateRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).UpdateRoom(ctx, req.(*UpdateRoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_DeleteRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).DeleteRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_DeleteRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).DeleteRoom(ctx, req.(*RoomIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_TurnRoomOff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoomIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).TurnRoomOff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_TurnRoomOff_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).TurnRoomOff(ctx, req.(*RoomIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_SetMemberRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).SetMemberRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_SetMemberRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).SetMemberRole(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_GetMemberRole_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).GetMemberRole(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_GetMemberRole_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).GetMemberRole(ctx, req.(*UserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControllerService_SetDeviceState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetDeviceStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControllerServiceServer).SetDeviceState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControllerService_SetDeviceState_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControllerServiceServer).SetDeviceState(ctx, req.(*SetDeviceStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ControllerService_ServiceDesc is the grpc.ServiceDesc for ControllerService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -256,6 +800,70 @@ var ControllerService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetBatteryStatus",
 			Handler:    _ControllerService_GetBatteryStatus_Handler,
 		},
+		{
+			MethodName: "CreateHouse",
+			Handler:    _ControllerService_CreateHouse_Handler,
+		},
+		{
+			MethodName: "GetHouse",
+			Handler:    _ControllerService_GetHouse_Handler,
+		},
+		{
+			MethodName: "ListHouses",
+			Handler:    _ControllerService_ListHouses_Handler,
+		},
+		{
+			MethodName: "UpdateHouse",
+			Handler:    _ControllerService_UpdateHouse_Handler,
+		},
+		{
+			MethodName: "DeleteHouse",
+			Handler:    _ControllerService_DeleteHouse_Handler,
+		},
+		{
+			MethodName: "GetHouseMembers",
+			Handler:    _ControllerService_GetHouseMembers_Handler,
+		},
+		{
+			MethodName: "GetUserHouses",
+			Handler:    _ControllerService_GetUserHouses_Handler,
+		},
+		{
+			MethodName: "CreateRoom",
+			Handler:    _ControllerService_CreateRoom_Handler,
+		},
+		{
+			MethodName: "GetRoom",
+			Handler:    _ControllerService_GetRoom_Handler,
+		},
+		{
+			MethodName: "ListRooms",
+			Handler:    _ControllerService_ListRooms_Handler,
+		},
+		{
+			MethodName: "UpdateRoom",
+			Handler:    _ControllerService_UpdateRoom_Handler,
+		},
+		{
+			MethodName: "DeleteRoom",
+			Handler:    _ControllerService_DeleteRoom_Handler,
+		},
+		{
+			MethodName: "TurnRoomOff",
+			Handler:    _ControllerService_TurnRoomOff_Handler,
+		},
+		{
+			MethodName: "SetMemberRole",
+			Handler:    _ControllerService_SetMemberRole_Handler,
+		},
+		{
+			MethodName: "GetMemberRole",
+			Handler:    _ControllerService_GetMemberRole_Handler,
+		},
+		{
+			MethodName: "SetDeviceState",
+			Handler:    _ControllerService_SetDeviceState_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "controller_submodule/controller.proto",
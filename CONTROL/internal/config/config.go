@@ -3,25 +3,80 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// DbConfig holds the database configuration
+// DbConfig holds the database configuration. UsersDB is the database of the
+// users service, whose users are added to houses
 type DbConfig struct {
 	MongoURI   string
 	MongoDB    string
 	Collection string
+	UsersDB    string
+}
+
+// OutboxConfig holds the settings of the outbox relay
+type OutboxConfig struct {
+	Exchange     string
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// DriverConfig selects the driver the controller talks to devices through:
+// none, which takes every command without talking to any device, simulator
+// or mqtt
+type DriverConfig struct {
+	Name      string
+	Simulator SimulatorConfig
+	MQTT      MQTTConfig
+}
+
+// MQTTConfig holds the settings of the MQTT bridge. AckTimeout is how long a
+// device has to report its state after a command before it counts as
+// unreachable; EmbeddedBroker, when set, is the address of a broker started
+// in-process for local runs and tests.
+type MQTTConfig struct {
+	Broker         string
+	ClientId       string
+	Username       string
+	Password       string
+	TopicPrefix    string
+	AckTimeout     time.Duration
+	EmbeddedBroker string
+}
+
+// SimulatorConfig tunes the simulated devices: how long they take to answer,
+// the share of commands they fail, and the battery percent they lose on
+// every report, twice that while on
+type SimulatorConfig struct {
+	Latency        time.Duration
+	Jitter         time.Duration
+	FailureRate    float64
+	BatteryDrain   float64
+	ReportInterval time.Duration
+}
+
+// PresenceConfig holds how long a device may stay silent before it is marked
+// offline, and how often the silent devices are looked for
+type PresenceConfig struct {
+	Timeout       time.Duration
+	SweepInterval time.Duration
 }
 
 // Config holds the application configuration
 type Config struct {
-	DbConfig    DbConfig
-	Port        string
-	Protocol    string
-	secretKey   string
-	redisUri    string
-	rabbitMqUri string
+	DbConfig       DbConfig
+	OutboxConfig   OutboxConfig
+	DriverConfig   DriverConfig
+	PresenceConfig PresenceConfig
+	Port           string
+	Protocol       string
+	secretKey      string
+	redisUri       string
+	rabbitMqUri    string
 }
 
 // LoadConfig reads configuration from environment variables or .env file
@@ -35,6 +90,35 @@ func LoadConfig() (*Config, error) {
 			MongoURI:   getEnv("MONGO_URI", "mongodb://localhost:27017"),
 			MongoDB:    getEnv("MONGO_DB", "control_db"),
 			Collection: getEnv("MONGO_COLLECTION", "control"),
+			UsersDB:    getEnv("USERS_DB", "users_db"),
+		},
+		OutboxConfig: OutboxConfig{
+			Exchange:     getEnv("OUTBOX_EXCHANGE", "smart_house.events"),
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		},
+		DriverConfig: DriverConfig{
+			Name: getEnv("DEVICE_DRIVER", "none"),
+			Simulator: SimulatorConfig{
+				Latency:        getEnvDuration("SIMULATOR_LATENCY", 150*time.Millisecond),
+				Jitter:         getEnvDuration("SIMULATOR_JITTER", 100*time.Millisecond),
+				FailureRate:    getEnvFloat("SIMULATOR_FAILURE_RATE", 0.02),
+				BatteryDrain:   getEnvFloat("SIMULATOR_BATTERY_DRAIN", 0.05),
+				ReportInterval: getEnvDuration("SIMULATOR_REPORT_INTERVAL", 30*time.Second),
+			},
+			MQTT: MQTTConfig{
+				Broker:         getEnv("MQTT_BROKER", "tcp://localhost:1883"),
+				ClientId:       getEnv("MQTT_CLIENT_ID", "smart-house-control"),
+				Username:       getEnv("MQTT_USERNAME", ""),
+				Password:       getEnv("MQTT_PASSWORD", ""),
+				TopicPrefix:    getEnv("MQTT_TOPIC_PREFIX", "home"),
+				AckTimeout:     getEnvDuration("MQTT_ACK_TIMEOUT", 5*time.Second),
+				EmbeddedBroker: getEnv("MQTT_EMBEDDED_BROKER", ""),
+			},
+		},
+		PresenceConfig: PresenceConfig{
+			Timeout:       getEnvDuration("PRESENCE_TIMEOUT", 5*time.Minute),
+			SweepInterval: getEnvDuration("PRESENCE_SWEEP_INTERVAL", 30*time.Second),
 		},
 		Port:        getEnv("PORT", "8080"),
 		Protocol:    getEnv("PROTOCOL", "tcp"),
@@ -51,6 +135,39 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// Helper function to get an integer environment variable with a fallback value
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %d\n", key, fallback)
+	}
+	return fallback
+}
+
+// Helper function to get a float environment variable with a fallback value
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %g\n", key, fallback)
+	}
+	return fallback
+}
+
+// Helper function to get a duration environment variable with a fallback value
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %s\n", key, fallback)
+	}
+	return fallback
+}
+
 func (c *Config) GetSecretKey() string {
 	return c.secretKey
 }
@@ -0,0 +1,26 @@
+package driver
+
+import (
+	"fmt"
+	"log/slog"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// StartBroker runs an MQTT broker in-process on address, letting any client
+// in. It is meant for local runs and tests of the bridge, not production.
+func StartBroker(address string) (*mochi.Server, error) {
+	server := mochi.New(&mochi.Options{Logger: slog.Default()})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		return nil, fmt.Errorf("failed to set up the embedded broker: %s", err.Error())
+	}
+	if err := server.AddListener(listeners.NewTCP(listeners.Config{ID: "tcp", Address: address})); err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %s", address, err.Error())
+	}
+	if err := server.Serve(); err != nil {
+		return nil, fmt.Errorf("failed to start the embedded broker: %s", err.Error())
+	}
+	return server, nil
+}
@@ -0,0 +1,59 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"ruziba3vich/github.com/control/internal/models"
+)
+
+var (
+	// ErrUnreachable is returned when a device can't be talked to at all
+	ErrUnreachable = errors.New("device is unreachable")
+	// ErrRejected is returned when a device got a command but failed to carry it out
+	ErrRejected = errors.New("device rejected the command")
+)
+
+type (
+	// DeviceDriver is how the controller talks to devices. Every command that
+	// changes a device goes through Apply before it is stored, so what is in
+	// the database is what the device took.
+	DeviceDriver interface {
+		// Apply sends a command to a device and returns once the device has
+		// taken it
+		Apply(ctx context.Context, command Command) error
+		// Subscribe returns the states devices report on their own, from
+		// readings to battery levels, until ctx is done
+		Subscribe(ctx context.Context) (<-chan State, error)
+	}
+
+	// Device identifies a device to a driver
+	Device struct {
+		Id      string
+		HouseId string
+		Type    string
+	}
+
+	// Command is a change of state sent to a device. An empty Status and nil
+	// Attributes leave the device as it is
+	Command struct {
+		Device
+		Status     string
+		Attributes *models.DeviceAttributes
+	}
+
+	// State is what a device reports about itself. Battery and Readings are
+	// only set by the devices that have them, Online by the drivers that
+	// learn when a device comes and goes. Drivers don't vouch for the house
+	// of a reported state; the controller goes by the stored device.
+	State struct {
+		Device
+		Status     string
+		Attributes *models.DeviceAttributes
+		Battery    *int32
+		Readings   map[string]float64
+		Online     *bool
+		ReportedAt time.Time
+	}
+)
@@ -0,0 +1,273 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"ruziba3vich/github.com/control/internal/config"
+	"ruziba3vich/github.com/control/internal/models"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Topics a device uses under {prefix}/{house}/{device}/. The controller
+// publishes to set; the device publishes its state on state, its
+// battery and readings on telemetry, and online or offline on availability,
+// the latter also as its last will. A device with nothing to report
+// publishes on heartbeat to tell it is still there; anything it publishes
+// counts as much.
+const (
+	TopicSet          = "set"
+	TopicState        = "state"
+	TopicTelemetry    = "telemetry"
+	TopicAvailability = "availability"
+	TopicHeartbeat    = "heartbeat"
+)
+
+// Payloads of the availability topics
+const (
+	Online  = "online"
+	Offline = "offline"
+)
+
+// unplacedHouse stands in the topics for the house of a device that was
+// never placed in one
+const unplacedHouse = "none"
+
+const connectTimeout = 10 * time.Second
+
+type (
+	// MQTT is a driver that bridges the controller to devices speaking MQTT.
+	// Commands are published to a device's set topic, and a command only
+	// counts as taken once the device reported its state back. What the
+	// devices publish is handed to the subscribers.
+	MQTT struct {
+		client mqtt.Client
+		cfg    config.MQTTConfig
+		logger *log.Logger
+
+		mu          sync.Mutex
+		offline     map[string]bool
+		waiters     map[string][]chan State
+		subscribers map[chan State]struct{}
+	}
+
+	// Payload is the body of the set, state and telemetry messages
+	Payload struct {
+		Status string `json:"status,omitempty"`
+		*models.DeviceAttributes
+		Battery  *int32             `json:"battery,omitempty"`
+		Readings map[string]float64 `json:"readings,omitempty"`
+	}
+)
+
+// NewMQTT builds a bridge to the broker; Connect connects it. The bridge
+// announces itself on {prefix}/control/availability, with offline as its
+// last will.
+func NewMQTT(cfg config.MQTTConfig, logger *log.Logger) *MQTT {
+	bridge := MQTT{
+		cfg:         cfg,
+		logger:      logger,
+		offline:     map[string]bool{},
+		waiters:     map[string][]chan State{},
+		subscribers: map[chan State]struct{}{},
+	}
+	availability := cfg.TopicPrefix + "/control/" + TopicAvailability
+
+	opts := mqtt.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientId).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetAutoReconnect(true).
+		SetWill(availability, Offline, 1, true).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.Printf("Lost the MQTT broker: %v", err)
+		}).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			// subscriptions don't outlive a clean session, so they are made
+			// again on every connect
+			filter := cfg.TopicPrefix + "/+/+/+"
+			if token := client.Subscribe(filter, 1, bridge.handle); token.WaitTimeout(connectTimeout) && token.Error() != nil {
+				logger.Printf("Failed to subscribe to %s: %v", filter, token.Error())
+			}
+			client.Publish(availability, 1, true, Online)
+		})
+	bridge.client = mqtt.NewClient(opts)
+	return &bridge
+}
+
+// Connect connects the bridge to the broker
+func (b *MQTT) Connect() error {
+	token := b.client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		return fmt.Errorf("timed out connecting to the MQTT broker at %s", b.cfg.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to connect to the MQTT broker: %s", err.Error())
+	}
+	b.logger.Printf("Bridging devices over MQTT at %s", b.cfg.Broker)
+	return nil
+}
+
+// Close marks the bridge offline and disconnects it
+func (b *MQTT) Close() {
+	b.client.Publish(b.cfg.TopicPrefix+"/control/"+TopicAvailability, 1, true, Offline).WaitTimeout(time.Second)
+	b.client.Disconnect(250)
+}
+
+// Topic returns the topic of a device for the given kind of message
+func (b *MQTT) Topic(device Device, kind string) string {
+	house := device.HouseId
+	if len(house) == 0 {
+		house = unplacedHouse
+	}
+	return strings.Join([]string{b.cfg.TopicPrefix, house, device.Id, kind}, "/")
+}
+
+func (b *MQTT) Apply(ctx context.Context, command Command) error {
+	b.mu.Lock()
+	offline := b.offline[command.Id]
+	b.mu.Unlock()
+	if offline {
+		return fmt.Errorf("%w: %s is offline", ErrUnreachable, command.Id)
+	}
+
+	body, err := json.Marshal(&Payload{Status: command.Status, DeviceAttributes: command.Attributes})
+	if err != nil {
+		return err
+	}
+	state, err := b.request(ctx, command.Device, TopicSet, body)
+	if err != nil {
+		return err
+	}
+	if len(command.Status) > 0 && len(state.Status) > 0 && state.Status != command.Status {
+		return fmt.Errorf("%w: %s stayed %s", ErrRejected, command.Id, state.Status)
+	}
+	return nil
+}
+
+func (b *MQTT) Subscribe(ctx context.Context) (<-chan State, error) {
+	states := make(chan State, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[states] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers, states)
+		close(states)
+		b.mu.Unlock()
+	}()
+	return states, nil
+}
+
+// request publishes to a topic of a device and waits for the state the
+// device reports back. With no ack timeout it doesn't wait.
+func (b *MQTT) request(ctx context.Context, device Device, kind string, body []byte) (*State, error) {
+	answer := make(chan State, 1)
+	b.mu.Lock()
+	b.waiters[device.Id] = append(b.waiters[device.Id], answer)
+	b.mu.Unlock()
+	defer b.forget(device.Id, answer)
+
+	token := b.client.Publish(b.Topic(device, kind), 1, false, body)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnreachable, err.Error())
+		}
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %s", ErrUnreachable, ctx.Err())
+	}
+	if b.cfg.AckTimeout <= 0 {
+		return &State{Device: device}, nil
+	}
+
+	timer := time.NewTimer(b.cfg.AckTimeout)
+	defer timer.Stop()
+	select {
+	case state := <-answer:
+		return &state, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: no state from %s within %s", ErrUnreachable, device.Id, b.cfg.AckTimeout)
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %s", ErrUnreachable, ctx.Err())
+	}
+}
+
+func (b *MQTT) forget(deviceId string, answer chan State) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	waiters := b.waiters[deviceId]
+	for i := range waiters {
+		if waiters[i] == answer {
+			b.waiters[deviceId] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(b.waiters[deviceId]) == 0 {
+		delete(b.waiters, deviceId)
+	}
+}
+
+// handle takes in what the devices publish
+func (b *MQTT) handle(_ mqtt.Client, msg mqtt.Message) {
+	parts := strings.Split(strings.TrimPrefix(msg.Topic(), b.cfg.TopicPrefix+"/"), "/")
+	if len(parts) != 3 || parts[1] == "" {
+		return
+	}
+	// the house of a topic only addresses the device; any device may
+	// publish under any house, so the controller goes by the stored one
+	device := Device{Id: parts[1]}
+	state := State{Device: device, ReportedAt: time.Now()}
+
+	switch parts[2] {
+	case TopicState, TopicTelemetry:
+		var payload Payload
+		if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
+			b.logger.Printf("Ignoring a malformed message on %s: %v", msg.Topic(), err)
+			return
+		}
+		state.Status = payload.Status
+		state.Attributes = payload.DeviceAttributes
+		state.Battery = payload.Battery
+		state.Readings = payload.Readings
+	case TopicAvailability:
+		online := string(msg.Payload()) == Online
+		state.Online = &online
+	case TopicHeartbeat:
+	default:
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if state.Online != nil {
+		b.offline[device.Id] = !*state.Online
+	} else {
+		// a device that publishes is there, whatever its will said before
+		delete(b.offline, device.Id)
+	}
+	if parts[2] == TopicState {
+		for _, answer := range b.waiters[device.Id] {
+			select {
+			case answer <- state:
+			default:
+			}
+		}
+	}
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- state:
+		default:
+			b.logger.Printf("Dropped a state of device %s for a slow subscriber", device.Id)
+		}
+	}
+}
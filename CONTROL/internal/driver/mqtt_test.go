@@ -0,0 +1,174 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"ruziba3vich/github.com/control/internal/config"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	mochi "github.com/mochi-mqtt/server/v2"
+)
+
+const testPrefix = "test"
+
+// startTestBroker runs the embedded broker on a free loopback port and
+// returns its address for the clients
+func startTestBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server, err := StartBroker(address)
+	if err != nil {
+		t.Fatalf("failed to start the broker: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, "tcp://" + address
+}
+
+// startTestBridge connects a bridge to the broker and subscribes to it
+func startTestBridge(t *testing.T, broker string) (*MQTT, <-chan State) {
+	t.Helper()
+	bridge := NewMQTT(config.MQTTConfig{
+		Broker:      broker,
+		ClientId:    "control",
+		TopicPrefix: testPrefix,
+		AckTimeout:  2 * time.Second,
+	}, log.New(io.Discard, "", 0))
+	if err := bridge.Connect(); err != nil {
+		t.Fatalf("failed to connect the bridge: %v", err)
+	}
+	t.Cleanup(bridge.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	states, err := bridge.Subscribe(ctx)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	// the bridge subscribes to the device topics once it is connected
+	time.Sleep(100 * time.Millisecond)
+	return bridge, states
+}
+
+// connectDevice connects a client standing for a device, with offline as its
+// last will and online published once it is connected
+func connectDevice(t *testing.T, broker string, device Device) mqtt.Client {
+	t.Helper()
+	availability := testPrefix + "/" + device.HouseId + "/" + device.Id + "/" + TopicAvailability
+	client := mqtt.NewClient(mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(device.Id).
+		SetWill(availability, Offline, 1, true))
+	if token := client.Connect(); !token.WaitTimeout(connectTimeout) || token.Error() != nil {
+		t.Fatalf("failed to connect device %s: %v", device.Id, token.Error())
+	}
+	t.Cleanup(func() { client.Disconnect(0) })
+	client.Publish(availability, 1, true, Online).WaitTimeout(connectTimeout)
+	return client
+}
+
+// nextState waits for the next state of a device among those the bridge hands out
+func nextState(t *testing.T, states <-chan State, deviceId string, match func(State) bool) State {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case state := <-states:
+			if state.Id == deviceId && match(state) {
+				return state
+			}
+		case <-timeout:
+			t.Fatalf("no matching state from device %s", deviceId)
+		}
+	}
+}
+
+func TestApplyWaitsForTheReportedState(t *testing.T) {
+	_, broker := startTestBroker(t)
+	bridge, states := startTestBridge(t, broker)
+
+	target := Device{Id: "lamp", HouseId: "house"}
+	device := connectDevice(t, broker, target)
+	// the device carries out whatever it is set to and reports it back
+	device.Subscribe(bridge.Topic(target, TopicSet), 1, func(client mqtt.Client, msg mqtt.Message) {
+		var payload Payload
+		if err := json.Unmarshal(msg.Payload(), &payload); err != nil {
+			t.Errorf("malformed set payload: %v", err)
+			return
+		}
+		body, _ := json.Marshal(&Payload{Status: payload.Status, DeviceAttributes: payload.DeviceAttributes})
+		client.Publish(bridge.Topic(target, TopicState), 1, false, body)
+	}).WaitTimeout(connectTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bridge.Apply(ctx, Command{Device: target, Status: "on"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	state := nextState(t, states, target.Id, func(state State) bool { return len(state.Status) > 0 })
+	if state.Status != "on" {
+		t.Errorf("reported status = %q, want on", state.Status)
+	}
+	if len(state.HouseId) > 0 {
+		t.Errorf("reported house = %q, want none taken from the topic", state.HouseId)
+	}
+}
+
+func TestApplyFailsWhenTheDeviceStaysAsItIs(t *testing.T) {
+	_, broker := startTestBroker(t)
+	bridge, _ := startTestBridge(t, broker)
+
+	target := Device{Id: "stuck", HouseId: "house"}
+	device := connectDevice(t, broker, target)
+	device.Subscribe(bridge.Topic(target, TopicSet), 1, func(client mqtt.Client, _ mqtt.Message) {
+		client.Publish(bridge.Topic(target, TopicState), 1, false, `{"status":"off"}`)
+	}).WaitTimeout(connectTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bridge.Apply(ctx, Command{Device: target, Status: "on"}); !errors.Is(err, ErrRejected) {
+		t.Fatalf("Apply = %v, want ErrRejected", err)
+	}
+}
+
+func TestLastWillMarksTheDeviceOffline(t *testing.T) {
+	server, broker := startTestBroker(t)
+	bridge, states := startTestBridge(t, broker)
+
+	target := Device{Id: "sensor", HouseId: "house"}
+	connectDevice(t, broker, target)
+	online := nextState(t, states, target.Id, func(state State) bool { return state.Online != nil })
+	if !*online.Online {
+		t.Fatalf("device reported offline right after connecting")
+	}
+
+	// dropping the connection without a disconnect makes the broker publish the will
+	client, ok := server.Clients.Get(target.Id)
+	if !ok {
+		t.Fatalf("the broker does not know device %s", target.Id)
+	}
+	client.Stop(errors.New("connection lost"))
+
+	offline := nextState(t, states, target.Id, func(state State) bool { return state.Online != nil })
+	if *offline.Online {
+		t.Fatalf("device still online after its will")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bridge.Apply(ctx, Command{Device: target, Status: "on"}); !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("Apply to an offline device = %v, want ErrUnreachable", err)
+	}
+}
@@ -0,0 +1,21 @@
+package driver
+
+import "context"
+
+// Noop is the driver of a controller that has no devices to talk to: every
+// command counts as taken and no device ever reports, so what users ask for
+// is stored as it is.
+type Noop struct{}
+
+func (Noop) Apply(context.Context, Command) error {
+	return nil
+}
+
+func (Noop) Subscribe(ctx context.Context) (<-chan State, error) {
+	states := make(chan State)
+	go func() {
+		<-ctx.Done()
+		close(states)
+	}()
+	return states, nil
+}
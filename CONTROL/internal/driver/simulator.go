@@ -0,0 +1,267 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"ruziba3vich/github.com/control/internal/config"
+	"ruziba3vich/github.com/control/internal/models"
+)
+
+// subscriberBuffer is how many states a slow subscriber may fall behind
+// before further states are dropped for it
+const subscriberBuffer = 64
+
+type (
+	// Simulator is an in-process driver whose devices answer after some
+	// latency, now and then fail a command, drain their batteries and, for
+	// sensors and thermostats, report readings. It lets the whole stack run
+	// without hardware.
+	Simulator struct {
+		cfg    config.SimulatorConfig
+		logger *log.Logger
+
+		mu          sync.Mutex
+		random      *rand.Rand
+		devices     map[string]*simulatedDevice
+		subscribers map[chan State]struct{}
+	}
+
+	simulatedDevice struct {
+		device     Device
+		status     string
+		attributes models.DeviceAttributes
+		battery    float64
+		readings   map[string]float64
+	}
+)
+
+func NewSimulator(cfg config.SimulatorConfig, logger *log.Logger) *Simulator {
+	return &Simulator{
+		cfg:         cfg,
+		logger:      logger,
+		random:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		devices:     map[string]*simulatedDevice{},
+		subscribers: map[chan State]struct{}{},
+	}
+}
+
+// Add makes devices known to the simulator with the state they are stored
+// in, so those that never get a command still report. A device stored
+// without a battery level starts full, as do the devices it only learns of
+// on their first command.
+func (s *Simulator) Add(states ...State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, state := range states {
+		device := s.device(state.Device)
+		device.status = state.Status
+		device.attributes.Merge(state.Attributes)
+		if state.Battery != nil && *state.Battery > 0 {
+			device.battery = float64(*state.Battery)
+		}
+	}
+}
+
+func (s *Simulator) Apply(ctx context.Context, command Command) error {
+	if err := s.answer(ctx); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	device := s.device(command.Device)
+	if device.battery <= 0 {
+		return fmt.Errorf("%w: %s has a flat battery", ErrUnreachable, command.Id)
+	}
+	if s.random.Float64() < s.cfg.FailureRate {
+		return fmt.Errorf("%w: %s failed to carry it out", ErrRejected, command.Id)
+	}
+
+	if len(command.Status) > 0 {
+		device.status = command.Status
+	}
+	device.attributes.Merge(command.Attributes)
+	return nil
+}
+
+func (s *Simulator) Subscribe(ctx context.Context) (<-chan State, error) {
+	states := make(chan State, subscriberBuffer)
+	s.mu.Lock()
+	s.subscribers[states] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subscribers, states)
+		close(states)
+		s.mu.Unlock()
+	}()
+	return states, nil
+}
+
+// Run moves the simulated devices on every report interval until ctx is done
+func (s *Simulator) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.ReportInterval)
+	defer ticker.Stop()
+
+	s.logger.Printf("Simulating devices, reporting every %s", s.cfg.ReportInterval)
+	for {
+		select {
+		case <-ticker.C:
+			s.tick()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// tick drains the batteries, twice as fast for the devices that are on, and
+// moves the readings. Every device with some battery left sends a heartbeat,
+// carrying its battery level and readings only when they changed; a flat one
+// goes silent.
+func (s *Simulator) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, device := range s.devices {
+		if device.battery <= 0 {
+			continue
+		}
+		level, readings := device.level(), device.copyReadings()
+		drain := s.cfg.BatteryDrain
+		if device.status == "on" {
+			drain *= 2
+		}
+		device.battery = math.Max(0, device.battery-drain)
+
+		switch device.device.Type {
+		case "sensor":
+			device.readings["temperature"] = s.walk(device.readings["temperature"], 0.3, 15, 30)
+			device.readings["humidity"] = s.walk(device.readings["humidity"], 1, 30, 70)
+		case "thermostat":
+			device.readings["temperature"] = s.heat(device)
+		}
+
+		heartbeat := State{Device: device.device, ReportedAt: time.Now()}
+		if current := device.level(); current != level {
+			heartbeat.Battery = &current
+		}
+		if current := device.copyReadings(); !sameReadings(current, readings) {
+			heartbeat.Readings = current
+		}
+		s.publish(heartbeat)
+	}
+}
+
+// sameReadings tells whether two sets of readings hold the same values
+func sameReadings(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if other, ok := b[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// walk moves a reading by up to step, keeping it within min and max
+func (s *Simulator) walk(value, step, min, max float64) float64 {
+	value += (s.random.Float64()*2 - 1) * step
+	return math.Round(math.Min(max, math.Max(min, value))*10) / 10
+}
+
+// heat moves the temperature of a thermostat's room half a degree towards
+// its target while it is on, and lets it drift otherwise
+func (s *Simulator) heat(device *simulatedDevice) float64 {
+	current := device.readings["temperature"]
+	target := device.attributes.TargetTemperature
+	if device.status != "on" || target == nil {
+		return s.walk(current, 0.1, 10, 30)
+	}
+	if math.Abs(*target-current) <= 0.5 {
+		return *target
+	}
+	if *target > current {
+		return current + 0.5
+	}
+	return current - 0.5
+}
+
+// answer waits for as long as a device takes to answer, or until ctx is done
+func (s *Simulator) answer(ctx context.Context) error {
+	s.mu.Lock()
+	latency := s.cfg.Latency
+	if s.cfg.Jitter > 0 {
+		latency += time.Duration(s.random.Int63n(int64(s.cfg.Jitter)))
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-time.After(latency):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %s", ErrUnreachable, ctx.Err())
+	}
+}
+
+// device returns the simulated device, adding it with a full battery when it
+// is not known yet. s.mu must be held.
+func (s *Simulator) device(target Device) *simulatedDevice {
+	device, ok := s.devices[target.Id]
+	if !ok {
+		device = &simulatedDevice{
+			device:   target,
+			battery:  100,
+			readings: map[string]float64{},
+		}
+		switch target.Type {
+		case "sensor":
+			device.readings["temperature"], device.readings["humidity"] = 21, 45
+		case "thermostat":
+			device.readings["temperature"] = 20
+		}
+		s.devices[target.Id] = device
+	}
+	if len(target.HouseId) > 0 {
+		device.device.HouseId = target.HouseId
+	}
+	return device
+}
+
+// publish hands a state to every subscriber that has room for it. s.mu must
+// be held.
+func (s *Simulator) publish(state State) {
+	for subscriber := range s.subscribers {
+		select {
+		case subscriber <- state:
+		default:
+			s.logger.Printf("Dropped a state of device %s for a slow subscriber", state.Id)
+		}
+	}
+}
+
+// level is the battery level the device reports, in whole percents
+func (d *simulatedDevice) level() int32 {
+	return int32(math.Ceil(d.battery))
+}
+
+// copyReadings returns a copy of the readings, nil for a device without any
+func (d *simulatedDevice) copyReadings() map[string]float64 {
+	if len(d.readings) == 0 {
+		return nil
+	}
+	readings := make(map[string]float64, len(d.readings))
+	for name, value := range d.readings {
+		readings[name] = value
+	}
+	return readings
+}
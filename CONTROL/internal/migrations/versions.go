@@ -0,0 +1,94 @@
+package migrations
+
+import (
+	"context"
+
+	"ruziba3vich/github.com/control/internal/models"
+	"ruziba3vich/github.com/control/internal/storage"
+
+	"github.com/ruziba3vich/shared/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All returns the migrations of the control database. New migrations are
+// appended with the next version; applied ones must not change.
+func All(db *storage.DB) []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version:     1,
+			Description: "unique room names within a house",
+			Up: migrate.CreateIndexes(db.RoomsCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "house_id", Value: 1}, {Key: "name", Value: 1}},
+				Options: options.Index().SetName("rooms_house_name").SetUnique(true),
+			}),
+		},
+		{
+			Version:     2,
+			Description: "index houses by member and creation time",
+			Up: migrate.CreateIndexes(db.HousesCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "members.user_id", Value: 1}, {Key: "created_at", Value: 1}},
+					Options: options.Index().SetName("houses_member_created"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "created_at", Value: 1}},
+					Options: options.Index().SetName("houses_created"),
+				},
+			),
+		},
+		{
+			Version:     3,
+			Description: "index unsent outbox rows",
+			Up: migrate.CreateIndexes(db.OutboxCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "sent_at", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("outbox_unsent"),
+			}),
+		},
+		{
+			Version:     4,
+			Description: "give the members of houses created before roles a role",
+			Up:          convertMemberIds(db.HousesCollection),
+		},
+	}
+}
+
+// convertMemberIds turns the member_ids of the houses created before roles
+// into members: the owner of the house keeps owning it, everyone else becomes
+// a member. Members a house already has are left as they are, so it can run
+// again after a partial failure.
+func convertMemberIds(collection *mongo.Collection) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		members := bson.M{"$ifNull": bson.A{"$members", bson.A{}}}
+		missing := bson.M{"$setDifference": bson.A{
+			bson.M{"$setUnion": bson.A{
+				bson.M{"$ifNull": bson.A{"$member_ids", bson.A{}}},
+				bson.A{"$owner_id"},
+			}},
+			bson.M{"$map": bson.M{"input": members, "as": "member", "in": "$$member.user_id"}},
+		}}
+		_, err := collection.UpdateMany(ctx,
+			bson.M{"member_ids": bson.M{"$exists": true}},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"members": bson.M{"$concatArrays": bson.A{
+					members,
+					bson.M{"$map": bson.M{
+						"input": missing,
+						"as":    "id",
+						"in": bson.M{
+							"user_id": "$$id",
+							"role": bson.M{"$cond": bson.A{
+								bson.M{"$eq": bson.A{"$$id", "$owner_id"}},
+								models.RoleOwner,
+								models.RoleMember,
+							}},
+						},
+					}},
+				}}}}},
+				{{Key: "$unset", Value: "member_ids"}},
+			},
+		)
+		return err
+	}
+}
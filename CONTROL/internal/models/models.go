@@ -1,12 +1,250 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+
+	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+
+	"github.com/ruziba3vich/shared/access"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
 type (
 	TYPE string
 )
 
+// Roles a user can have in a house, from the most to the least privileged.
+// A house has exactly one owner, the user who created it.
 const (
-	TURNDEVICEONQUEUE  TYPE = "turn_device_on_queue"
-	TURNDEVICEOFFQUEUE TYPE = "turn_device_off_queue"
-	ADDUSERQUEUE       TYPE = "add_user_queue"
-	REMOVEUSERQUEUE    TYPE = "remove_user_queue"
+	RoleOwner  = access.RoleOwner
+	RoleAdmin  = access.RoleAdmin
+	RoleMember = access.RoleMember
+	RoleGuest  = access.RoleGuest
 )
+
+const (
+	TURNDEVICEONQUEUE   TYPE = "turn_device_on_queue"
+	TURNDEVICEOFFQUEUE  TYPE = "turn_device_off_queue"
+	ADDUSERQUEUE        TYPE = "add_user_queue"
+	REMOVEUSERQUEUE     TYPE = "remove_user_queue"
+	BATTERYQUEUE        TYPE = "battery_report_queue"
+	SETDEVICESTATEQUEUE TYPE = "set_device_state_queue"
+	HEARTBEATQUEUE      TYPE = "device_heartbeat_queue"
+)
+
+type (
+	// DeviceStateChange is the payload of a device.state_changed event.
+	// Attributes are only sent by the changes that can set them, readings by
+	// the devices that report some
+	DeviceStateChange struct {
+		DeviceId   string             `json:"device_id"`
+		HouseId    string             `json:"house_id"`
+		Status     string             `json:"status"`
+		Attributes *DeviceAttributes  `json:"attributes,omitempty"`
+		Readings   map[string]float64 `json:"readings,omitempty"`
+	}
+
+	// BatteryReport is sent by a device to the battery queue and is also the
+	// payload of the device.battery_changed event. The house a device reports
+	// is ignored; the event carries the one the device is placed in.
+	BatteryReport struct {
+		DeviceId string `json:"device_id"`
+		HouseId  string `json:"house_id"`
+		Battery  int32  `json:"battery"`
+	}
+
+	// Heartbeat is sent by a device to the heartbeat queue to tell it is
+	// still there
+	Heartbeat struct {
+		DeviceId string `json:"device_id"`
+	}
+
+	// PresenceChange is the payload of a device.presence_changed event
+	PresenceChange struct {
+		DeviceId string     `json:"device_id"`
+		HouseId  string     `json:"house_id"`
+		Online   bool       `json:"online"`
+		LastSeen *time.Time `json:"last_seen,omitempty"`
+	}
+
+	// HouseMembership is the payload of the house.member_* events
+	HouseMembership struct {
+		UserId  string `json:"user_id"`
+		HouseId string `json:"house_id"`
+		Role    string `json:"role,omitempty"`
+	}
+
+	// Reply is published to the ReplyTo queue of a consumed command so the
+	// caller learns how it ended.
+	Reply struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Error   string          `json:"error,omitempty"`
+		Data    json.RawMessage `json:"data,omitempty"`
+	}
+)
+
+const (
+	ReplyStatusSuccess    = "success"
+	ReplyStatusError      = "error"
+	ReplyStatusBadRequest = "bad_request"
+	ReplyStatusForbidden  = "forbidden"
+)
+
+type (
+	// House is a home managed by the controller, with the users that belong to it
+	House struct {
+		Id        primitive.ObjectID `bson:"_id" json:"id"`
+		Name      string             `bson:"name" json:"name"`
+		OwnerId   string             `bson:"owner_id" json:"owner_id"`
+		Address   string             `bson:"address" json:"address"`
+		Members   []Member           `bson:"members" json:"members"`
+		CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+		UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	}
+
+	// Member is a user of a house with the role they have in it
+	Member struct {
+		UserId string `bson:"user_id" json:"user_id"`
+		Role   string `bson:"role" json:"role"`
+	}
+
+	// Room is a room of a house that devices are placed in. Rooms sharing a
+	// zone (e.g. "upstairs") can be listed together.
+	Room struct {
+		Id        primitive.ObjectID `bson:"_id" json:"id"`
+		HouseId   string             `bson:"house_id" json:"house_id"`
+		Name      string             `bson:"name" json:"name"`
+		Zone      string             `bson:"zone,omitempty" json:"zone"`
+		CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	}
+
+	// Device is the part of a device document, owned by DEVICES, that the
+	// controller reads and changes. Online and LastSeen are unset until the
+	// controller first hears from the device.
+	Device struct {
+		Id         primitive.ObjectID `bson:"_id"`
+		Type       string             `bson:"type"`
+		Status     string             `bson:"status"`
+		HouseId    string             `bson:"house_id"`
+		Attributes *DeviceAttributes  `bson:"attributes,omitempty"`
+		Battery    int32              `bson:"battery"`
+		Online     *bool              `bson:"online,omitempty"`
+		LastSeen   *time.Time         `bson:"last_seen,omitempty"`
+	}
+
+	// DeviceAttributes are the typed settings of a device, as DEVICES stores them
+	DeviceAttributes struct {
+		Brightness        *int32   `bson:"brightness,omitempty" json:"brightness,omitempty"`
+		TargetTemperature *float64 `bson:"target_temperature,omitempty" json:"target_temperature,omitempty"`
+		LockState         *string  `bson:"lock_state,omitempty" json:"lock_state,omitempty"`
+		Position          *int32   `bson:"position,omitempty" json:"position,omitempty"`
+		Color             *string  `bson:"color,omitempty" json:"color,omitempty"`
+	}
+)
+
+func (h *House) ToProto() *controlrpc.House {
+	return &controlrpc.House{
+		Id:        h.Id.Hex(),
+		Name:      h.Name,
+		OwnerId:   h.OwnerId,
+		Address:   h.Address,
+		MemberIds: h.MemberIds(),
+		Members:   h.ProtoMembers(),
+		CreatedAt: h.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: h.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// MemberIds lists the ids of the members of the house
+func (h *House) MemberIds() []string {
+	ids := make([]string, 0, len(h.Members))
+	for _, member := range h.Members {
+		ids = append(ids, member.UserId)
+	}
+	return ids
+}
+
+func (h *House) ProtoMembers() []*controlrpc.HouseMember {
+	members := make([]*controlrpc.HouseMember, 0, len(h.Members))
+	for _, member := range h.Members {
+		members = append(members, &controlrpc.HouseMember{UserId: member.UserId, Role: member.Role})
+	}
+	return members
+}
+
+// RoleOf returns the role of a user in the house, or "" for a non-member
+func (h *House) RoleOf(userId string) string {
+	for _, member := range h.Members {
+		if member.UserId == userId {
+			return member.Role
+		}
+	}
+	return ""
+}
+
+// ValidRole reports whether role can be granted to a house member. The owner
+// role is not grantable.
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleMember, RoleGuest:
+		return true
+	}
+	return false
+}
+
+func (r *Room) ToProto() *controlrpc.Room {
+	return &controlrpc.Room{
+		Id:        r.Id.Hex(),
+		HouseId:   r.HouseId,
+		Name:      r.Name,
+		Zone:      r.Zone,
+		CreatedAt: r.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// Merge copies the attributes that are set in other
+func (a *DeviceAttributes) Merge(other *DeviceAttributes) {
+	if other == nil {
+		return
+	}
+	if other.Brightness != nil {
+		value := *other.Brightness
+		a.Brightness = &value
+	}
+	if other.TargetTemperature != nil {
+		value := *other.TargetTemperature
+		a.TargetTemperature = &value
+	}
+	if other.LockState != nil {
+		value := *other.LockState
+		a.LockState = &value
+	}
+	if other.Position != nil {
+		value := *other.Position
+		a.Position = &value
+	}
+	if other.Color != nil {
+		value := *other.Color
+		a.Color = &value
+	}
+}
+
+// ToState returns the full state of the device
+func (d *Device) ToState() *controlrpc.DeviceState {
+	state := controlrpc.DeviceState{
+		DeviceId: d.Id.Hex(),
+		HouseId:  d.HouseId,
+		Type:     d.Type,
+		Status:   d.Status,
+	}
+	if d.Attributes != nil {
+		state.Brightness = d.Attributes.Brightness
+		state.Color = d.Attributes.Color
+		state.TargetTemperature = d.Attributes.TargetTemperature
+		state.LockState = d.Attributes.LockState
+		state.Position = d.Attributes.Position
+	}
+	return &state
+}
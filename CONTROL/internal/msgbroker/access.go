@@ -0,0 +1,82 @@
+package msgbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/models"
+
+	"github.com/ruziba3vich/shared/access"
+	"github.com/ruziba3vich/shared/events"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authorizeDevice checks that the actor of a command may perform action on a
+// device. Only administrators may command a device that belongs to no house.
+func (m *MsgBrokerService) authorizeDevice(ctx context.Context, action access.Action, deviceId string) error {
+	houseId, err := m.storageService.DeviceHouse(ctx, deviceId)
+	if err != nil {
+		return err
+	}
+	role, err := m.roleOf(ctx, events.ActorFromContext(ctx), houseId)
+	if err != nil {
+		return err
+	}
+	return access.Check(role, action)
+}
+
+// authorizeMembership checks that the actor of a command may add a user to
+// a house with the role it asks for, or remove them from it. Anyone may
+// leave a house.
+func (m *MsgBrokerService) authorizeMembership(ctx context.Context, req *controlrpc.UserRequest, add bool) error {
+	actor := events.ActorFromContext(ctx)
+	if !add && actor == req.UserId {
+		return nil
+	}
+	callerRole, err := m.roleOf(ctx, actor, req.HouseId)
+	if err != nil {
+		return err
+	}
+	targetRole, err := m.roleOf(ctx, req.UserId, req.HouseId)
+	if err != nil {
+		return err
+	}
+	if add {
+		return access.CheckGrant(callerRole, targetRole, req.Role)
+	}
+	return access.CheckRemoval(callerRole, targetRole)
+}
+
+// roleOf returns the role of a user in a house, "" for a non-member. As on
+// the gateway, administrators are the owners of what belongs to no house.
+func (m *MsgBrokerService) roleOf(ctx context.Context, userId, houseId string) (string, error) {
+	if len(houseId) == 0 {
+		admin, err := m.storageService.IsAdmin(ctx, userId)
+		if err != nil {
+			return "", err
+		}
+		if !admin {
+			return "", fmt.Errorf("%w: the resource does not belong to a house", access.ErrForbidden)
+		}
+		return models.RoleOwner, nil
+	}
+	response, err := m.storageService.GetMemberRole(ctx, &controlrpc.UserRequest{UserId: userId, HouseId: houseId})
+	if err != nil {
+		return "", err
+	}
+	return response.Role, nil
+}
+
+// replyStatus is the status of the reply to a command that failed with err
+func replyStatus(err error) string {
+	if errors.Is(err, access.ErrForbidden) {
+		return models.ReplyStatusForbidden
+	}
+	if code := status.Code(err); code == codes.InvalidArgument || code == codes.NotFound {
+		return models.ReplyStatusBadRequest
+	}
+	return models.ReplyStatusError
+}
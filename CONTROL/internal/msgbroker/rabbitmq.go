@@ -3,30 +3,41 @@ package msgbroker
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/models"
 	"ruziba3vich/github.com/control/internal/storage"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/shared/access"
+	"github.com/ruziba3vich/shared/events"
+	"google.golang.org/grpc/status"
 )
 
 type MsgBrokerService struct {
-	msgs           <-chan amqp.Delivery
 	storageService *storage.Storage
+	channel        *amqp.Channel
 	logger         *log.Logger
 }
 
-func NewService(msgs <-chan amqp.Delivery, storageService *storage.Storage, logger *log.Logger) *MsgBrokerService {
+// NewService builds the consumers of the controller's queues. channel is
+// used to answer the commands that have a reply
+func NewService(storageService *storage.Storage, channel *amqp.Channel, logger *log.Logger) *MsgBrokerService {
 	return &MsgBrokerService{
-		msgs: msgs,
 		storageService: storageService,
-		logger: logger,
+		channel:        channel,
+		logger:         logger,
 	}
 }
 
-func (m *MsgBrokerService) ConsumeMessages(ch *amqp.Channel, queueName string, logger *log.Logger, handler func(context.Context, *storage.Storage, *amqp.Delivery, *log.Logger)) {
-	for msg := range m.msgs {
-		handler(context.Background(), m.storageService, &msg, logger)
+func (m *MsgBrokerService) ConsumeMessages(ctx context.Context, msgs <-chan amqp.Delivery, handler func(context.Context, *amqp.Delivery)) {
+	for msg := range msgs {
+		msgCtx := ctx
+		if actor, ok := msg.Headers[events.ActorHeader].(string); ok {
+			msgCtx = events.WithActor(ctx, actor)
+		}
+		handler(msgCtx, &msg)
 	}
 }
 
@@ -34,58 +45,209 @@ func (m *MsgBrokerService) HandleTurnDeviceOn(ctx context.Context, msg *amqp.Del
 	var req controlrpc.DeviceRequest
 	if err := json.Unmarshal(msg.Body, &req); err != nil {
 		m.logger.Printf("Failed to unmarshal message: %v", err)
+		m.replyInvalid(msg, "invalid turn device on payload", err)
 		return
 	}
 
-	_, err := m.storageService.TurnDeviceOn(ctx, &req)
+	err := m.authorizeDevice(ctx, access.ActionToggleDevice, req.DeviceId)
+	var response *controlrpc.DeviceResponse
+	if err == nil {
+		response, err = m.storageService.TurnDeviceOn(ctx, &req)
+	}
 	if err != nil {
 		m.logger.Printf("Failed to turn device on: %v", err)
+		m.replyOutcome(msg, fmt.Sprintf("could not turn device %s on", req.DeviceId), nil, err)
 		return
 	}
 	m.logger.Printf("Device %s turned on successfully", req.DeviceId)
+	m.replyOutcome(msg, "", response, nil)
 }
 
 func (m *MsgBrokerService) HandleTurnDeviceOff(ctx context.Context, msg *amqp.Delivery) {
 	var req controlrpc.DeviceRequest
 	if err := json.Unmarshal(msg.Body, &req); err != nil {
 		m.logger.Printf("Failed to unmarshal message: %v", err)
+		m.replyInvalid(msg, "invalid turn device off payload", err)
 		return
 	}
 
-	_, err := m.storageService.TurnDeviceOff(ctx, &req)
+	err := m.authorizeDevice(ctx, access.ActionToggleDevice, req.DeviceId)
+	var response *controlrpc.DeviceResponse
+	if err == nil {
+		response, err = m.storageService.TurnDeviceOff(ctx, &req)
+	}
 	if err != nil {
 		m.logger.Printf("Failed to turn device off: %v", err)
+		m.replyOutcome(msg, fmt.Sprintf("could not turn device %s off", req.DeviceId), nil, err)
 		return
 	}
 	m.logger.Printf("Device %s turned off successfully", req.DeviceId)
+	m.replyOutcome(msg, "", response, nil)
 }
 
 func (m *MsgBrokerService) HandleAddUserToHouse(ctx context.Context, msg *amqp.Delivery) {
 	var req controlrpc.UserRequest
 	if err := json.Unmarshal(msg.Body, &req); err != nil {
 		m.logger.Printf("Failed to unmarshal message: %v", err)
+		m.replyInvalid(msg, "invalid add user payload", err)
 		return
 	}
 
-	_, err := m.storageService.AddUserToHouse(ctx, &req)
+	err := m.authorizeMembership(ctx, &req, true)
+	var response *controlrpc.HouseResponse
+	if err == nil {
+		response, err = m.storageService.AddUserToHouse(ctx, &req)
+	}
 	if err != nil {
 		m.logger.Printf("Failed to add user to house: %v", err)
+		m.replyOutcome(msg, fmt.Sprintf("could not add user %s to house %s", req.UserId, req.HouseId), nil, err)
 		return
 	}
 	m.logger.Printf("User %s added to house %s successfully", req.UserId, req.HouseId)
+	m.replyOutcome(msg, "", response, nil)
 }
 
 func (m *MsgBrokerService) HandleRemoveUserFromHouse(ctx context.Context, msg *amqp.Delivery) {
 	var req controlrpc.UserRequest
 	if err := json.Unmarshal(msg.Body, &req); err != nil {
 		m.logger.Printf("Failed to unmarshal message: %v", err)
+		m.replyInvalid(msg, "invalid remove user payload", err)
 		return
 	}
 
-	_, err := m.storageService.RemoveUserFromHouse(ctx, &req)
+	err := m.authorizeMembership(ctx, &req, false)
+	var response *controlrpc.HouseResponse
+	if err == nil {
+		response, err = m.storageService.RemoveUserFromHouse(ctx, &req)
+	}
 	if err != nil {
 		m.logger.Printf("Failed to remove user from house: %v", err)
+		m.replyOutcome(msg, fmt.Sprintf("could not remove user %s from house %s", req.UserId, req.HouseId), nil, err)
 		return
 	}
 	m.logger.Printf("User %s removed from house %s successfully", req.UserId, req.HouseId)
+	m.replyOutcome(msg, "", response, nil)
+}
+
+func (m *MsgBrokerService) HandleBatteryReport(ctx context.Context, msg *amqp.Delivery) {
+	var req models.BatteryReport
+	if err := json.Unmarshal(msg.Body, &req); err != nil {
+		m.logger.Printf("Failed to unmarshal message: %v", err)
+		return
+	}
+
+	if err := m.storageService.UpdateBatteryStatus(ctx, &req); err != nil {
+		m.logger.Printf("Failed to update battery status: %v", err)
+		return
+	}
+	m.logger.Printf("Battery of device %s updated to %d", req.DeviceId, req.Battery)
+}
+
+func (m *MsgBrokerService) HandleHeartbeat(ctx context.Context, msg *amqp.Delivery) {
+	var req models.Heartbeat
+	if err := json.Unmarshal(msg.Body, &req); err != nil {
+		m.logger.Printf("Failed to unmarshal message: %v", err)
+		return
+	}
+
+	if err := m.storageService.RecordHeartbeat(ctx, &req); err != nil {
+		m.logger.Printf("Failed to record heartbeat: %v", err)
+	}
+}
+
+// HandleSetDeviceState applies a state patch and answers with the resulting
+// state of the device, or with why the patch was refused
+func (m *MsgBrokerService) HandleSetDeviceState(ctx context.Context, msg *amqp.Delivery) {
+	var req controlrpc.SetDeviceStateRequest
+	if err := json.Unmarshal(msg.Body, &req); err != nil {
+		m.logger.Printf("Failed to unmarshal message: %v", err)
+		m.replyInvalid(msg, "invalid set device state payload", err)
+		return
+	}
+
+	err := m.authorizeDevice(ctx, access.ActionToggleDevice, req.DeviceId)
+	var state *controlrpc.DeviceState
+	if err == nil {
+		state, err = m.storageService.SetDeviceState(ctx, &req)
+	}
+	if err != nil {
+		m.logger.Printf("Failed to set device state: %v", err)
+		m.reply(msg, &models.Reply{
+			Status:  replyStatus(err),
+			Message: fmt.Sprintf("could not set the state of device %s", req.DeviceId),
+			Error:   status.Convert(err).Message(),
+		})
+		return
+	}
+	m.logger.Printf("State of device %s set successfully", req.DeviceId)
+
+	reply := models.Reply{
+		Status:  models.ReplyStatusSuccess,
+		Message: "device state set",
+	}
+	if data, err := json.Marshal(state); err != nil {
+		m.logger.Printf("Failed to marshal response: %v", err)
+	} else {
+		reply.Data = data
+	}
+	m.reply(msg, &reply)
+}
+
+// replyInvalid answers a command whose payload could not be read
+func (m *MsgBrokerService) replyInvalid(msg *amqp.Delivery, message string, err error) {
+	m.reply(msg, &models.Reply{
+		Status:  models.ReplyStatusBadRequest,
+		Message: message,
+		Error:   err.Error(),
+	})
+}
+
+// replyOutcome answers a command with the response it produced, or with why
+// it failed when err is set
+func (m *MsgBrokerService) replyOutcome(msg *amqp.Delivery, failure string, response interface{ GetMessage() string }, err error) {
+	if err != nil {
+		m.reply(msg, &models.Reply{
+			Status:  replyStatus(err),
+			Message: failure,
+			Error:   status.Convert(err).Message(),
+		})
+		return
+	}
+	reply := models.Reply{
+		Status:  models.ReplyStatusSuccess,
+		Message: response.GetMessage(),
+	}
+	if data, err := json.Marshal(response); err != nil {
+		m.logger.Printf("Failed to marshal response: %v", err)
+	} else {
+		reply.Data = data
+	}
+	m.reply(msg, &reply)
+}
+
+// reply answers the caller of a command on its ReplyTo queue, echoing the
+// correlation id so the caller can match the reply
+func (m *MsgBrokerService) reply(msg *amqp.Delivery, reply *models.Reply) {
+	if len(msg.ReplyTo) == 0 {
+		return
+	}
+	body, err := json.Marshal(reply)
+	if err != nil {
+		m.logger.Printf("Failed to marshal reply: %v", err)
+		return
+	}
+	err = m.channel.Publish(
+		"",          // exchange
+		msg.ReplyTo, // routing key
+		false,       // mandatory
+		false,       // immediate
+		amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: msg.CorrelationId,
+			Body:          body,
+		},
+	)
+	if err != nil {
+		m.logger.Printf("Failed to publish reply to %s: %v", msg.ReplyTo, err)
+	}
 }
@@ -0,0 +1,50 @@
+package presence
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ruziba3vich/github.com/control/internal/storage"
+)
+
+type (
+	// Reaper marks offline the devices that stayed silent for longer than
+	// the timeout, looking for them on every sweep
+	Reaper struct {
+		storage  *storage.Storage
+		timeout  time.Duration
+		interval time.Duration
+		logger   *log.Logger
+	}
+)
+
+func NewReaper(storage *storage.Storage, timeout, interval time.Duration, logger *log.Logger) *Reaper {
+	return &Reaper{
+		storage:  storage,
+		timeout:  timeout,
+		interval: interval,
+		logger:   logger,
+	}
+}
+
+// Run sweeps until ctx is done
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Println("Context done, stopping presence reaper")
+			return
+		case <-ticker.C:
+			reaped, err := r.storage.ReapSilentDevices(ctx, r.timeout)
+			if err != nil {
+				r.logger.Printf("PRESENCE REAPER ERROR: %s\n", err.Error())
+			}
+			if reaped > 0 {
+				r.logger.Printf("Marked %d devices silent for over %s offline", reaped, r.timeout)
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"log"
+
+	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/storage"
+)
+
+type (
+	Service struct {
+		storage *storage.Storage
+		logger  *log.Logger
+		controlrpc.UnimplementedControllerServiceServer
+	}
+)
+
+func New(storage *storage.Storage, logger *log.Logger) *Service {
+	return &Service{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (s *Service) TurnDeviceOn(ctx context.Context, req *controlrpc.DeviceRequest) (*controlrpc.DeviceResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <TurnDeviceOn> SERVICE --")
+	return s.storage.TurnDeviceOn(ctx, req)
+}
+
+func (s *Service) TurnDeviceOff(ctx context.Context, req *controlrpc.DeviceRequest) (*controlrpc.DeviceResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <TurnDeviceOff> SERVICE --")
+	return s.storage.TurnDeviceOff(ctx, req)
+}
+
+func (s *Service) AddUserToHouse(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.HouseResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <AddUserToHouse> SERVICE --")
+	return s.storage.AddUserToHouse(ctx, req)
+}
+
+func (s *Service) RemoveUserFromHouse(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.HouseResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <RemoveUserFromHouse> SERVICE --")
+	return s.storage.RemoveUserFromHouse(ctx, req)
+}
+
+func (s *Service) GetBatteryStatus(ctx context.Context, req *controlrpc.DeviceRequest) (*controlrpc.BatteryResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetBatteryStatus> SERVICE --")
+	return s.storage.GetBatteryStatus(ctx, req)
+}
+
+func (s *Service) CreateHouse(ctx context.Context, req *controlrpc.CreateHouseRequest) (*controlrpc.House, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <CreateHouse> SERVICE --")
+	return s.storage.CreateHouse(ctx, req)
+}
+
+func (s *Service) GetHouse(ctx context.Context, req *controlrpc.HouseIdRequest) (*controlrpc.House, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetHouse> SERVICE --")
+	return s.storage.GetHouse(ctx, req)
+}
+
+func (s *Service) ListHouses(ctx context.Context, req *controlrpc.ListHousesRequest) (*controlrpc.ListHousesResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <ListHouses> SERVICE --")
+	return s.storage.ListHouses(ctx, req)
+}
+
+func (s *Service) UpdateHouse(ctx context.Context, req *controlrpc.UpdateHouseRequest) (*controlrpc.House, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <UpdateHouse> SERVICE --")
+	return s.storage.UpdateHouse(ctx, req)
+}
+
+func (s *Service) DeleteHouse(ctx context.Context, req *controlrpc.HouseIdRequest) (*controlrpc.HouseResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <DeleteHouse> SERVICE --")
+	return s.storage.DeleteHouse(ctx, req)
+}
+
+func (s *Service) GetHouseMembers(ctx context.Context, req *controlrpc.HouseIdRequest) (*controlrpc.HouseMembersResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetHouseMembers> SERVICE --")
+	return s.storage.GetHouseMembers(ctx, req)
+}
+
+func (s *Service) GetUserHouses(ctx context.Context, req *controlrpc.UserHousesRequest) (*controlrpc.ListHousesResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetUserHouses> SERVICE --")
+	return s.storage.GetUserHouses(ctx, req)
+}
+
+func (s *Service) CreateRoom(ctx context.Context, req *controlrpc.CreateRoomRequest) (*controlrpc.Room, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <CreateRoom> SERVICE --")
+	return s.storage.CreateRoom(ctx, req)
+}
+
+func (s *Service) GetRoom(ctx context.Context, req *controlrpc.RoomIdRequest) (*controlrpc.Room, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetRoom> SERVICE --")
+	return s.storage.GetRoom(ctx, req)
+}
+
+func (s *Service) ListRooms(ctx context.Context, req *controlrpc.ListRoomsRequest) (*controlrpc.ListRoomsResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <ListRooms> SERVICE --")
+	return s.storage.ListRooms(ctx, req)
+}
+
+func (s *Service) UpdateRoom(ctx context.Context, req *controlrpc.UpdateRoomRequest) (*controlrpc.Room, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <UpdateRoom> SERVICE --")
+	return s.storage.UpdateRoom(ctx, req)
+}
+
+func (s *Service) DeleteRoom(ctx context.Context, req *controlrpc.RoomIdRequest) (*controlrpc.HouseResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <DeleteRoom> SERVICE --")
+	return s.storage.DeleteRoom(ctx, req)
+}
+
+func (s *Service) TurnRoomOff(ctx context.Context, req *controlrpc.RoomIdRequest) (*controlrpc.DeviceResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <TurnRoomOff> SERVICE --")
+	return s.storage.TurnRoomOff(ctx, req)
+}
+
+func (s *Service) SetMemberRole(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.HouseResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <SetMemberRole> SERVICE --")
+	return s.storage.SetMemberRole(ctx, req)
+}
+
+func (s *Service) GetMemberRole(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.MemberRoleResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetMemberRole> SERVICE --")
+	return s.storage.GetMemberRole(ctx, req)
+}
+
+func (s *Service) SetDeviceState(ctx context.Context, req *controlrpc.SetDeviceStateRequest) (*controlrpc.DeviceState, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <SetDeviceState> SERVICE --")
+	return s.storage.SetDeviceState(ctx, req)
+}
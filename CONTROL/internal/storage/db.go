@@ -5,21 +5,30 @@ import (
 	"fmt"
 	"log"
 	"ruziba3vich/github.com/control/internal/config"
+	"ruziba3vich/github.com/control/internal/driver"
 
+	"github.com/ruziba3vich/shared/events"
+	"github.com/ruziba3vich/shared/outbox"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type (
 	DB struct {
-		Client          *mongo.Client
+		Client           *mongo.Client
+		HousesCollection *mongo.Collection
+		RoomsCollection  *mongo.Collection
+		OutboxCollection *mongo.Collection
+		// UsersCollection belongs to the users service and is only read, to
+		// check that users exist before they are added to a house
 		UsersCollection *mongo.Collection
 	}
 )
 
-func NewStorage(database *DB, logger *log.Logger) *Storage {
+func NewStorage(database *DB, deviceDriver driver.DeviceDriver, logger *log.Logger) *Storage {
 	return &Storage{
 		database: database,
+		driver:   deviceDriver,
 		logger:   logger,
 	}
 }
@@ -37,8 +46,11 @@ func ConnectDB(cfg *config.Config, ctx context.Context) (*DB, error) {
 	}
 
 	return &DB{
-		Client:          client,
-		UsersCollection: client.Database(cfg.DbConfig.MongoDB).Collection(cfg.DbConfig.Collection),
+		Client:           client,
+		HousesCollection: client.Database(cfg.DbConfig.MongoDB).Collection("houses"),
+		RoomsCollection:  client.Database(cfg.DbConfig.MongoDB).Collection("rooms"),
+		OutboxCollection: client.Database(cfg.DbConfig.MongoDB).Collection(outbox.CollectionName),
+		UsersCollection:  client.Database(cfg.DbConfig.UsersDB).Collection("users"),
 	}, nil
 }
 
@@ -48,3 +60,32 @@ func (db *DB) DisconnectDB(ctx context.Context) error {
 	}
 	return nil
 }
+
+// withTransaction runs fn in a Mongo transaction, so a change and the outbox
+// row announcing it are committed or rolled back together
+func (s *Storage) withTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := s.database.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %s", err.Error())
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// writeEvent wraps payload in a domain event envelope and stores it as an
+// outbox row inside the transaction of sessCtx
+func (s *Storage) writeEvent(sessCtx mongo.SessionContext, eventType string, payload interface{}) error {
+	msg, err := outbox.NewMessage(eventType, events.New(sessCtx, eventType, payload))
+	if err != nil {
+		return err
+	}
+	if _, err := s.database.OutboxCollection.InsertOne(sessCtx, msg); err != nil {
+		s.logger.Printf("Failed to insert outbox row: %v", err)
+		return fmt.Errorf("failed to insert outbox row: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/models"
+
+	"github.com/ruziba3vich/shared/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateHouse stores a new house; its owner is its first member
+func (s *Storage) CreateHouse(ctx context.Context, req *controlrpc.CreateHouseRequest) (*controlrpc.House, error) {
+	if len(req.Name) == 0 || len(req.OwnerId) == 0 {
+		return nil, fmt.Errorf("house name and owner id are required")
+	}
+	now := time.Now().UTC()
+	house := models.House{
+		Id:        primitive.NewObjectID(),
+		Name:      req.Name,
+		OwnerId:   req.OwnerId,
+		Address:   req.Address,
+		Members:   []models.Member{{UserId: req.OwnerId, Role: models.RoleOwner}},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := s.database.HousesCollection.InsertOne(sessCtx, house); err != nil {
+			s.logger.Printf("Error creating house: %v", err)
+			return fmt.Errorf("failed to create house: %s", err.Error())
+		}
+		if err := s.writeEvent(sessCtx, events.HouseCreated, house.ToProto()); err != nil {
+			return err
+		}
+		return s.writeEvent(sessCtx, events.HouseMemberAdded, &models.HouseMembership{
+			UserId:  house.OwnerId,
+			HouseId: house.Id.Hex(),
+			Role:    models.RoleOwner,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return house.ToProto(), nil
+}
+
+// GetHouse finds a house by id
+func (s *Storage) GetHouse(ctx context.Context, req *controlrpc.HouseIdRequest) (*controlrpc.House, error) {
+	house, err := s.getHouse(ctx, req.HouseId)
+	if err != nil {
+		return nil, err
+	}
+	return house.ToProto(), nil
+}
+
+// ListHouses returns a page of houses
+func (s *Storage) ListHouses(ctx context.Context, req *controlrpc.ListHousesRequest) (*controlrpc.ListHousesResponse, error) {
+	findOptions := options.Find().SetSort(bson.M{"created_at": 1})
+	if req.Limit > 0 {
+		page := req.Page
+		if page < 1 {
+			page = 1
+		}
+		findOptions.SetLimit(int64(req.Limit))
+		findOptions.SetSkip(int64((page - 1) * req.Limit))
+	}
+	return s.findHouses(ctx, bson.M{}, findOptions)
+}
+
+// UpdateHouse renames or moves a house; empty fields are left as they are
+func (s *Storage) UpdateHouse(ctx context.Context, req *controlrpc.UpdateHouseRequest) (*controlrpc.House, error) {
+	objectId, err := primitive.ObjectIDFromHex(req.HouseId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid house id: %s", req.HouseId)
+	}
+	set := bson.M{"updated_at": time.Now().UTC()}
+	if len(req.Name) > 0 {
+		set["name"] = req.Name
+	}
+	if len(req.Address) > 0 {
+		set["address"] = req.Address
+	}
+
+	var house models.House
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		err := s.database.HousesCollection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": objectId},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&house)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return fmt.Errorf("no house found with id: %s", req.HouseId)
+			}
+			s.logger.Printf("Error updating house: %v", err)
+			return fmt.Errorf("failed to update house: %s", err.Error())
+		}
+		return s.writeEvent(sessCtx, events.HouseUpdated, house.ToProto())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return house.ToProto(), nil
+}
+
+// DeleteHouse removes a house and its rooms, leaving its devices unplaced.
+// The house.deleted event carries the former members so their memberships can
+// be dropped as well.
+func (s *Storage) DeleteHouse(ctx context.Context, req *controlrpc.HouseIdRequest) (*controlrpc.HouseResponse, error) {
+	objectId, err := primitive.ObjectIDFromHex(req.HouseId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid house id: %s", req.HouseId)
+	}
+
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var house models.House
+		if err := s.database.HousesCollection.FindOneAndDelete(sessCtx, bson.M{"_id": objectId}).Decode(&house); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return fmt.Errorf("no house found with id: %s", req.HouseId)
+			}
+			s.logger.Printf("Error deleting house: %v", err)
+			return fmt.Errorf("failed to delete house: %s", err.Error())
+		}
+		if _, err := s.database.RoomsCollection.DeleteMany(sessCtx, bson.M{"house_id": req.HouseId}); err != nil {
+			s.logger.Printf("Error deleting rooms of house: %v", err)
+			return fmt.Errorf("failed to delete the rooms of house %s: %s", req.HouseId, err.Error())
+		}
+		if _, err := s.devicesCollection().UpdateMany(sessCtx, bson.M{"house_id": req.HouseId}, bson.M{"$set": bson.M{"house_id": "", "room_id": ""}}); err != nil {
+			s.logger.Printf("Error unplacing devices of house: %v", err)
+			return fmt.Errorf("failed to unplace the devices of house %s: %s", req.HouseId, err.Error())
+		}
+		return s.writeEvent(sessCtx, events.HouseDeleted, house.ToProto())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &controlrpc.HouseResponse{
+		Status:  "success",
+		Message: "House deleted",
+	}, nil
+}
+
+// GetHouseMembers lists the ids of the users that belong to a house
+func (s *Storage) GetHouseMembers(ctx context.Context, req *controlrpc.HouseIdRequest) (*controlrpc.HouseMembersResponse, error) {
+	house, err := s.getHouse(ctx, req.HouseId)
+	if err != nil {
+		return nil, err
+	}
+	return &controlrpc.HouseMembersResponse{
+		HouseId: house.Id.Hex(),
+		UserIds: house.MemberIds(),
+		Members: house.ProtoMembers(),
+	}, nil
+}
+
+// GetUserHouses lists the houses a user belongs to
+func (s *Storage) GetUserHouses(ctx context.Context, req *controlrpc.UserHousesRequest) (*controlrpc.ListHousesResponse, error) {
+	return s.findHouses(ctx, bson.M{"members.user_id": req.UserId}, options.Find().SetSort(bson.M{"created_at": 1}))
+}
+
+func (s *Storage) getHouse(ctx context.Context, houseId string) (*models.House, error) {
+	objectId, err := primitive.ObjectIDFromHex(houseId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid house id: %s", houseId)
+	}
+	var house models.House
+	if err := s.database.HousesCollection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&house); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("no house found with id: %s", houseId)
+		}
+		s.logger.Printf("Error getting house: %v", err)
+		return nil, fmt.Errorf("failed to get house: %s", err.Error())
+	}
+	return &house, nil
+}
+
+func (s *Storage) findHouses(ctx context.Context, filter bson.M, findOptions *options.FindOptions) (*controlrpc.ListHousesResponse, error) {
+	cursor, err := s.database.HousesCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		s.logger.Printf("Error finding houses: %v", err)
+		return nil, fmt.Errorf("failed to find houses: %s", err.Error())
+	}
+	defer cursor.Close(ctx)
+
+	var response controlrpc.ListHousesResponse
+	for cursor.Next(ctx) {
+		var house models.House
+		if err := cursor.Decode(&house); err != nil {
+			s.logger.Printf("Error decoding house: %v", err)
+			return nil, fmt.Errorf("failed to decode house: %s", err.Error())
+		}
+		response.Houses = append(response.Houses, house.ToProto())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %s", err.Error())
+	}
+	return &response, nil
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"ruziba3vich/github.com/control/internal/models"
+
+	"github.com/ruziba3vich/shared/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RecordHeartbeat notes that a device was heard from just now
+func (s *Storage) RecordHeartbeat(ctx context.Context, req *models.Heartbeat) error {
+	return s.setPresence(ctx, req.DeviceId, true, time.Now().UTC())
+}
+
+// setPresence records that a device was heard from at seenAt, or that it went
+// offline, and announces it to the house it is placed in when that flips the
+// device's presence.
+func (s *Storage) setPresence(ctx context.Context, deviceId string, online bool, seenAt time.Time) error {
+	filter, err := deviceFilter(deviceId)
+	if err != nil {
+		return err
+	}
+	set := bson.M{"online": online}
+	if online {
+		set["last_seen"] = seenAt
+	}
+
+	return s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var before models.Device
+		err := s.devicesCollection().FindOneAndUpdate(sessCtx, filter, bson.M{"$set": set}).Decode(&before)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if before.Online != nil && *before.Online == online {
+			return nil
+		}
+
+		lastSeen := before.LastSeen
+		if online {
+			lastSeen = &seenAt
+		}
+		return s.writeEvent(sessCtx, events.DevicePresenceChanged, &models.PresenceChange{
+			DeviceId: deviceId,
+			HouseId:  before.HouseId,
+			Online:   online,
+			LastSeen: lastSeen,
+		})
+	})
+}
+
+// ReapSilentDevices marks offline the online devices that were not heard
+// from for longer than silence, and returns how many it marked. A device
+// heard from while it runs stays online.
+func (s *Storage) ReapSilentDevices(ctx context.Context, silence time.Duration) (int, error) {
+	cutoff := time.Now().UTC().Add(-silence)
+	filter := bson.M{"deleted": false, "online": true, "last_seen": bson.M{"$lt": cutoff}}
+
+	cursor, err := s.devicesCollection().Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1, "house_id": 1, "last_seen": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find silent devices: %s", err.Error())
+	}
+	var devices []models.Device
+	if err := cursor.All(ctx, &devices); err != nil {
+		return 0, fmt.Errorf("failed to decode silent devices: %s", err.Error())
+	}
+
+	var reaped int
+	for _, device := range devices {
+		var marked bool
+		err := s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+			marked = false
+			result, err := s.devicesCollection().UpdateOne(sessCtx,
+				bson.M{"_id": device.Id, "online": true, "last_seen": bson.M{"$lt": cutoff}},
+				bson.M{"$set": bson.M{"online": false}})
+			if err != nil {
+				return err
+			}
+			if result.ModifiedCount == 0 {
+				return nil
+			}
+			marked = true
+			return s.writeEvent(sessCtx, events.DevicePresenceChanged, &models.PresenceChange{
+				DeviceId: device.Id.Hex(),
+				HouseId:  device.HouseId,
+				Online:   false,
+				LastSeen: device.LastSeen,
+			})
+		})
+		if err != nil {
+			return reaped, err
+		}
+		if marked {
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+// checkOnline refuses commands for a device known to be offline. A device
+// never heard from is given the benefit of the doubt.
+func checkOnline(device *models.Device) error {
+	if device.Online != nil && !*device.Online {
+		return status.Errorf(codes.FailedPrecondition, "device %s is offline", device.Id.Hex())
+	}
+	return nil
+}
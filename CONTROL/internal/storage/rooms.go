@@ -0,0 +1,339 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/driver"
+	"ruziba3vich/github.com/control/internal/models"
+
+	"github.com/ruziba3vich/shared/devicetypes"
+	"github.com/ruziba3vich/shared/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CreateRoom adds a room to an existing house. Room names are unique within
+// a house.
+func (s *Storage) CreateRoom(ctx context.Context, req *controlrpc.CreateRoomRequest) (*controlrpc.Room, error) {
+	if len(req.Name) == 0 {
+		return nil, fmt.Errorf("room name is required")
+	}
+	if _, err := s.getHouse(ctx, req.HouseId); err != nil {
+		return nil, err
+	}
+	room := models.Room{
+		Id:        primitive.NewObjectID(),
+		HouseId:   req.HouseId,
+		Name:      req.Name,
+		Zone:      req.Zone,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	err := s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		count, err := s.database.RoomsCollection.CountDocuments(sessCtx, bson.M{"house_id": req.HouseId, "name": req.Name})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("house %s already has a room named %s", req.HouseId, req.Name)
+		}
+		if _, err := s.database.RoomsCollection.InsertOne(sessCtx, room); mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("house %s already has a room named %s", req.HouseId, req.Name)
+		} else if err != nil {
+			s.logger.Printf("Error creating room: %v", err)
+			return fmt.Errorf("failed to create room: %s", err.Error())
+		}
+		return s.writeEvent(sessCtx, events.RoomCreated, room.ToProto())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return room.ToProto(), nil
+}
+
+// GetRoom finds a room by id
+func (s *Storage) GetRoom(ctx context.Context, req *controlrpc.RoomIdRequest) (*controlrpc.Room, error) {
+	objectId, err := primitive.ObjectIDFromHex(req.RoomId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid room id: %s", req.RoomId)
+	}
+	var room models.Room
+	if err := s.database.RoomsCollection.FindOne(ctx, bson.M{"_id": objectId}).Decode(&room); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, status.Errorf(codes.NotFound, "no room found with id: %s", req.RoomId)
+		}
+		s.logger.Printf("Error getting room: %v", err)
+		return nil, fmt.Errorf("failed to get room: %s", err.Error())
+	}
+	return room.ToProto(), nil
+}
+
+// ListRooms lists the rooms of a house, optionally only those of one zone
+func (s *Storage) ListRooms(ctx context.Context, req *controlrpc.ListRoomsRequest) (*controlrpc.ListRoomsResponse, error) {
+	if len(req.HouseId) == 0 {
+		return nil, fmt.Errorf("house id is required")
+	}
+	filter := bson.M{"house_id": req.HouseId}
+	if len(req.Zone) > 0 {
+		filter["zone"] = req.Zone
+	}
+
+	cursor, err := s.database.RoomsCollection.Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		s.logger.Printf("Error finding rooms: %v", err)
+		return nil, fmt.Errorf("failed to find rooms: %s", err.Error())
+	}
+	defer cursor.Close(ctx)
+
+	var response controlrpc.ListRoomsResponse
+	for cursor.Next(ctx) {
+		var room models.Room
+		if err := cursor.Decode(&room); err != nil {
+			s.logger.Printf("Error decoding room: %v", err)
+			return nil, fmt.Errorf("failed to decode room: %s", err.Error())
+		}
+		response.Rooms = append(response.Rooms, room.ToProto())
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error: %s", err.Error())
+	}
+	return &response, nil
+}
+
+// UpdateRoom renames a room or moves it to another zone; empty fields are
+// left as they are
+func (s *Storage) UpdateRoom(ctx context.Context, req *controlrpc.UpdateRoomRequest) (*controlrpc.Room, error) {
+	objectId, err := primitive.ObjectIDFromHex(req.RoomId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid room id: %s", req.RoomId)
+	}
+	set := bson.M{}
+	if len(req.Name) > 0 {
+		set["name"] = req.Name
+	}
+	if len(req.Zone) > 0 {
+		set["zone"] = req.Zone
+	}
+	if len(set) == 0 {
+		return s.GetRoom(ctx, &controlrpc.RoomIdRequest{RoomId: req.RoomId})
+	}
+
+	var room models.Room
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		err := s.database.RoomsCollection.FindOneAndUpdate(sessCtx,
+			bson.M{"_id": objectId},
+			bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&room)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return fmt.Errorf("no room found with id: %s", req.RoomId)
+			}
+			s.logger.Printf("Error updating room: %v", err)
+			return fmt.Errorf("failed to update room: %s", err.Error())
+		}
+		return s.writeEvent(sessCtx, events.RoomUpdated, room.ToProto())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return room.ToProto(), nil
+}
+
+// DeleteRoom removes a room; its devices stay in the house, unplaced
+func (s *Storage) DeleteRoom(ctx context.Context, req *controlrpc.RoomIdRequest) (*controlrpc.HouseResponse, error) {
+	objectId, err := primitive.ObjectIDFromHex(req.RoomId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid room id: %s", req.RoomId)
+	}
+
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var room models.Room
+		if err := s.database.RoomsCollection.FindOneAndDelete(sessCtx, bson.M{"_id": objectId}).Decode(&room); err != nil {
+			if err == mongo.ErrNoDocuments {
+				return fmt.Errorf("no room found with id: %s", req.RoomId)
+			}
+			s.logger.Printf("Error deleting room: %v", err)
+			return fmt.Errorf("failed to delete room: %s", err.Error())
+		}
+		if _, err := s.devicesCollection().UpdateMany(sessCtx, bson.M{"room_id": req.RoomId}, bson.M{"$set": bson.M{"room_id": ""}}); err != nil {
+			s.logger.Printf("Error unplacing devices of room: %v", err)
+			return fmt.Errorf("failed to unplace the devices of room %s: %s", req.RoomId, err.Error())
+		}
+		return s.writeEvent(sessCtx, events.RoomDeleted, room.ToProto())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &controlrpc.HouseResponse{
+		Status:  "success",
+		Message: "Room deleted",
+	}, nil
+}
+
+// TurnRoomOff switches off every device of a room that can be switched and
+// is not off yet. Each device gets the command on its own; those that are
+// offline or don't take it stay as they are and are counted in the response.
+func (s *Storage) TurnRoomOff(ctx context.Context, req *controlrpc.RoomIdRequest) (*controlrpc.DeviceResponse, error) {
+	room, err := s.GetRoom(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.M{"room_id": room.Id, "deleted": false, "status": bson.M{"$ne": "off"}}
+
+	cursor, err := s.devicesCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var stored []models.Device
+	if err := cursor.All(ctx, &stored); err != nil {
+		return nil, err
+	}
+	devices := stored[:0]
+	for _, device := range stored {
+		if deviceType, ok := devicetypes.Lookup(device.Type); ok && deviceType.Has(devicetypes.CapabilityOnOff) {
+			devices = append(devices, device)
+		}
+	}
+	var taken []primitive.ObjectID
+	for i := range devices {
+		if checkOnline(&devices[i]) != nil {
+			continue
+		}
+		if err := s.dispatch(ctx, driver.Command{Device: driverDevice(&devices[i]), Status: "off"}); err != nil {
+			continue
+		}
+		taken = append(taken, devices[i].Id)
+	}
+	filter["_id"] = bson.M{"$in": taken}
+
+	var switched int
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		switched = 0
+		if len(taken) == 0 {
+			return nil
+		}
+		cursor, err := s.devicesCollection().Find(sessCtx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		var devices []struct {
+			Id primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.All(sessCtx, &devices); err != nil {
+			return err
+		}
+		if len(devices) == 0 {
+			return nil
+		}
+		if _, err := s.devicesCollection().UpdateMany(sessCtx, filter, bson.M{"$set": bson.M{"status": "off"}}); err != nil {
+			return err
+		}
+		for _, device := range devices {
+			if err := s.writeEvent(sessCtx, events.DeviceStateChanged, &models.DeviceStateChange{
+				DeviceId: device.Id.Hex(),
+				HouseId:  room.HouseId,
+				Status:   "off",
+			}); err != nil {
+				return err
+			}
+		}
+		switched = len(devices)
+		return nil
+	})
+	if err != nil {
+		s.logger.Printf("Error turning room off: %v", err)
+		return nil, err
+	}
+
+	message := fmt.Sprintf("%d devices turned off", switched)
+	if failed := len(devices) - len(taken); failed > 0 {
+		message = fmt.Sprintf("%s, %d did not respond", message, failed)
+	}
+	return &controlrpc.DeviceResponse{
+		Status:  "success",
+		Message: message,
+	}, nil
+}
+
+// MigrateLocations places the devices that only have a free-text location
+// into the room of that name in the given house, creating the rooms that are
+// missing. With dryRun set nothing is written; the counts are what would
+// change.
+func (s *Storage) MigrateLocations(ctx context.Context, houseId string, dryRun bool) (devices int, rooms int, err error) {
+	if _, err := s.getHouse(ctx, houseId); err != nil {
+		return 0, 0, err
+	}
+	filter := bson.M{
+		"location": bson.M{"$exists": true, "$ne": ""},
+		"$or":      bson.A{bson.M{"room_id": bson.M{"$exists": false}}, bson.M{"room_id": ""}},
+	}
+
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		devices, rooms = 0, 0
+		cursor, err := s.devicesCollection().Find(sessCtx, filter, options.Find().SetProjection(bson.M{"_id": 1, "location": 1}))
+		if err != nil {
+			return err
+		}
+		var legacy []struct {
+			Id       interface{} `bson:"_id"`
+			Location string      `bson:"location"`
+		}
+		if err := cursor.All(sessCtx, &legacy); err != nil {
+			return err
+		}
+
+		roomIds := map[string]string{}
+		for _, device := range legacy {
+			roomId, ok := roomIds[device.Location]
+			if !ok {
+				var room models.Room
+				err := s.database.RoomsCollection.FindOne(sessCtx, bson.M{"house_id": houseId, "name": device.Location}).Decode(&room)
+				switch {
+				case err == mongo.ErrNoDocuments:
+					room = models.Room{
+						Id:        primitive.NewObjectID(),
+						HouseId:   houseId,
+						Name:      device.Location,
+						CreatedAt: time.Now().UTC(),
+					}
+					rooms++
+					if !dryRun {
+						if _, err := s.database.RoomsCollection.InsertOne(sessCtx, room); err != nil {
+							return err
+						}
+						if err := s.writeEvent(sessCtx, events.RoomCreated, room.ToProto()); err != nil {
+							return err
+						}
+					}
+				case err != nil:
+					return err
+				}
+				roomId = room.Id.Hex()
+				roomIds[device.Location] = roomId
+			}
+
+			devices++
+			if dryRun {
+				continue
+			}
+			update := bson.M{
+				"$set":   bson.M{"house_id": houseId, "room_id": roomId},
+				"$unset": bson.M{"location": ""},
+			}
+			if _, err := s.devicesCollection().UpdateOne(sessCtx, bson.M{"_id": device.Id}, update); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return devices, rooms, err
+}
@@ -0,0 +1,349 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/driver"
+	"ruziba3vich/github.com/control/internal/models"
+
+	"github.com/ruziba3vich/shared/devicetypes"
+	"github.com/ruziba3vich/shared/events"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errEmptyPatch = errors.New("the patch changes nothing")
+
+// SetDeviceState applies a patch to a device and returns its full state. The
+// patch is checked against the capabilities of the device's type, sent to the
+// device, and once the device took it stored in one transaction with the
+// event announcing it. A patch that doesn't fit the device is
+// InvalidArgument, a device that is offline FailedPrecondition, and one that
+// doesn't take it Unavailable.
+func (s *Storage) SetDeviceState(ctx context.Context, req *controlrpc.SetDeviceStateRequest) (*controlrpc.DeviceState, error) {
+	filter, err := deviceFilter(req.DeviceId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.Patch == nil {
+		return nil, status.Error(codes.InvalidArgument, errEmptyPatch.Error())
+	}
+
+	var device models.Device
+	err = s.devicesCollection().FindOne(ctx, filter).Decode(&device)
+	if err == mongo.ErrNoDocuments {
+		return nil, status.Errorf(codes.NotFound, "no device found with id: %s", req.DeviceId)
+	}
+	if err != nil {
+		return nil, err
+	}
+	set, err := statePatch(device.Type, req.Patch)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := checkOnline(&device); err != nil {
+		return nil, err
+	}
+
+	err = s.dispatch(ctx, driver.Command{
+		Device:     driverDevice(&device),
+		Status:     req.Patch.GetStatus(),
+		Attributes: patchAttributes(req.Patch),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the patch was checked for this type, so it only lands on a device
+	// that still has it
+	filter["type"] = device.Type
+	var state *controlrpc.DeviceState
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		err := s.devicesCollection().FindOneAndUpdate(sessCtx, filter, bson.M{"$set": set},
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&device)
+		if err == mongo.ErrNoDocuments {
+			return status.Errorf(codes.NotFound, "no device found with id: %s", req.DeviceId)
+		}
+		if err != nil {
+			return err
+		}
+
+		state = device.ToState()
+		return s.writeEvent(sessCtx, events.DeviceStateChanged, &models.DeviceStateChange{
+			DeviceId:   req.DeviceId,
+			HouseId:    state.HouseId,
+			Status:     device.Status,
+			Attributes: device.Attributes,
+		})
+	})
+	if err != nil {
+		s.logger.Printf("Error setting device state: %v", err)
+		return nil, err
+	}
+	return state, nil
+}
+
+// statePatch checks a patch against the type of the device it is for and
+// returns the fields it sets
+func statePatch(typeName string, patch *controlrpc.DeviceStatePatch) (bson.M, error) {
+	deviceType, err := devicetypes.Resolve(typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	set := bson.M{}
+	if patch.Status != nil {
+		if !deviceType.Has(devicetypes.CapabilityOnOff) {
+			return nil, fmt.Errorf("a %s can't be switched on or off", deviceType.Name)
+		}
+		if *patch.Status != "on" && *patch.Status != "off" {
+			return nil, fmt.Errorf("status of a %s must be on or off", deviceType.Name)
+		}
+		set["status"] = *patch.Status
+	}
+
+	// checked holds the attribute values the way the registry checks them,
+	// numbers as float64, while set keeps the stored types
+	checked := map[string]interface{}{}
+	if patch.Brightness != nil {
+		checked[devicetypes.AttributeBrightness] = float64(*patch.Brightness)
+		set["attributes."+devicetypes.AttributeBrightness] = *patch.Brightness
+	}
+	if patch.Color != nil {
+		checked[devicetypes.AttributeColor] = *patch.Color
+		set["attributes."+devicetypes.AttributeColor] = *patch.Color
+	}
+	if patch.TargetTemperature != nil {
+		checked[devicetypes.AttributeTargetTemperature] = *patch.TargetTemperature
+		set["attributes."+devicetypes.AttributeTargetTemperature] = *patch.TargetTemperature
+	}
+	if patch.LockState != nil {
+		checked[devicetypes.AttributeLockState] = *patch.LockState
+		set["attributes."+devicetypes.AttributeLockState] = *patch.LockState
+	}
+	if patch.Position != nil {
+		checked[devicetypes.AttributePosition] = float64(*patch.Position)
+		set["attributes."+devicetypes.AttributePosition] = *patch.Position
+	}
+	for name, value := range checked {
+		if err := deviceType.CheckAttribute(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(set) == 0 {
+		return nil, errEmptyPatch
+	}
+	return set, nil
+}
+
+// patchAttributes returns the attributes a patch sets, nil for none
+func patchAttributes(patch *controlrpc.DeviceStatePatch) *models.DeviceAttributes {
+	if patch.Brightness == nil && patch.Color == nil && patch.TargetTemperature == nil && patch.LockState == nil && patch.Position == nil {
+		return nil
+	}
+	return &models.DeviceAttributes{
+		Brightness:        patch.Brightness,
+		Color:             patch.Color,
+		TargetTemperature: patch.TargetTemperature,
+		LockState:         patch.LockState,
+		Position:          patch.Position,
+	}
+}
+
+// dispatch sends a command to a device through the driver. A device that
+// doesn't take it is Unavailable.
+func (s *Storage) dispatch(ctx context.Context, command driver.Command) error {
+	if err := s.driver.Apply(ctx, command); err != nil {
+		s.logger.Printf("Device %s did not take the command: %v", command.Id, err)
+		return status.Errorf(codes.Unavailable, "device %s did not take the command: %s", command.Id, err.Error())
+	}
+	return nil
+}
+
+// driverDevice identifies a stored device to the driver
+func driverDevice(device *models.Device) driver.Device {
+	return driver.Device{Id: device.Id.Hex(), HouseId: device.HouseId, Type: device.Type}
+}
+
+// DeviceStates returns the stored state of every live device, for a driver
+// that has to know the devices up front
+func (s *Storage) DeviceStates(ctx context.Context) ([]driver.State, error) {
+	cursor, err := s.devicesCollection().Find(ctx, bson.M{"deleted": false})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %s", err.Error())
+	}
+	var devices []models.Device
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, fmt.Errorf("failed to decode devices: %s", err.Error())
+	}
+
+	states := make([]driver.State, 0, len(devices))
+	for i := range devices {
+		battery := devices[i].Battery
+		states = append(states, driver.State{
+			Device:     driverDevice(&devices[i]),
+			Status:     devices[i].Status,
+			Attributes: devices[i].Attributes,
+			Battery:    &battery,
+		})
+	}
+	return states, nil
+}
+
+// WatchDevices stores the states the devices report through the driver until
+// ctx is done
+func (s *Storage) WatchDevices(ctx context.Context) error {
+	states, err := s.driver.Subscribe(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to device states: %s", err.Error())
+	}
+	for state := range states {
+		if err := s.checkReport(ctx, &state); err != nil {
+			s.logger.Printf("Ignoring a report of device %s: %v", state.Id, err)
+			continue
+		}
+		if err := s.recordState(ctx, &state); err != nil {
+			s.logger.Printf("Error recording the state of device %s: %v", state.Id, err)
+		}
+	}
+	return nil
+}
+
+// checkReport checks a state a device reported against the type it is
+// stored with, the way a patch from a user is checked, so a device can't
+// store a status or attributes its type doesn't have
+func (s *Storage) checkReport(ctx context.Context, state *driver.State) error {
+	if state.Battery != nil && (*state.Battery < 0 || *state.Battery > 100) {
+		return fmt.Errorf("battery level %d is not between 0 and 100", *state.Battery)
+	}
+	patch := &controlrpc.DeviceStatePatch{}
+	if len(state.Status) > 0 {
+		patch.Status = &state.Status
+	}
+	if state.Attributes != nil {
+		patch.Brightness = state.Attributes.Brightness
+		patch.Color = state.Attributes.Color
+		patch.TargetTemperature = state.Attributes.TargetTemperature
+		patch.LockState = state.Attributes.LockState
+		patch.Position = state.Attributes.Position
+	}
+	if patch.Status == nil && patchAttributes(patch) == nil {
+		return nil
+	}
+
+	filter, err := deviceFilter(state.Id)
+	if err != nil {
+		return err
+	}
+	var device struct {
+		Type string `bson:"type"`
+	}
+	err = s.devicesCollection().FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"type": 1})).Decode(&device)
+	if err == mongo.ErrNoDocuments {
+		return status.Errorf(codes.NotFound, "no device found with id: %s", state.Id)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = statePatch(device.Type, patch)
+	return err
+}
+
+// recordState stores a state a device reported. Any report counts as a
+// heartbeat, unless it says the device went offline. Its battery goes through
+// UpdateBatteryStatus; the rest, when it changed anything, is announced as a
+// device.state_changed event with the full stored state.
+func (s *Storage) recordState(ctx context.Context, state *driver.State) error {
+	online := state.Online == nil || *state.Online
+	seenAt := state.ReportedAt
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+	if err := s.setPresence(ctx, state.Id, online, seenAt.UTC()); err != nil {
+		return err
+	}
+	if !online {
+		return nil
+	}
+
+	if state.Battery != nil {
+		err := s.UpdateBatteryStatus(ctx, &models.BatteryReport{
+			DeviceId: state.Id,
+			Battery:  *state.Battery,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	set := bson.M{}
+	if len(state.Status) > 0 {
+		set["status"] = state.Status
+	}
+	for name, value := range reportedAttributes(state.Attributes) {
+		set["attributes."+name] = value
+	}
+	for name, value := range state.Readings {
+		set["readings."+name] = value
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	filter, err := deviceFilter(state.Id)
+	if err != nil {
+		return err
+	}
+	return s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		result, err := s.devicesCollection().UpdateOne(sessCtx, filter, bson.M{"$set": set})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 0 {
+			return nil
+		}
+		var device models.Device
+		if err := s.devicesCollection().FindOne(sessCtx, filter).Decode(&device); err != nil {
+			return err
+		}
+		return s.writeEvent(sessCtx, events.DeviceStateChanged, &models.DeviceStateChange{
+			DeviceId:   state.Id,
+			HouseId:    device.HouseId,
+			Status:     device.Status,
+			Attributes: device.Attributes,
+			Readings:   state.Readings,
+		})
+	})
+}
+
+// reportedAttributes returns the attributes that are set, by name
+func reportedAttributes(attributes *models.DeviceAttributes) map[string]interface{} {
+	set := map[string]interface{}{}
+	if attributes == nil {
+		return set
+	}
+	if attributes.Brightness != nil {
+		set[devicetypes.AttributeBrightness] = *attributes.Brightness
+	}
+	if attributes.Color != nil {
+		set[devicetypes.AttributeColor] = *attributes.Color
+	}
+	if attributes.TargetTemperature != nil {
+		set[devicetypes.AttributeTargetTemperature] = *attributes.TargetTemperature
+	}
+	if attributes.LockState != nil {
+		set[devicetypes.AttributeLockState] = *attributes.LockState
+	}
+	if attributes.Position != nil {
+		set[devicetypes.AttributePosition] = *attributes.Position
+	}
+	return set
+}
@@ -2,27 +2,32 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"time"
 
 	controlrpc "ruziba3vich/github.com/control/genprotos/controller_submodule"
+	"ruziba3vich/github.com/control/internal/driver"
+	"ruziba3vich/github.com/control/internal/models"
 
+	"github.com/ruziba3vich/shared/events"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type (
 	Storage struct {
 		database *DB
+		driver   driver.DeviceDriver
 		logger   *log.Logger
 	}
 )
 
 func (s *Storage) TurnDeviceOn(ctx context.Context, req *controlrpc.DeviceRequest) (*controlrpc.DeviceResponse, error) {
-	collection := s.database.Client.Database("smart_house").Collection("devices")
-	filter := bson.M{"_id": req.DeviceId}
-	update := bson.M{"$set": bson.M{"status": "on"}}
-
-	_, err := collection.UpdateOne(ctx, filter, update)
-	if err != nil {
+	if err := s.setDeviceStatus(ctx, req, "on"); err != nil {
 		s.logger.Printf("Error turning device on: %v", err)
 		return nil, err
 	}
@@ -34,12 +39,7 @@ func (s *Storage) TurnDeviceOn(ctx context.Context, req *controlrpc.DeviceReques
 }
 
 func (s *Storage) TurnDeviceOff(ctx context.Context, req *controlrpc.DeviceRequest) (*controlrpc.DeviceResponse, error) {
-	collection := s.database.Client.Database("smart_house").Collection("devices")
-	filter := bson.M{"_id": req.DeviceId}
-	update := bson.M{"$set": bson.M{"status": "off"}}
-
-	_, err := collection.UpdateOne(ctx, filter, update)
-	if err != nil {
+	if err := s.setDeviceStatus(ctx, req, "off"); err != nil {
 		s.logger.Printf("Error turning device off: %v", err)
 		return nil, err
 	}
@@ -50,49 +50,282 @@ func (s *Storage) TurnDeviceOff(ctx context.Context, req *controlrpc.DeviceReque
 	}, nil
 }
 
+func (s *Storage) devicesCollection() *mongo.Collection {
+	return s.database.Client.Database("smart_house").Collection("devices")
+}
+
+// deviceFilter matches a live device by the id DEVICES handed out
+func deviceFilter(deviceId string) (bson.M, error) {
+	objectId, err := primitive.ObjectIDFromHex(deviceId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device id: %s", deviceId)
+	}
+	return bson.M{"_id": objectId, "deleted": false}, nil
+}
+
+// DeviceHouse returns the house a live device is placed in, "" for none
+func (s *Storage) DeviceHouse(ctx context.Context, deviceId string) (string, error) {
+	filter, err := deviceFilter(deviceId)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	var device models.Device
+	err = s.devicesCollection().FindOne(ctx, filter).Decode(&device)
+	if err == mongo.ErrNoDocuments {
+		return "", status.Errorf(codes.NotFound, "no device found with id: %s", deviceId)
+	}
+	if err != nil {
+		return "", err
+	}
+	return device.HouseId, nil
+}
+
+// setDeviceStatus switches a device and announces the change. The command
+// goes to the device first, unless it is offline, and is only stored once the
+// device took it. The house in the event is the one the device is placed in,
+// never one the caller names; a device that was never placed is announced to
+// no house.
+func (s *Storage) setDeviceStatus(ctx context.Context, req *controlrpc.DeviceRequest, wanted string) error {
+	filter, err := deviceFilter(req.DeviceId)
+	if err != nil {
+		return err
+	}
+
+	var device models.Device
+	err = s.devicesCollection().FindOne(ctx, filter).Decode(&device)
+	if err == mongo.ErrNoDocuments {
+		return status.Errorf(codes.NotFound, "no device found with id: %s", req.DeviceId)
+	}
+	if err != nil {
+		return err
+	}
+	// only types with the on_off capability can be switched
+	if _, err := statePatch(device.Type, &controlrpc.DeviceStatePatch{Status: &wanted}); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if device.Status == wanted {
+		return nil
+	}
+	if err := checkOnline(&device); err != nil {
+		return err
+	}
+	if err := s.dispatch(ctx, driver.Command{Device: driverDevice(&device), Status: wanted}); err != nil {
+		return err
+	}
+
+	filter["status"] = bson.M{"$ne": wanted}
+	return s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var device struct {
+			HouseId string `bson:"house_id"`
+		}
+		err := s.devicesCollection().FindOneAndUpdate(sessCtx, filter, bson.M{"$set": bson.M{"status": wanted}}).Decode(&device)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.writeEvent(sessCtx, events.DeviceStateChanged, &models.DeviceStateChange{
+			DeviceId: req.DeviceId,
+			HouseId:  device.HouseId,
+			Status:   wanted,
+		})
+	})
+}
+
 func (s *Storage) AddUserToHouse(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.HouseResponse, error) {
-	collection := s.database.Client.Database("smart_house").Collection("users")
-	filter := bson.M{"_id": req.UserId}
-	update := bson.M{"$set": bson.M{"houseId": req.HouseId}}
+	return s.changeMembership(ctx, req, true)
+}
 
-	_, err := collection.UpdateOne(ctx, filter, update)
+func (s *Storage) RemoveUserFromHouse(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.HouseResponse, error) {
+	return s.changeMembership(ctx, req, false)
+}
+
+// checkUser makes sure a live user with the given id is known to the users
+// service
+func (s *Storage) checkUser(ctx context.Context, userId string) error {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid user id: %s", userId)
+	}
+	err = s.database.UsersCollection.FindOne(ctx, bson.M{"_id": objectId, "deleted": false}).Err()
+	if err == mongo.ErrNoDocuments {
+		return status.Errorf(codes.NotFound, "no user found with id: %s", userId)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up user: %s", err.Error())
+	}
+	return nil
+}
+
+// IsAdmin reports whether a live user is an administrator of the users
+// service
+func (s *Storage) IsAdmin(ctx context.Context, userId string) (bool, error) {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, nil
+	}
+	var user struct {
+		Admin bool `bson:"admin"`
+	}
+	err = s.database.UsersCollection.FindOne(ctx, bson.M{"_id": objectId, "deleted": false}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up user: %s", err.Error())
+	}
+	return user.Admin, nil
+}
+
+// changeMembership adds a user to or removes a user from the members of a
+// house and announces it. Nothing is announced when the user already was (or
+// was not) a member. New members are plain members unless another grantable
+// role is asked for; the owner can never be removed.
+func (s *Storage) changeMembership(ctx context.Context, req *controlrpc.UserRequest, add bool) (*controlrpc.HouseResponse, error) {
+	houseId, err := primitive.ObjectIDFromHex(req.HouseId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid house id: %s", req.HouseId)
+	}
+	if len(req.UserId) == 0 {
+		return nil, fmt.Errorf("user id is required")
+	}
+	role := req.Role
+	if add && len(role) == 0 {
+		role = models.RoleMember
+	}
+	if add && !models.ValidRole(role) {
+		return nil, fmt.Errorf("invalid role: %s", role)
+	}
+	if add {
+		if err := s.checkUser(ctx, req.UserId); err != nil {
+			return nil, err
+		}
+	}
+
+	filter := bson.M{"_id": houseId, "members.user_id": bson.M{"$ne": req.UserId}}
+	update := bson.M{"$push": bson.M{"members": models.Member{UserId: req.UserId, Role: role}}}
+	eventType, message := events.HouseMemberAdded, "User added to house"
+	if !add {
+		filter = bson.M{"_id": houseId, "members.user_id": req.UserId, "owner_id": bson.M{"$ne": req.UserId}}
+		update = bson.M{"$pull": bson.M{"members": bson.M{"user_id": req.UserId}}}
+		eventType, message = events.HouseMemberRemoved, "User removed from house"
+	}
+	update["$set"] = bson.M{"updated_at": time.Now().UTC()}
+
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		result, err := s.database.HousesCollection.UpdateOne(sessCtx, filter, update)
+		if err != nil {
+			s.logger.Printf("Error changing house membership: %v", err)
+			return err
+		}
+		if result.MatchedCount == 0 {
+			house, err := s.getHouse(sessCtx, req.HouseId)
+			if err != nil {
+				return err
+			}
+			if !add && house.OwnerId == req.UserId {
+				return fmt.Errorf("the owner of house %s cannot be removed", req.HouseId)
+			}
+			return nil
+		}
+		return s.writeEvent(sessCtx, eventType, &models.HouseMembership{
+			UserId:  req.UserId,
+			HouseId: req.HouseId,
+			Role:    role,
+		})
+	})
 	if err != nil {
-		s.logger.Printf("Error adding user to house: %v", err)
 		return nil, err
 	}
 
 	return &controlrpc.HouseResponse{
 		Status:  "success",
-		Message: "User added to house",
+		Message: message,
 	}, nil
 }
 
-func (s *Storage) RemoveUserFromHouse(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.HouseResponse, error) {
-	collection := s.database.Client.Database("smart_house").Collection("users")
-	filter := bson.M{"_id": req.UserId}
-	update := bson.M{"$unset": bson.M{"houseId": ""}}
+// SetMemberRole changes the role of a member of a house. The owner keeps the
+// owner role.
+func (s *Storage) SetMemberRole(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.HouseResponse, error) {
+	houseId, err := primitive.ObjectIDFromHex(req.HouseId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid house id: %s", req.HouseId)
+	}
+	if !models.ValidRole(req.Role) {
+		return nil, fmt.Errorf("invalid role: %s", req.Role)
+	}
+
+	filter := bson.M{"_id": houseId, "owner_id": bson.M{"$ne": req.UserId}, "members": bson.M{"$elemMatch": bson.M{"user_id": req.UserId, "role": bson.M{"$ne": req.Role}}}}
+	update := bson.M{"$set": bson.M{"members.$.role": req.Role, "updated_at": time.Now().UTC()}}
 
-	_, err := collection.UpdateOne(ctx, filter, update)
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		result, err := s.database.HousesCollection.UpdateOne(sessCtx, filter, update)
+		if err != nil {
+			s.logger.Printf("Error changing member role: %v", err)
+			return err
+		}
+		if result.MatchedCount == 0 {
+			house, err := s.getHouse(sessCtx, req.HouseId)
+			if err != nil {
+				return err
+			}
+			if house.OwnerId == req.UserId {
+				return fmt.Errorf("the role of the owner of house %s cannot change", req.HouseId)
+			}
+			if len(house.RoleOf(req.UserId)) == 0 {
+				return fmt.Errorf("user %s is not a member of house %s", req.UserId, req.HouseId)
+			}
+			return nil
+		}
+		return s.writeEvent(sessCtx, events.HouseMemberRoleChanged, &models.HouseMembership{
+			UserId:  req.UserId,
+			HouseId: req.HouseId,
+			Role:    req.Role,
+		})
+	})
 	if err != nil {
-		s.logger.Printf("Error removing user from house: %v", err)
 		return nil, err
 	}
 
 	return &controlrpc.HouseResponse{
 		Status:  "success",
-		Message: "User removed from house",
+		Message: "Member role changed",
 	}, nil
 }
 
+// GetMemberRole returns the role of a user in a house; the role is empty when
+// the user is not a member or the house does not exist
+func (s *Storage) GetMemberRole(ctx context.Context, req *controlrpc.UserRequest) (*controlrpc.MemberRoleResponse, error) {
+	response := &controlrpc.MemberRoleResponse{HouseId: req.HouseId, UserId: req.UserId}
+	houseId, err := primitive.ObjectIDFromHex(req.HouseId)
+	if err != nil {
+		return response, nil
+	}
+	var house models.House
+	err = s.database.HousesCollection.FindOne(ctx, bson.M{"_id": houseId}).Decode(&house)
+	if err == mongo.ErrNoDocuments {
+		return response, nil
+	}
+	if err != nil {
+		s.logger.Printf("Error getting member role: %v", err)
+		return nil, fmt.Errorf("failed to get member role: %s", err.Error())
+	}
+	response.Role = house.RoleOf(req.UserId)
+	return response, nil
+}
+
 func (s *Storage) GetBatteryStatus(ctx context.Context, req *controlrpc.DeviceRequest) (*controlrpc.BatteryResponse, error) {
-	collection := s.database.Client.Database("smart_house").Collection("devices")
-	filter := bson.M{"_id": req.DeviceId}
+	filter, err := deviceFilter(req.DeviceId)
+	if err != nil {
+		return nil, err
+	}
 
 	var device struct {
 		Battery int `bson:"battery"`
 	}
 
-	err := collection.FindOne(ctx, filter).Decode(&device)
+	err = s.devicesCollection().FindOne(ctx, filter).Decode(&device)
 	if err != nil {
 		s.logger.Printf("Error getting battery status: %v", err)
 		return nil, err
@@ -102,3 +335,30 @@ func (s *Storage) GetBatteryStatus(ctx context.Context, req *controlrpc.DeviceRe
 		Battery: int32(device.Battery),
 	}, nil
 }
+
+func (s *Storage) UpdateBatteryStatus(ctx context.Context, req *models.BatteryReport) error {
+	filter, err := deviceFilter(req.DeviceId)
+	if err != nil {
+		return err
+	}
+	update := bson.M{"$set": bson.M{"battery": req.Battery}}
+
+	filter["battery"] = bson.M{"$ne": req.Battery}
+
+	return s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var device models.Device
+		err := s.devicesCollection().FindOneAndUpdate(sessCtx, filter, update).Decode(&device)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err != nil {
+			s.logger.Printf("Error updating battery status: %v", err)
+			return err
+		}
+		return s.writeEvent(sessCtx, events.DeviceBatteryChanged, &models.BatteryReport{
+			DeviceId: req.DeviceId,
+			HouseId:  device.HouseId,
+			Battery:  req.Battery,
+		})
+	})
+}
@@ -1,12 +1,15 @@
 package grpcapp
 
 import (
+	"context"
 	"log"
 	"net"
 
 	genprotos "github.com/ruziba3vich/devices/genprotos/devices_submodule"
 	"github.com/ruziba3vich/devices/internal/config"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"github.com/ruziba3vich/shared/events"
 )
 
 type (
@@ -27,8 +30,19 @@ func (a *GRPCApp) RUN(cfg *config.Config, logger *log.Logger) error {
 		logger.Printf("ERROR WHILE CREATING A LISTENER %s\n", err.Error())
 		return err
 	}
-	serverRegisterer := grpc.NewServer()
+	serverRegisterer := grpc.NewServer(grpc.UnaryInterceptor(actorInterceptor))
 	genprotos.RegisterDeviceServiceServer(serverRegisterer, a.service)
 	logger.Printf("--- SERVER HAS STARTED TO RUN ON PORT %s\n", cfg.Port)
 	return serverRegisterer.Serve(listener)
 }
+
+// actorInterceptor attributes the events emitted while handling a call to the
+// user the gateway forwarded in the metadata
+func actorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if actor := md.Get(events.ActorHeader); len(actor) > 0 {
+			ctx = events.WithActor(ctx, actor[0])
+		}
+	}
+	return handler(ctx, req)
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"sync"
@@ -10,13 +13,23 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 	grpcapp "github.com/ruziba3vich/devices/app"
 	"github.com/ruziba3vich/devices/internal/config"
+	"github.com/ruziba3vich/devices/internal/migrations"
 	msgbroker "github.com/ruziba3vich/devices/internal/msg-broker"
 	"github.com/ruziba3vich/devices/internal/redisservice"
 	"github.com/ruziba3vich/devices/internal/service"
 	"github.com/ruziba3vich/devices/internal/storage"
+	"github.com/ruziba3vich/shared/migrate"
+	"github.com/ruziba3vich/shared/outbox"
 )
 
 func main() {
+	dlqList := flag.String("dlq-list", "", "list the dead-lettered messages of the given queue and exit")
+	dlqReplay := flag.String("dlq-replay", "", "move the dead-lettered messages of the given queue back into it and exit")
+	dlqLimit := flag.Int("dlq-limit", 100, "maximum number of dead-lettered messages to list or replay")
+	migrateOnly := flag.Bool("migrate", false, "apply the pending database migrations and exit; they are also applied on startup")
+	dryRun := flag.Bool("dry-run", false, "only list the pending migrations instead of applying them, and exit")
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	cfg, err := config.LoadConfig()
@@ -31,12 +44,21 @@ func main() {
 		logger.Fatal(err)
 	}
 
+	migrator := migrate.New(db.Client.Database("smart_house").Collection(migrate.CollectionName), migrations.All(db), logger)
+	if err := runMigrations(ctx, migrator, logger, *dryRun); err != nil {
+		logger.Fatal(err)
+	}
+	if *migrateOnly || *dryRun {
+		return
+	}
+
 	redisService := redisservice.New(redis.NewClient(&redis.Options{
 		Addr: cfg.GetRedisURI(),
 		DB:   0,
-	}), logger)
+	}), cfg.CacheTTL, logger)
 
-	service := service.New(storage.NewStorage(db, logger), redisService, logger)
+	storage := storage.NewStorage(db, logger)
+	service := service.New(storage, redisService, logger)
 
 	conn, err := amqp.Dial(cfg.GetRabbitMqURI())
 	if err != nil {
@@ -50,9 +72,14 @@ func main() {
 	}
 	defer ch.Close()
 
+	if len(*dlqList) > 0 || len(*dlqReplay) > 0 {
+		runDeadLetterCommand(ctx, ch, logger, *dlqList, *dlqReplay, *dlqLimit)
+		return
+	}
+
 	grpcserver := grpcapp.New(service)
 
-	regQueue, err := getQueue(ch, "create")
+	regQueue, err := getQueue(ch, "device_create")
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -61,7 +88,7 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	updQueue, err := getQueue(ch, "update")
+	updQueue, err := getQueue(ch, "device_update")
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -70,7 +97,7 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	delQueue, err := getQueue(ch, "delete")
+	delQueue, err := getQueue(ch, "device_delete")
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -79,7 +106,48 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	msgBroker := msgbroker.New(service, ch, logger, regMsgs, updMsgs, delMsgs, &sync.WaitGroup{}, 3)
+	policy := msgbroker.RetryPolicy{
+		MaxAttempts: cfg.RetryConfig.MaxAttempts,
+		BaseDelay:   cfg.RetryConfig.BaseDelay,
+		MaxDelay:    cfg.RetryConfig.MaxDelay,
+	}
+	for _, q := range []amqp.Queue{regQueue, updQueue, delQueue} {
+		if err := msgbroker.DeclareRetryTopology(ch, q.Name, policy); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	relayCh, err := conn.Channel()
+	if err != nil {
+		logger.Fatalf("Failed to open a channel: %v", err)
+	}
+	defer relayCh.Close()
+
+	relay, err := outbox.NewRelay(db.OutboxCollection, relayCh, cfg.OutboxConfig.Exchange, cfg.OutboxConfig.PollInterval, cfg.OutboxConfig.BatchSize, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	relayCtx, stopRelay := context.WithCancel(ctx)
+	defer stopRelay()
+	go relay.Run(relayCtx)
+
+	invalidateCh, err := conn.Channel()
+	if err != nil {
+		logger.Fatalf("Failed to open a channel: %v", err)
+	}
+	defer invalidateCh.Close()
+
+	invalidator, err := msgbroker.NewCacheInvalidator(invalidateCh, cfg.OutboxConfig.Exchange, redisService, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	go func() {
+		if err := invalidator.Run(relayCtx); err != nil && err != context.Canceled {
+			logger.Println(err)
+		}
+	}()
+
+	msgBroker := msgbroker.New(service, storage, ch, logger, regMsgs, updMsgs, delMsgs, &sync.WaitGroup{}, 3, policy)
 
 	go func() {
 		logger.Fatal(grpcserver.RUN(cfg, logger))
@@ -88,6 +156,47 @@ func main() {
 	msgBroker.StartToConsume(ctx, "application/json")
 }
 
+// runMigrations applies the pending migrations, or with dryRun lists them
+func runMigrations(ctx context.Context, migrator *migrate.Migrator, logger *log.Logger, dryRun bool) error {
+	applied, err := migrator.Run(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		for _, migration := range applied {
+			logger.Printf("Pending migration %d: %s\n", migration.Version, migration.Description)
+		}
+		logger.Printf("%d pending migrations\n", len(applied))
+	} else if len(applied) > 0 {
+		logger.Printf("Applied %d migrations\n", len(applied))
+	}
+	return nil
+}
+
+// runDeadLetterCommand lists or replays the dead-lettered messages of a queue
+func runDeadLetterCommand(ctx context.Context, ch *amqp.Channel, logger *log.Logger, listQueue, replayQueue string, limit int) {
+	if len(listQueue) > 0 {
+		letters, err := msgbroker.ListDeadLetters(ch, listQueue, limit)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, letter := range letters {
+			data, err := json.Marshal(letter)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			fmt.Println(string(data))
+		}
+	}
+	if len(replayQueue) > 0 {
+		replayed, err := msgbroker.ReplayDeadLetters(ctx, ch, replayQueue, limit)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Replayed %d dead-lettered messages into %s\n", replayed, replayQueue)
+	}
+}
+
 func getQueue(ch *amqp.Channel, queueName string) (amqp.Queue, error) {
 	return ch.QueueDeclare(
 		queueName, // name
@@ -3,24 +3,48 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
-// DbConfig holds the database configuration
+// DbConfig holds the database configuration. RoomsDB is the database of the
+// controller, whose houses and rooms devices are placed in; UsersDB is the
+// one of the users service, whose administrators manage devices outside any
+// house
 type DbConfig struct {
 	MongoURI   string
 	MongoDB    string
 	Collection string
+	RoomsDB    string
+	UsersDB    string
+}
+
+// RetryConfig holds the retry policy of the message consumers
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// OutboxConfig holds the settings of the outbox relay
+type OutboxConfig struct {
+	Exchange     string
+	PollInterval time.Duration
+	BatchSize    int
 }
 
 // Config holds the application configuration
 type Config struct {
-	DbConfig    DbConfig
-	Port        string
-	Protocol    string
-	redisUri    string
-	rabbitMqUri string
+	DbConfig     DbConfig
+	RetryConfig  RetryConfig
+	OutboxConfig OutboxConfig
+	CacheTTL     time.Duration
+	Port         string
+	Protocol     string
+	redisUri     string
+	rabbitMqUri  string
 }
 
 // LoadConfig reads configuration from environment variables or .env file
@@ -34,7 +58,20 @@ func LoadConfig() (*Config, error) {
 			MongoURI:   getEnv("MONGO_URI", "mongodb://localhost:27017"),
 			MongoDB:    getEnv("MONGO_DB", "test"),
 			Collection: getEnv("MONGO_COLLECTION", "users"),
+			RoomsDB:    getEnv("ROOMS_DB", "control_db"),
+			UsersDB:    getEnv("USERS_DB", "users_db"),
 		},
+		RetryConfig: RetryConfig{
+			MaxAttempts: getEnvInt("RETRY_MAX_ATTEMPTS", 3),
+			BaseDelay:   getEnvDuration("RETRY_BASE_DELAY", time.Second),
+			MaxDelay:    getEnvDuration("RETRY_MAX_DELAY", time.Minute),
+		},
+		OutboxConfig: OutboxConfig{
+			Exchange:     getEnv("OUTBOX_EXCHANGE", "smart_house.events"),
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		},
+		CacheTTL:    getEnvDuration("CACHE_TTL", time.Minute),
 		Port:        getEnv("PORT", "8080"),
 		Protocol:    getEnv("PROTOCOL", "tcp"),
 		redisUri:    getEnv("REDIS_URI", "redis:6379"),
@@ -50,6 +87,28 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+// Helper function to get an integer environment variable with a fallback value
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %d\n", key, fallback)
+	}
+	return fallback
+}
+
+// Helper function to get a duration environment variable with a fallback value
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %s\n", key, fallback)
+	}
+	return fallback
+}
+
 func (c *Config) GetRedisURI() string {
 	return c.redisUri
 }
@@ -0,0 +1,146 @@
+package migrations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ruziba3vich/devices/internal/storage"
+	"github.com/ruziba3vich/shared/devicetypes"
+	"github.com/ruziba3vich/shared/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All returns the migrations of the devices database. New migrations are
+// appended with the next version; applied ones must not change.
+func All(db *storage.DB) []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version:     1,
+			Description: "mark devices without a deleted flag as active",
+			Up:          migrate.Backfill(db.DevicesCollection, "deleted", false),
+		},
+		{
+			Version:     2,
+			Description: "index devices by house, room, type and status",
+			Up: migrate.CreateIndexes(db.DevicesCollection,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "deleted", Value: 1}, {Key: "house_id", Value: 1}, {Key: "room_id", Value: 1}},
+					Options: options.Index().SetName("devices_deleted_house_room"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "deleted", Value: 1}, {Key: "house_id", Value: 1}, {Key: "type", Value: 1}},
+					Options: options.Index().SetName("devices_deleted_house_type"),
+				},
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "deleted", Value: 1}, {Key: "house_id", Value: 1}, {Key: "status", Value: 1}},
+					Options: options.Index().SetName("devices_deleted_house_status"),
+				},
+			),
+		},
+		{
+			Version:     3,
+			Description: "text index for device search",
+			Up: migrate.CreateIndexes(db.DevicesCollection, mongo.IndexModel{
+				Keys:    storage.DeviceSearchIndex,
+				Options: options.Index().SetName("devices_search"),
+			}),
+		},
+		{
+			Version:     4,
+			Description: "index unsent outbox rows",
+			Up: migrate.CreateIndexes(db.OutboxCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "sent_at", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("outbox_unsent"),
+			}),
+		},
+		{
+			Version:     5,
+			Description: "index devices by presence and last heard from",
+			Up: migrate.CreateIndexes(db.DevicesCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "deleted", Value: 1}, {Key: "online", Value: 1}, {Key: "last_seen", Value: 1}},
+				Options: options.Index().SetName("devices_deleted_online_last_seen"),
+			}),
+		},
+		{
+			Version:     6,
+			Description: "map the types of devices created before the type registry to registered ones and flag the others",
+			Up:          registerLegacyTypes(db.DevicesCollection),
+		},
+	}
+}
+
+// legacyTypes maps the free-text types devices were created with before the
+// registry, lowercased, to the registered type they stand for
+var legacyTypes = map[string]string{
+	"bulb":               "light",
+	"lamp":               "light",
+	"lights":             "light",
+	"plug":               "switch",
+	"socket":             "switch",
+	"outlet":             "switch",
+	"relay":              "switch",
+	"heater":             "thermostat",
+	"thermo":             "thermostat",
+	"door lock":          "lock",
+	"smart lock":         "lock",
+	"thermometer":        "sensor",
+	"motion sensor":      "sensor",
+	"temperature sensor": "sensor",
+	"humidity sensor":    "sensor",
+	"cam":                "camera",
+	"security camera":    "camera",
+	"blinds":             "blind",
+	"shutter":            "blind",
+	"curtain":            "blind",
+}
+
+// registerLegacyTypes gives the devices whose type is not registered the
+// registered type it stands for. Those it can't place are flagged with
+// unknown_type for an operator to retype; their updates are refused until
+// then. Statuses the type of a device doesn't allow are cleared, as if the
+// device never reported one.
+func registerLegacyTypes(collection *mongo.Collection) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		types, err := collection.Distinct(ctx, "type", bson.M{})
+		if err != nil {
+			return err
+		}
+		for _, value := range types {
+			legacy, _ := value.(string)
+			if _, ok := devicetypes.Lookup(legacy); ok {
+				continue
+			}
+			name := strings.ToLower(strings.TrimSpace(legacy))
+			if mapped, ok := legacyTypes[name]; ok {
+				name = mapped
+			}
+			update := bson.M{"$set": bson.M{"unknown_type": true}}
+			if _, ok := devicetypes.Lookup(name); ok {
+				update = bson.M{"$set": bson.M{"type": name}}
+			}
+			if _, err := collection.UpdateMany(ctx, bson.M{"type": value}, update); err != nil {
+				return err
+			}
+		}
+		if _, err := collection.UpdateMany(ctx, bson.M{"type": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"unknown_type": true}}); err != nil {
+			return err
+		}
+
+		for _, deviceType := range devicetypes.All() {
+			allowed := []string{""}
+			if deviceType.Has(devicetypes.CapabilityOnOff) {
+				allowed = append(allowed, "on", "off")
+			}
+			_, err := collection.UpdateMany(ctx,
+				bson.M{"type": deviceType.Name, "status": bson.M{"$nin": allowed}},
+				bson.M{"$set": bson.M{"status": ""}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
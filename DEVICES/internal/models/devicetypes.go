@@ -0,0 +1,73 @@
+package models
+
+import (
+	genprotos "github.com/ruziba3vich/devices/genprotos/devices_submodule"
+	"github.com/ruziba3vich/shared/devicetypes"
+)
+
+// ValidateDevice checks a device against the registry: its type has to be known,
+// an on_off device can only be on or off, and every attribute it carries has
+// to be one of its type's, with a value the attribute allows
+func ValidateDevice(device *genprotos.Device) error {
+	deviceType, err := devicetypes.Resolve(device.Type)
+	if err != nil {
+		return err
+	}
+	if err := deviceType.CheckStatus(device.Status); err != nil {
+		return err
+	}
+
+	for name, value := range setAttributes(device.Attributes) {
+		if err := deviceType.CheckAttribute(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setAttributes returns the attributes that are set, numbers as float64
+func setAttributes(attributes *genprotos.DeviceAttributes) map[string]interface{} {
+	set := map[string]interface{}{}
+	if attributes == nil {
+		return set
+	}
+	if attributes.Brightness != nil {
+		set[devicetypes.AttributeBrightness] = float64(*attributes.Brightness)
+	}
+	if attributes.Color != nil {
+		set[devicetypes.AttributeColor] = *attributes.Color
+	}
+	if attributes.TargetTemperature != nil {
+		set[devicetypes.AttributeTargetTemperature] = *attributes.TargetTemperature
+	}
+	if attributes.LockState != nil {
+		set[devicetypes.AttributeLockState] = *attributes.LockState
+	}
+	if attributes.Position != nil {
+		set[devicetypes.AttributePosition] = float64(*attributes.Position)
+	}
+	return set
+}
+
+// ToProtoDeviceType returns a device type as it is listed in the catalog
+func ToProtoDeviceType(t *devicetypes.DeviceType) *genprotos.DeviceType {
+	deviceType := genprotos.DeviceType{
+		Name:         t.Name,
+		Description:  t.Description,
+		Capabilities: t.Capabilities,
+	}
+	for _, attribute := range t.Attributes {
+		protoAttribute := genprotos.DeviceTypeAttribute{
+			Name:   attribute.Name,
+			Kind:   attribute.Kind,
+			Values: attribute.Values,
+			Unit:   attribute.Unit,
+		}
+		if attribute.Kind == devicetypes.KindInt || attribute.Kind == devicetypes.KindFloat {
+			lower, upper := attribute.Min, attribute.Max
+			protoAttribute.Min, protoAttribute.Max = &lower, &upper
+		}
+		deviceType.Attributes = append(deviceType.Attributes, &protoAttribute)
+	}
+	return &deviceType
+}
@@ -1,18 +1,39 @@
 package models
 
 import (
+	"encoding/json"
+	"time"
+
 	genprotos "github.com/ruziba3vich/devices/genprotos/devices_submodule"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type (
 	Device struct {
-		Id       primitive.ObjectID `bson:"_id" json:"id"`
-		Name     string             `bson:"name" json:"name"`
-		Type     string             `bson:"type" json:"type"`
-		Status   string             `bson:"status" json:"status"`
-		Location string             `bson:"location" json:"location"`
-		Deleted  bool               `bson:"deleted" json:"deleted"`
+		Id         primitive.ObjectID `bson:"_id" json:"id"`
+		Name       string             `bson:"name" json:"name"`
+		Type       string             `bson:"type" json:"type"`
+		Status     string             `bson:"status" json:"status"`
+		Location   string             `bson:"location,omitempty" json:"location"` // free text kept until migrated to a room
+		HouseId    string             `bson:"house_id" json:"house_id"`
+		RoomId     string             `bson:"room_id" json:"room_id"`
+		Attributes *Attributes        `bson:"attributes,omitempty" json:"attributes,omitempty"`
+		Deleted    bool               `bson:"deleted" json:"deleted"`
+		// Online and LastSeen are kept by the controller as it hears from
+		// the device; both are unset for a device it never heard from
+		Online   *bool      `bson:"online,omitempty" json:"online,omitempty"`
+		LastSeen *time.Time `bson:"last_seen,omitempty" json:"last_seen,omitempty"`
+	}
+
+	// Attributes are the typed settings of a device; which of them it can
+	// have depends on its type
+	Attributes struct {
+		Brightness        *int32   `bson:"brightness,omitempty" json:"brightness,omitempty"`
+		TargetTemperature *float64 `bson:"target_temperature,omitempty" json:"target_temperature,omitempty"`
+		LockState         *string  `bson:"lock_state,omitempty" json:"lock_state,omitempty"`
+		Position          *int32   `bson:"position,omitempty" json:"position,omitempty"`
+		Color             *string  `bson:"color,omitempty" json:"color,omitempty"`
 	}
 
 	CreateDeviceRequest struct {
@@ -20,6 +41,8 @@ type (
 		Type     string `json:"type"`
 		Status   string `json:"status"`
 		Location string `json:"location"`
+		HouseId  string `json:"house_id"`
+		RoomId   string `json:"room_id"`
 	}
 
 	UpdateDeviceRequest struct {
@@ -28,21 +51,69 @@ type (
 		Type     string `json:"type"`
 		Status   string `json:"status"`
 		Location string `json:"location"`
+		HouseId  string `json:"house_id"`
+		RoomId   string `json:"room_id"`
 	}
 
 	DeleteDeviceRequest struct {
 		DeviceId string `json:"device_id"`
 	}
+
+	// Reply is published to the ReplyTo queue of a consumed command so the
+	// caller learns how it ended.
+	Reply struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Error   string          `json:"error,omitempty"`
+		Data    json.RawMessage `json:"data,omitempty"`
+	}
+)
+
+// Presence of a device
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+	PresenceUnknown = "unknown"
+)
+
+const (
+	ReplyStatusSuccess    = "success"
+	ReplyStatusError      = "error"
+	ReplyStatusBadRequest = "bad_request"
+	ReplyStatusForbidden  = "forbidden"
 )
 
 func (d *Device) ToProtoDevice() *genprotos.Device {
 	return &genprotos.Device{
-		Id:       d.Id.Hex(),
-		Name:     d.Name,
-		Type:     d.Type,
-		Status:   d.Status,
-		Location: d.Location,
+		Id:         d.Id.Hex(),
+		Name:       d.Name,
+		Type:       d.Type,
+		Status:     d.Status,
+		Location:   d.Location,
+		HouseId:    d.HouseId,
+		RoomId:     d.RoomId,
+		Attributes: d.Attributes.ToProto(),
+		Presence:   d.Presence(),
+		LastSeen:   d.lastSeen(),
+	}
+}
+
+// Presence tells whether the device is online, offline or was never heard from
+func (d *Device) Presence() string {
+	switch {
+	case d.Online == nil:
+		return PresenceUnknown
+	case *d.Online:
+		return PresenceOnline
+	}
+	return PresenceOffline
+}
+
+func (d *Device) lastSeen() string {
+	if d.LastSeen == nil {
+		return ""
 	}
+	return d.LastSeen.UTC().Format(time.RFC3339)
 }
 
 func (d *Device) FromProto(data *genprotos.Device) {
@@ -51,15 +122,21 @@ func (d *Device) FromProto(data *genprotos.Device) {
 	d.Type = data.Type
 	d.Status = data.Status
 	d.Location = data.Location
+	d.HouseId = data.HouseId
+	d.RoomId = data.RoomId
+	d.Attributes = AttributesFromProto(data.Attributes)
 }
 
 func (d *Device) ToCreateDeviceRequest() *genprotos.CreateDeviceRequest {
 	return &genprotos.CreateDeviceRequest{
 		Device: &genprotos.Device{
-			Name:     d.Name,
-			Type:     d.Type,
-			Status:   d.Status,
-			Location: d.Location,
+			Name:       d.Name,
+			Type:       d.Type,
+			Status:     d.Status,
+			Location:   d.Location,
+			HouseId:    d.HouseId,
+			RoomId:     d.RoomId,
+			Attributes: d.Attributes.ToProto(),
 		},
 	}
 }
@@ -67,10 +144,14 @@ func (d *Device) ToCreateDeviceRequest() *genprotos.CreateDeviceRequest {
 func (d *Device) ToUpdateDeviceRequest() *genprotos.UpdateDeviceRequest {
 	return &genprotos.UpdateDeviceRequest{
 		Device: &genprotos.Device{
-			Name:     d.Name,
-			Type:     d.Type,
-			Status:   d.Status,
-			Location: d.Location,
+			Id:         d.Id.Hex(),
+			Name:       d.Name,
+			Type:       d.Type,
+			Status:     d.Status,
+			Location:   d.Location,
+			HouseId:    d.HouseId,
+			RoomId:     d.RoomId,
+			Attributes: d.Attributes.ToProto(),
 		},
 	}
 }
@@ -80,6 +161,9 @@ func (d *Device) FromCreateDeviceRequest(data *genprotos.CreateDeviceRequest) {
 	d.Type = data.Device.Type
 	d.Status = data.Device.Status
 	d.Location = data.Device.Location
+	d.HouseId = data.Device.HouseId
+	d.RoomId = data.Device.RoomId
+	d.Attributes = AttributesFromProto(data.Device.Attributes)
 }
 
 func (d *Device) FromUpdateDeviceRequest(data *genprotos.UpdateDeviceRequest) {
@@ -88,4 +172,101 @@ func (d *Device) FromUpdateDeviceRequest(data *genprotos.UpdateDeviceRequest) {
 	d.Type = data.Device.Type
 	d.Status = data.Device.Status
 	d.Location = data.Device.Location
+	d.HouseId = data.Device.HouseId
+	d.RoomId = data.Device.RoomId
+	d.Attributes = AttributesFromProto(data.Device.Attributes)
+}
+
+// Patch copies the fields data sets onto the device and returns them as the
+// $set of the update storing them; fields data leaves empty are kept. A device
+// moved to another house leaves its room behind, and one changing type keeps
+// only the status and attributes sent along, since the old ones may not fit
+// the new type.
+func (d *Device) Patch(data *genprotos.Device) bson.M {
+	set := bson.M{}
+	if len(data.Name) > 0 && data.Name != d.Name {
+		d.Name = data.Name
+		set["name"] = d.Name
+	}
+	if len(data.Type) > 0 && data.Type != d.Type {
+		d.Type = data.Type
+		d.Status = ""
+		d.Attributes = nil
+		set["type"] = d.Type
+		set["status"] = d.Status
+		set["attributes"] = nil
+	}
+	if len(data.Status) > 0 && data.Status != d.Status {
+		d.Status = data.Status
+		set["status"] = d.Status
+	}
+	if len(data.Location) > 0 && data.Location != d.Location {
+		d.Location = data.Location
+		set["location"] = d.Location
+	}
+	if len(data.HouseId) > 0 && data.HouseId != d.HouseId {
+		d.HouseId = data.HouseId
+		d.RoomId = ""
+		set["house_id"] = d.HouseId
+		set["room_id"] = d.RoomId
+	}
+	if len(data.RoomId) > 0 && data.RoomId != d.RoomId {
+		d.RoomId = data.RoomId
+		set["room_id"] = d.RoomId
+	}
+	if attributes := AttributesFromProto(data.Attributes); attributes != nil {
+		if d.Attributes == nil {
+			d.Attributes = &Attributes{}
+		}
+		d.Attributes.Merge(attributes)
+		set["attributes"] = d.Attributes
+	}
+	return set
+}
+
+// Merge copies the attributes that are set in other
+func (a *Attributes) Merge(other *Attributes) {
+	if other.Brightness != nil {
+		a.Brightness = other.Brightness
+	}
+	if other.TargetTemperature != nil {
+		a.TargetTemperature = other.TargetTemperature
+	}
+	if other.LockState != nil {
+		a.LockState = other.LockState
+	}
+	if other.Position != nil {
+		a.Position = other.Position
+	}
+	if other.Color != nil {
+		a.Color = other.Color
+	}
+}
+
+func (a *Attributes) ToProto() *genprotos.DeviceAttributes {
+	if a == nil {
+		return nil
+	}
+	return &genprotos.DeviceAttributes{
+		Brightness:        a.Brightness,
+		TargetTemperature: a.TargetTemperature,
+		LockState:         a.LockState,
+		Position:          a.Position,
+		Color:             a.Color,
+	}
+}
+
+// AttributesFromProto converts the attributes of a device; none set gives nil,
+// so devices without attributes store none
+func AttributesFromProto(data *genprotos.DeviceAttributes) *Attributes {
+	if data == nil || (data.Brightness == nil && data.TargetTemperature == nil && data.LockState == nil && data.Position == nil && data.Color == nil) {
+		return nil
+	}
+	return &Attributes{
+		Brightness:        data.Brightness,
+		TargetTemperature: data.TargetTemperature,
+		LockState:         data.LockState,
+		Position:          data.Position,
+		Color:             data.Color,
+	}
 }
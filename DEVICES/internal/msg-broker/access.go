@@ -0,0 +1,62 @@
+package msgbroker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ruziba3vich/devices/internal/models"
+	"github.com/ruziba3vich/shared/access"
+	"github.com/ruziba3vich/shared/events"
+)
+
+// authorizeCreation checks that the actor of a command may add a device to
+// the house it names
+func (m *MsgBroker) authorizeCreation(ctx context.Context, device *models.Device) error {
+	return m.authorize(ctx, access.ActionManageDevices, device.HouseId)
+}
+
+// authorizeUpdate checks that the actor of a command may change a device,
+// and may move it to the house it names if that is another one
+func (m *MsgBroker) authorizeUpdate(ctx context.Context, device *models.Device) error {
+	houseId, err := m.storage.HouseOf(ctx, device.Id.Hex())
+	if err != nil {
+		return err
+	}
+	if err := m.authorize(ctx, access.ActionManageDevices, houseId); err != nil {
+		return err
+	}
+	if len(device.HouseId) == 0 || device.HouseId == houseId {
+		return nil
+	}
+	return m.authorize(ctx, access.ActionManageDevices, device.HouseId)
+}
+
+// authorizeDeletion checks that the actor of a command may delete a device
+func (m *MsgBroker) authorizeDeletion(ctx context.Context, deviceId string) error {
+	houseId, err := m.storage.HouseOf(ctx, deviceId)
+	if err != nil {
+		return err
+	}
+	return m.authorize(ctx, access.ActionDeleteDevice, houseId)
+}
+
+// authorize checks the role of the actor of a command in a house. As on the
+// gateway, only administrators manage devices outside any house.
+func (m *MsgBroker) authorize(ctx context.Context, action access.Action, houseId string) error {
+	actor := events.ActorFromContext(ctx)
+	if len(houseId) == 0 {
+		admin, err := m.storage.IsAdmin(ctx, actor)
+		if err != nil {
+			return err
+		}
+		if !admin {
+			return fmt.Errorf("%w: the device does not belong to a house", access.ErrForbidden)
+		}
+		return nil
+	}
+	role, err := m.storage.RoleIn(ctx, actor, houseId)
+	if err != nil {
+		return err
+	}
+	return access.Check(role, action)
+}
@@ -0,0 +1,98 @@
+package msgbroker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/devices/internal/redisservice"
+	"github.com/ruziba3vich/shared/events"
+)
+
+type (
+	// CacheInvalidator drops the cached copy of a device whenever the
+	// controller changes its state, battery or presence, so GetDevice doesn't
+	// answer with what a device was before. Its queue lives only as long as
+	// the instance, so the events published while it is down are lost; the
+	// short TTL of the cache bounds how long they leave a stale copy.
+	CacheInvalidator struct {
+		channel *amqp.Channel
+		queue   amqp.Queue
+		redis   *redisservice.RedisService
+		logger  *log.Logger
+	}
+
+	// deviceEvent is the part of a device event the invalidator reads
+	deviceEvent struct {
+		Type    string `json:"type"`
+		Payload struct {
+			DeviceId string `json:"device_id"`
+		} `json:"payload"`
+	}
+)
+
+// NewCacheInvalidator binds a private queue of this instance to the device
+// events on the given exchange.
+func NewCacheInvalidator(channel *amqp.Channel, exchange string, redis *redisservice.RedisService, logger *log.Logger) (*CacheInvalidator, error) {
+	if err := channel.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare exchange %s: %s", exchange, err.Error())
+	}
+	queue, err := channel.QueueDeclare(
+		"",    // name, let the server generate one
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare cache invalidation queue: %s", err.Error())
+	}
+	for _, key := range []string{events.DeviceStateChanged, events.DeviceBatteryChanged, events.DevicePresenceChanged} {
+		if err := channel.QueueBind(queue.Name, key, exchange, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to bind cache invalidation queue: %s", err.Error())
+		}
+	}
+	return &CacheInvalidator{
+		channel: channel,
+		queue:   queue,
+		redis:   redis,
+		logger:  logger,
+	}, nil
+}
+
+// Run drops the devices named by the consumed events until ctx is done
+func (c *CacheInvalidator) Run(ctx context.Context) error {
+	deliveries, err := c.channel.Consume(
+		c.queue.Name, // queue
+		"",           // consumer
+		true,         // auto-ack
+		true,         // exclusive
+		false,        // no-local
+		false,        // no-wait
+		nil,          // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to consume cache invalidation queue: %s", err.Error())
+	}
+	for {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("cache invalidation delivery channel closed")
+			}
+			var event deviceEvent
+			if err := json.Unmarshal(d.Body, &event); err != nil || len(event.Payload.DeviceId) == 0 {
+				c.logger.Printf("DROPPING UNREADABLE EVENT: %s\n", d.RoutingKey)
+				continue
+			}
+			if err := c.redis.DeleteDeviceFromRedis(ctx, event.Payload.DeviceId); err != nil {
+				c.logger.Printf("FAILED TO INVALIDATE DEVICE %s AFTER %s: %s\n", event.Payload.DeviceId, event.Type, err.Error())
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
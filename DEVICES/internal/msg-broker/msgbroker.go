@@ -3,6 +3,8 @@ package msgbroker
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -12,12 +14,18 @@ import (
 	amqp "github.com/rabbitmq/amqp091-go"
 	genprotos "github.com/ruziba3vich/devices/genprotos/devices_submodule"
 	"github.com/ruziba3vich/devices/internal/models"
+	"github.com/ruziba3vich/devices/internal/storage"
+	"github.com/ruziba3vich/shared/access"
+	"github.com/ruziba3vich/shared/events"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 )
 
 type (
 	MsgBroker struct {
 		service          genprotos.DeviceServiceServer
+		storage          *storage.Storage
 		channel          *amqp.Channel
 		deviceCreations  <-chan amqp.Delivery
 		deviceUpdates    <-chan amqp.Delivery
@@ -25,19 +33,23 @@ type (
 		logger           *log.Logger
 		wg               *sync.WaitGroup
 		numberOfServices int
+		policy           RetryPolicy
 	}
 )
 
 func New(service genprotos.DeviceServiceServer,
+	storage *storage.Storage,
 	channel *amqp.Channel,
 	logger *log.Logger,
 	deviceCreations <-chan amqp.Delivery,
 	deviceUpdates <-chan amqp.Delivery,
 	deviceDeletions <-chan amqp.Delivery,
 	wg *sync.WaitGroup,
-	numberOfServices int) *MsgBroker {
+	numberOfServices int,
+	policy RetryPolicy) *MsgBroker {
 	return &MsgBroker{
 		service:          service,
+		storage:          storage,
 		channel:          channel,
 		deviceCreations:  deviceCreations,
 		deviceUpdates:    deviceUpdates,
@@ -45,6 +57,7 @@ func New(service genprotos.DeviceServiceServer,
 		logger:           logger,
 		wg:               wg,
 		numberOfServices: numberOfServices,
+		policy:           policy,
 	}
 }
 
@@ -53,9 +66,9 @@ func (m *MsgBroker) StartToConsume(ctx context.Context, contentType string) {
 	consumerCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	go m.consumeMessages(consumerCtx, m.deviceCreations, m.service.CreateDevice, "creation")
-	go m.consumeMessages(consumerCtx, m.deviceUpdates, m.service.UpdateDevice, "update")
-	go m.consumeMessages(consumerCtx, m.deviceDeletions, m.service.DeleteDevice, "deletion")
+	go m.consumeMessages(consumerCtx, m.deviceCreations, m.service.CreateDevice, "creation", contentType)
+	go m.consumeMessages(consumerCtx, m.deviceUpdates, m.service.UpdateDevice, "update", contentType)
+	go m.consumeMessages(consumerCtx, m.deviceDeletions, m.service.DeleteDevice, "deletion", contentType)
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -67,63 +80,143 @@ func (m *MsgBroker) StartToConsume(ctx context.Context, contentType string) {
 	m.logger.Println("All consumers have stopped")
 }
 
-func (m *MsgBroker) consumeMessages(ctx context.Context, messages <-chan amqp.Delivery, serviceFunc interface{}, logPrefix string) {
+func (m *MsgBroker) consumeMessages(ctx context.Context, messages <-chan amqp.Delivery, serviceFunc interface{}, logPrefix string, contentType string) {
 	defer m.wg.Done()
 	for {
 		select {
-		case val := <-messages:
+		case val, ok := <-messages:
+			if !ok {
+				m.logger.Printf("Delivery channel closed, stopping %s consumer", logPrefix)
+				return
+			}
 			var request interface{}
 			var response proto.Message
 			var err error
 
+			msgCtx := ctx
+			if actor, ok := val.Headers[events.ActorHeader].(string); ok {
+				msgCtx = events.WithActor(ctx, actor)
+			}
+
 			switch logPrefix {
 			case "creation":
 				var req models.Device
 				if err := json.Unmarshal(val.Body, &req); err != nil {
 					m.logger.Printf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())
-					val.Nack(false, false)
+					m.handleFailure(ctx, val, contentType, &models.Reply{
+						Status:  models.ReplyStatusBadRequest,
+						Message: fmt.Sprintf("invalid %s payload", logPrefix),
+						Error:   err.Error(),
+					}, false)
 					continue
 				}
+				if err = m.authorizeCreation(msgCtx, &req); err != nil {
+					break
+				}
 				request = req.ToCreateDeviceRequest()
-				response, err = serviceFunc.(func(context.Context, *genprotos.CreateDeviceRequest) (*genprotos.CreateDeviceResponse, error))(ctx, request.(*genprotos.CreateDeviceRequest))
+				response, err = serviceFunc.(func(context.Context, *genprotos.CreateDeviceRequest) (*genprotos.CreateDeviceResponse, error))(msgCtx, request.(*genprotos.CreateDeviceRequest))
 			case "update":
 				var req models.Device
 				if err := json.Unmarshal(val.Body, &req); err != nil {
 					m.logger.Printf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())
-					val.Nack(false, false)
+					m.handleFailure(ctx, val, contentType, &models.Reply{
+						Status:  models.ReplyStatusBadRequest,
+						Message: fmt.Sprintf("invalid %s payload", logPrefix),
+						Error:   err.Error(),
+					}, false)
 					continue
 				}
+				if err = m.authorizeUpdate(msgCtx, &req); err != nil {
+					break
+				}
 				request = req.ToUpdateDeviceRequest()
-				response, err = serviceFunc.(func(context.Context, *genprotos.UpdateDeviceRequest) (*genprotos.UpdateDeviceResponse, error))(ctx, request.(*genprotos.UpdateDeviceRequest))
+				response, err = serviceFunc.(func(context.Context, *genprotos.UpdateDeviceRequest) (*genprotos.UpdateDeviceResponse, error))(msgCtx, request.(*genprotos.UpdateDeviceRequest))
 			case "deletion":
 				var req models.DeleteDeviceRequest
 				if err := json.Unmarshal(val.Body, &req); err != nil {
 					m.logger.Printf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())
-					val.Nack(false, false)
+					m.handleFailure(ctx, val, contentType, &models.Reply{
+						Status:  models.ReplyStatusBadRequest,
+						Message: fmt.Sprintf("invalid %s payload", logPrefix),
+						Error:   err.Error(),
+					}, false)
 					continue
 				}
-				request = &genprotos.GetDeviceRequest{Id: req.DeviceId}
-				response, err = serviceFunc.(func(context.Context, *genprotos.GetDeviceRequest) (*genprotos.DeleteDeviceResponse, error))(ctx, request.(*genprotos.GetDeviceRequest))
+				if err = m.authorizeDeletion(msgCtx, req.DeviceId); err != nil {
+					break
+				}
+				request = &genprotos.DeleteDeviceRequest{Id: req.DeviceId}
+				response, err = serviceFunc.(func(context.Context, *genprotos.DeleteDeviceRequest) (*genprotos.DeleteDeviceResponse, error))(msgCtx, request.(*genprotos.DeleteDeviceRequest))
 			}
 
-			if err != nil {
+			// retrying can't fix a bad request, bring back a missing device or
+			// grant the actor a role
+			if errors.Is(err, access.ErrForbidden) {
+				m.handleFailure(ctx, val, contentType, &models.Reply{
+					Status:  models.ReplyStatusForbidden,
+					Message: fmt.Sprintf("%s failed", logPrefix),
+					Error:   err.Error(),
+				}, false)
+				continue
+			} else if code := status.Code(err); code == codes.InvalidArgument || code == codes.NotFound {
+				m.handleFailure(ctx, val, contentType, &models.Reply{
+					Status:  models.ReplyStatusBadRequest,
+					Message: fmt.Sprintf("%s failed", logPrefix),
+					Error:   status.Convert(err).Message(),
+				}, false)
+				continue
+			} else if err != nil {
 				m.logger.Printf("Failed in %s: %s\n", logPrefix, err.Error())
-				val.Nack(false, false)
+				m.handleFailure(ctx, val, contentType, &models.Reply{
+					Status:  models.ReplyStatusError,
+					Message: fmt.Sprintf("%s failed", logPrefix),
+					Error:   err.Error(),
+				}, true)
 				continue
 			}
 
 			val.Ack(false)
 
-			_, err = proto.Marshal(response)
-			if err != nil {
+			reply := models.Reply{
+				Status:  models.ReplyStatusSuccess,
+				Message: fmt.Sprintf("%s succeeded", logPrefix),
+			}
+			if byteData, err := json.Marshal(response); err != nil {
 				m.logger.Printf("Failed to marshal response: %s\n", err.Error())
-				continue
+			} else {
+				reply.Data = byteData
 			}
-
-			// m.publishMessageBack(val, contentType, byteData)
+			m.publishMessageBack(val, contentType, &reply)
 		case <-ctx.Done():
 			m.logger.Printf("Context done, stopping %s consumer", logPrefix)
 			return
 		}
 	}
 }
+
+// publishMessageBack answers the caller of a command on its ReplyTo queue,
+// echoing the correlation id so the caller can match the reply.
+func (m *MsgBroker) publishMessageBack(val amqp.Delivery, contentType string, reply *models.Reply) {
+	if len(val.ReplyTo) == 0 {
+		return
+	}
+	body, err := json.Marshal(reply)
+	if err != nil {
+		m.logger.Printf("Failed to marshal reply: %s\n", err.Error())
+		return
+	}
+	err = m.channel.Publish(
+		"",          // exchange
+		val.ReplyTo, // routing key
+		false,       // mandatory
+		false,       // immediate
+		amqp.Publishing{
+			ContentType:   contentType,
+			CorrelationId: val.CorrelationId,
+			Body:          body,
+		},
+	)
+	if err != nil {
+		m.logger.Printf("Failed to publish reply to %s: %s\n", val.ReplyTo, err.Error())
+	}
+}
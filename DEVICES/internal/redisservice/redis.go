@@ -13,14 +13,17 @@ import (
 type (
 	RedisService struct {
 		redisDb *redis.Client
+		ttl     time.Duration
 		logger  *log.Logger
 	}
 )
 
-func New(redisDb *redis.Client, logger *log.Logger) *RedisService {
+// New returns a cache of devices whose entries expire after ttl
+func New(redisDb *redis.Client, ttl time.Duration, logger *log.Logger) *RedisService {
 	return &RedisService{
 		logger:  logger,
 		redisDb: redisDb,
+		ttl:     ttl,
 	}
 }
 
@@ -31,7 +34,7 @@ func (r *RedisService) StoreDeviceInRedis(ctx context.Context, device *genprotos
 		return err
 	}
 
-	err = r.redisDb.Set(ctx, device.Id, deviceJSON, time.Hour*24).Err()
+	err = r.redisDb.Set(ctx, device.Id, deviceJSON, r.ttl).Err()
 	if err != nil {
 		r.logger.Printf("ERROR WHILE STORING DATA IN REDIS: %s", err.Error())
 		return err
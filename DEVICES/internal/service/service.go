@@ -5,8 +5,12 @@ import (
 	"log"
 
 	genprotos "github.com/ruziba3vich/devices/genprotos/devices_submodule"
+	"github.com/ruziba3vich/devices/internal/models"
 	"github.com/ruziba3vich/devices/internal/redisservice"
 	"github.com/ruziba3vich/devices/internal/storage"
+	"github.com/ruziba3vich/shared/devicetypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type (
@@ -28,6 +32,9 @@ func New(storage *storage.Storage, redis *redisservice.RedisService, logger *log
 
 func (s *Service) CreateDevice(ctx context.Context, req *genprotos.CreateDeviceRequest) (*genprotos.CreateDeviceResponse, error) {
 	s.logger.Println("-- RECEIVED A REQUEST TO <CreateDevice> SERVICE --")
+	if err := validateDevice(req.Device); err != nil {
+		return nil, err
+	}
 	device, err := s.storage.CreateDevice(ctx, req)
 	var response genprotos.CreateDeviceResponse
 	if err == nil {
@@ -43,6 +50,7 @@ func (s *Service) CreateDevice(ctx context.Context, req *genprotos.CreateDeviceR
 
 func (s *Service) UpdateDevice(ctx context.Context, req *genprotos.UpdateDeviceRequest) (*genprotos.UpdateDeviceResponse, error) {
 	s.logger.Println("-- RECEIVED A REQUEST TO <UpdateDevice> SERVICE --")
+	// a partial update is checked by storage, once merged with the device
 	updatedDevice, err := s.storage.UpdateDevice(ctx, req)
 	var response genprotos.UpdateDeviceResponse
 	if err == nil {
@@ -69,6 +77,10 @@ func (s *Service) GetDevice(ctx context.Context, req *genprotos.GetDeviceRequest
 	if err != nil {
 		return nil, err
 	}
+	// cached until the device changes, see msgbroker.CacheInvalidator
+	if err := s.redis.StoreDeviceInRedis(ctx, device.Device); err != nil {
+		s.logger.Printf("FAILED TO CACHE DEVICE %s: %s", req.Id, err.Error())
+	}
 	return device, nil
 }
 
@@ -93,3 +105,39 @@ func (s *Service) GetAllDevices(ctx context.Context, req *genprotos.GetAllDevice
 	}
 	return devices, nil
 }
+
+func (s *Service) GetDevicesByRoom(ctx context.Context, req *genprotos.GetDevicesByRoomRequest) (*genprotos.GetAllDevicesResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetDevicesByRoom> SERVICE --")
+	return s.storage.GetDevicesByRoom(ctx, req)
+}
+
+func (s *Service) GetDevicesByHouse(ctx context.Context, req *genprotos.GetDevicesByHouseRequest) (*genprotos.GetAllDevicesResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <GetDevicesByHouse> SERVICE --")
+	return s.storage.GetDevicesByHouse(ctx, req)
+}
+
+func (s *Service) Search(ctx context.Context, req *genprotos.SearchDevicesRequest) (*genprotos.GetAllDevicesResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <Search> SERVICE --")
+	return s.storage.Search(ctx, req)
+}
+
+// ListDeviceTypes returns the catalog of the device types
+func (s *Service) ListDeviceTypes(ctx context.Context, req *genprotos.ListDeviceTypesRequest) (*genprotos.ListDeviceTypesResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST TO <ListDeviceTypes> SERVICE --")
+	var response genprotos.ListDeviceTypesResponse
+	for _, deviceType := range devicetypes.All() {
+		response.Types = append(response.Types, models.ToProtoDeviceType(deviceType))
+	}
+	return &response, nil
+}
+
+// validateDevice checks a created device against the device type registry
+func validateDevice(device *genprotos.Device) error {
+	if device == nil {
+		return status.Error(codes.InvalidArgument, "device is required")
+	}
+	if err := models.ValidateDevice(device); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ruziba3vich/shared/access"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RoleIn returns the role of a user in a house as the controller stores it,
+// "" when the user is not a member or the house does not exist
+func (s *Storage) RoleIn(ctx context.Context, userId, houseId string) (string, error) {
+	objectID, err := primitive.ObjectIDFromHex(houseId)
+	if err != nil {
+		return "", nil
+	}
+	var house struct {
+		OwnerId string `bson:"owner_id"`
+		Members []struct {
+			UserId string `bson:"user_id"`
+			Role   string `bson:"role"`
+		} `bson:"members"`
+	}
+	err = s.database.HousesCollection.FindOne(ctx, bson.M{"_id": objectID},
+		options.FindOne().SetProjection(bson.M{"owner_id": 1, "members": 1})).Decode(&house)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if err != nil {
+		s.logger.Printf("Failed to find house: %s", err.Error())
+		return "", fmt.Errorf("failed to find house: %s", err.Error())
+	}
+	for _, member := range house.Members {
+		if member.UserId == userId {
+			return member.Role, nil
+		}
+	}
+	if house.OwnerId == userId {
+		return access.RoleOwner, nil
+	}
+	return "", nil
+}
+
+// IsAdmin reports whether a live user is an administrator of the users
+// service
+func (s *Storage) IsAdmin(ctx context.Context, userId string) (bool, error) {
+	objectID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return false, nil
+	}
+	var user struct {
+		Admin bool `bson:"admin"`
+	}
+	err = s.database.UsersCollection.FindOne(ctx, bson.M{"_id": objectID, "deleted": false},
+		options.FindOne().SetProjection(bson.M{"admin": 1})).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		s.logger.Printf("Failed to find user: %s", err.Error())
+		return false, fmt.Errorf("failed to find user: %s", err.Error())
+	}
+	return user.Admin, nil
+}
+
+// HouseOf returns the house a device belongs to, "" for none
+func (s *Storage) HouseOf(ctx context.Context, deviceId string) (string, error) {
+	objectID, err := primitive.ObjectIDFromHex(deviceId)
+	if err != nil {
+		return "", status.Errorf(codes.InvalidArgument, "invalid device id: %s", deviceId)
+	}
+	var device struct {
+		HouseId string `bson:"house_id"`
+	}
+	err = s.devicesCollection().FindOne(ctx, bson.M{"_id": objectID, "deleted": false},
+		options.FindOne().SetProjection(bson.M{"house_id": 1})).Decode(&device)
+	if err == mongo.ErrNoDocuments {
+		return "", status.Errorf(codes.NotFound, "no device found with id: %s", deviceId)
+	}
+	if err != nil {
+		s.logger.Printf("Failed to find device: %s", err.Error())
+		return "", fmt.Errorf("failed to find device: %s", err.Error())
+	}
+	return device.HouseId, nil
+}
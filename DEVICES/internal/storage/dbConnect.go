@@ -6,13 +6,24 @@ import (
 	"log"
 
 	"github.com/ruziba3vich/devices/internal/config"
+	"github.com/ruziba3vich/shared/events"
+	"github.com/ruziba3vich/shared/outbox"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type (
 	DB struct {
-		Client          *mongo.Client
+		Client            *mongo.Client
+		DevicesCollection *mongo.Collection
+		OutboxCollection  *mongo.Collection
+		// RoomsCollection and HousesCollection belong to the controller and
+		// are only read, to check where devices are placed and who may
+		// change them
+		RoomsCollection  *mongo.Collection
+		HousesCollection *mongo.Collection
+		// UsersCollection belongs to the users service and is only read, to
+		// tell administrators apart
 		UsersCollection *mongo.Collection
 	}
 	Storage struct {
@@ -42,8 +53,12 @@ func ConnectDB(cfg *config.Config, ctx context.Context) (*DB, error) {
 	}
 
 	return &DB{
-		Client:          client,
-		UsersCollection: client.Database(cfg.DbConfig.MongoDB).Collection(cfg.DbConfig.Collection),
+		Client:            client,
+		DevicesCollection: client.Database("smart_house").Collection("devices"),
+		OutboxCollection:  client.Database("smart_house").Collection(outbox.CollectionName),
+		RoomsCollection:   client.Database(cfg.DbConfig.RoomsDB).Collection("rooms"),
+		HousesCollection:  client.Database(cfg.DbConfig.RoomsDB).Collection("houses"),
+		UsersCollection:   client.Database(cfg.DbConfig.UsersDB).Collection("users"),
 	}, nil
 }
 
@@ -54,3 +69,32 @@ func (db *DB) DisconnectDB(ctx context.Context) error {
 	}
 	return nil
 }
+
+// withTransaction runs fn in a Mongo transaction, so a device change and the
+// outbox row announcing it are committed or rolled back together
+func (s *Storage) withTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := s.database.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %s", err.Error())
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// writeEvent wraps payload in a domain event envelope and stores it as an
+// outbox row inside the transaction of sessCtx
+func (s *Storage) writeEvent(sessCtx mongo.SessionContext, eventType string, payload interface{}) error {
+	msg, err := outbox.NewMessage(eventType, events.New(sessCtx, eventType, payload))
+	if err != nil {
+		return err
+	}
+	if _, err := s.database.OutboxCollection.InsertOne(sessCtx, msg); err != nil {
+		s.logger.Printf("Failed to insert outbox row: %s", err.Error())
+		return fmt.Errorf("failed to insert outbox row: %s", err.Error())
+	}
+	return nil
+}
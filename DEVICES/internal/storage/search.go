@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	genprotos "github.com/ruziba3vich/devices/genprotos/devices_submodule"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// deviceSearchFields are the fields devices are searched by; DeviceSearchIndex is
+// the text index over them, which the migrations create
+var (
+	deviceSearchFields = []string{"name", "type", "location"}
+	DeviceSearchIndex  = textIndex(deviceSearchFields)
+)
+
+// Search finds the devices of the given houses matching a query
+func (s *Storage) Search(ctx context.Context, req *genprotos.SearchDevicesRequest) (*genprotos.GetAllDevicesResponse, error) {
+	query := strings.TrimSpace(req.Query)
+	if len(query) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+	if len(req.HouseIds) == 0 {
+		return &genprotos.GetAllDevicesResponse{}, nil
+	}
+	filter := bson.M{"deleted": false, "house_id": bson.M{"$in": req.HouseIds}}
+	return s.findDevices(ctx, filter, searchQuery(filter, query, req.Prefix, req.Limit, deviceSearchFields))
+}
+
+// searchQuery narrows filter down to the documents matching query and
+// returns the options listing them. Whole words are looked up in the text
+// index and ranked by relevance; with prefix, the documents with a word
+// starting with query in one of fields are listed instead, which is what
+// autocomplete needs while the last word is being typed
+func searchQuery(filter bson.M, query string, prefix bool, limit int32, fields []string) *options.FindOptions {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	} else if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	findOptions := options.Find().SetLimit(int64(limit))
+
+	if prefix {
+		pattern := primitive.Regex{Pattern: `(^|\s)` + regexp.QuoteMeta(query), Options: "i"}
+		matches := bson.A{}
+		for _, field := range fields {
+			matches = append(matches, bson.M{field: pattern})
+		}
+		filter["$or"] = matches
+		return findOptions.SetSort(bson.D{{Key: fields[0], Value: 1}, {Key: "_id", Value: 1}})
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	filter["$text"] = bson.M{"$search": query}
+	return findOptions.SetProjection(bson.M{"score": score}).SetSort(bson.M{"score": score})
+}
+
+func textIndex(fields []string) bson.D {
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: "text"})
+	}
+	return keys
+}
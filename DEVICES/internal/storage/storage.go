@@ -5,67 +5,139 @@ import (
 	"fmt"
 
 	genprotos "github.com/ruziba3vich/devices/genprotos/devices_submodule"
+	"github.com/ruziba3vich/devices/internal/models"
+	"github.com/ruziba3vich/shared/events"
+	"github.com/ruziba3vich/shared/pagination"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+func (s *Storage) devicesCollection() *mongo.Collection {
+	return s.database.DevicesCollection
+}
+
 func (s *Storage) CreateDevice(ctx context.Context, req *genprotos.CreateDeviceRequest) (*genprotos.CreateDeviceResponse, error) {
-	device := req.Device
-	device.Id = primitive.NewObjectID().Hex()
+	var device models.Device
+	device.FromCreateDeviceRequest(req)
+	device.Id = primitive.NewObjectID()
 
-	_, err := s.database.Client.Database("smart_house").Collection("devices").InsertOne(ctx, device)
+	err := s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if err := s.checkPlacement(sessCtx, device.HouseId, device.RoomId); err != nil {
+			return err
+		}
+		if _, err := s.devicesCollection().InsertOne(sessCtx, device); err != nil {
+			s.logger.Printf("Failed to insert device: %s", err.Error())
+			return err
+		}
+		return s.writeEvent(sessCtx, events.DeviceCreated, device.ToProtoDevice())
+	})
 	if err != nil {
-		s.logger.Printf("Failed to insert device: %s", err.Error())
 		return nil, err
 	}
 
-	return &genprotos.CreateDeviceResponse{Device: device}, nil
+	return &genprotos.CreateDeviceResponse{Device: device.ToProtoDevice()}, nil
 }
 
+// UpdateDevice changes the fields of a device the request sets and leaves the
+// others as they are. The device it results in has to fit its type and be
+// placed in a room of its own house; otherwise it is InvalidArgument.
 func (s *Storage) UpdateDevice(ctx context.Context, req *genprotos.UpdateDeviceRequest) (*genprotos.UpdateDeviceResponse, error) {
-	device := req.Device
-	objectID, err := primitive.ObjectIDFromHex(device.Id)
+	if req.Device == nil {
+		return nil, status.Error(codes.InvalidArgument, "device is required")
+	}
+	objectID, err := primitive.ObjectIDFromHex(req.Device.Id)
 	if err != nil {
 		s.logger.Printf("Failed to convert ID to ObjectID: %s", err.Error())
-		return nil, err
+		return nil, status.Errorf(codes.InvalidArgument, "invalid device id: %s", req.Device.Id)
 	}
 
-	filter := bson.M{"_id": objectID}
-	update := bson.M{"$set": device}
+	filter := bson.M{"_id": objectID, "deleted": false}
+	var device models.Device
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		err := s.devicesCollection().FindOne(sessCtx, filter).Decode(&device)
+		if err == mongo.ErrNoDocuments {
+			return status.Errorf(codes.NotFound, "no device found with ID: %s", req.Device.Id)
+		}
+		if err != nil {
+			return err
+		}
+		set := device.Patch(req.Device)
+		if len(set) == 0 {
+			s.logger.Println("No device was updated")
+			return nil
+		}
+		if err := models.ValidateDevice(device.ToProtoDevice()); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err := s.checkPlacement(sessCtx, device.HouseId, device.RoomId); err != nil {
+			return err
+		}
 
-	updateResult, err := s.database.Client.Database("smart_house").Collection("devices").UpdateOne(ctx, filter, update)
+		update := bson.M{"$set": set}
+		if _, retyped := set["type"]; retyped {
+			// a device flagged by the legacy type migration got a registered type
+			update["$unset"] = bson.M{"unknown_type": ""}
+		}
+		if _, err := s.devicesCollection().UpdateOne(sessCtx, filter, update); err != nil {
+			s.logger.Printf("Failed to update device: %s", err.Error())
+			return err
+		}
+		return s.writeEvent(sessCtx, events.DeviceUpdated, device.ToProtoDevice())
+	})
 	if err != nil {
-		s.logger.Printf("Failed to update device: %s", err.Error())
 		return nil, err
 	}
-	if updateResult.ModifiedCount == 0 {
-		s.logger.Println("No device was updated")
-	}
 
-	return &genprotos.UpdateDeviceResponse{Device: device}, nil
+	return &genprotos.UpdateDeviceResponse{Device: device.ToProtoDevice()}, nil
+}
+
+// checkPlacement refuses a room that is not one of the house's. Rooms are
+// kept by the controller, in its own database.
+func (s *Storage) checkPlacement(ctx context.Context, houseId, roomId string) error {
+	if len(roomId) == 0 {
+		return nil
+	}
+	if len(houseId) == 0 {
+		return status.Error(codes.InvalidArgument, "a device placed in a room has to be placed in its house")
+	}
+	objectID, err := primitive.ObjectIDFromHex(roomId)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid room id: %s", roomId)
+	}
+	err = s.database.RoomsCollection.FindOne(ctx, bson.M{"_id": objectID, "house_id": houseId}).Err()
+	if err == mongo.ErrNoDocuments {
+		return status.Errorf(codes.InvalidArgument, "room %s is not in house %s", roomId, houseId)
+	}
+	if err != nil {
+		s.logger.Printf("Failed to find room: %s", err.Error())
+		return fmt.Errorf("failed to find room: %s", err.Error())
+	}
+	return nil
 }
 
 func (s *Storage) GetDevice(ctx context.Context, req *genprotos.GetDeviceRequest) (*genprotos.GetDeviceResponse, error) {
 	objectID, err := primitive.ObjectIDFromHex(req.Id)
 	if err != nil {
 		s.logger.Printf("Failed to convert ID to ObjectID: %s", err.Error())
-		return nil, err
+		return nil, status.Errorf(codes.InvalidArgument, "invalid device id: %s", req.Id)
 	}
 
-	var device genprotos.Device
-	err = s.database.Client.Database("smart_house").Collection("devices").FindOne(ctx, bson.M{"_id": objectID}).Decode(&device)
+	var device models.Device
+	err = s.devicesCollection().FindOne(ctx, bson.M{"_id": objectID, "deleted": false}).Decode(&device)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			s.logger.Printf("No device found with ID: %s", req.Id)
-			return nil, fmt.Errorf("no device found with ID: %s", req.Id)
+			return nil, status.Errorf(codes.NotFound, "no device found with ID: %s", req.Id)
 		}
 		s.logger.Printf("Failed to find device: %s", err.Error())
 		return nil, err
 	}
 
-	return &genprotos.GetDeviceResponse{Device: &device}, nil
+	return &genprotos.GetDeviceResponse{Device: device.ToProtoDevice()}, nil
 }
 
 func (s *Storage) DeleteDevice(ctx context.Context, req *genprotos.DeleteDeviceRequest) (*genprotos.DeleteDeviceResponse, error) {
@@ -78,28 +150,97 @@ func (s *Storage) DeleteDevice(ctx context.Context, req *genprotos.DeleteDeviceR
 	filter := bson.M{"_id": objectID}
 	update := bson.M{"$set": bson.M{"deleted": true}}
 
-	updateResult, err := s.database.Client.Database("smart_house").Collection("devices").UpdateOne(ctx, filter, update)
+	var deleted bool
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		updateResult, err := s.devicesCollection().UpdateOne(sessCtx, filter, update)
+		if err != nil {
+			s.logger.Printf("Failed to update device: %s", err.Error())
+			return err
+		}
+		deleted = updateResult.ModifiedCount > 0
+		if !deleted {
+			s.logger.Println("No device was updated")
+			return nil
+		}
+		return s.writeEvent(sessCtx, events.DeviceDeleted, &genprotos.GetDeviceRequest{Id: req.Id})
+	})
 	if err != nil {
-		s.logger.Printf("Failed to update device: %s", err.Error())
 		return nil, err
 	}
-	if updateResult.ModifiedCount == 0 {
-		s.logger.Println("No device was updated")
-	}
 
-	return &genprotos.DeleteDeviceResponse{Success: updateResult.ModifiedCount > 0}, nil
+	return &genprotos.DeleteDeviceResponse{Success: deleted}, nil
 }
 
+// GetAllDevices lists devices a page at a time, optionally narrowed down to
+// a house, a room, a type, a status or a location. Pages follow each other
+// through the opaque next_cursor of the response
 func (s *Storage) GetAllDevices(ctx context.Context, req *genprotos.GetAllDevicesRequest) (*genprotos.GetAllDevicesResponse, error) {
-	skip := (req.Page - 1) * req.Limit
+	query, err := pagination.NewQuery(req.Limit, req.Cursor, req.SortBy, req.Descending, "name", "type", "status")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{"deleted": req.Deleted}
+	if len(req.HouseId) > 0 {
+		filter["house_id"] = req.HouseId
+	}
+	if len(req.RoomId) > 0 {
+		filter["room_id"] = req.RoomId
+	}
+	if len(req.Type) > 0 {
+		filter["type"] = req.Type
+	}
+	if len(req.Status) > 0 {
+		filter["status"] = req.Status
+	}
+	if len(req.Location) > 0 {
+		filter["location"] = req.Location
+	}
+	if len(req.Presence) > 0 {
+		presence, err := presenceFilter(req.Presence)
+		if err != nil {
+			return nil, err
+		}
+		filter["online"] = presence
+	}
+
+	total, err := s.devicesCollection().CountDocuments(ctx, filter)
+	if err != nil {
+		s.logger.Printf("Failed to count devices: %s", err.Error())
+		return nil, err
+	}
+	response, err := s.findDevices(ctx, filter, query.Apply(filter))
+	if err != nil {
+		return nil, err
+	}
+	response.Total = total
+	if int64(len(response.Devices)) > query.Limit() {
+		response.Devices = response.Devices[:query.Limit()]
+		last := response.Devices[query.Limit()-1]
+		id, _ := primitive.ObjectIDFromHex(last.Id)
+		response.NextCursor = query.NextCursor(deviceSortValue(last, query.SortBy()), id)
+	}
+	return response, nil
+}
 
-	findOptions := options.Find()
-	findOptions.SetLimit(int64(req.Limit))
-	findOptions.SetSkip(int64(skip))
+// GetDevicesByRoom lists the devices placed in a room
+func (s *Storage) GetDevicesByRoom(ctx context.Context, req *genprotos.GetDevicesByRoomRequest) (*genprotos.GetAllDevicesResponse, error) {
+	if len(req.RoomId) == 0 {
+		return nil, fmt.Errorf("room id is required")
+	}
+	return s.findDevices(ctx, bson.M{"room_id": req.RoomId, "deleted": false}, options.Find())
+}
 
-	filter := bson.M{"deleted": false}
+// GetDevicesByHouse lists the devices of a house, whatever room they are in
+func (s *Storage) GetDevicesByHouse(ctx context.Context, req *genprotos.GetDevicesByHouseRequest) (*genprotos.GetAllDevicesResponse, error) {
+	if len(req.HouseId) == 0 {
+		return nil, fmt.Errorf("house id is required")
+	}
+	return s.findDevices(ctx, bson.M{"house_id": req.HouseId, "deleted": false}, options.Find())
+}
 
-	cursor, err := s.database.Client.Database("smart_house").Collection("devices").Find(ctx, filter, findOptions)
+func (s *Storage) findDevices(ctx context.Context, filter bson.M, findOptions *options.FindOptions) (*genprotos.GetAllDevicesResponse, error) {
+	cursor, err := s.devicesCollection().Find(ctx, filter, findOptions)
 	if err != nil {
 		s.logger.Printf("Failed to find devices: %s", err.Error())
 		return nil, err
@@ -108,12 +249,12 @@ func (s *Storage) GetAllDevices(ctx context.Context, req *genprotos.GetAllDevice
 
 	var devices []*genprotos.Device
 	for cursor.Next(ctx) {
-		var device genprotos.Device
+		var device models.Device
 		if err := cursor.Decode(&device); err != nil {
 			s.logger.Printf("Failed to decode device: %s", err.Error())
 			return nil, err
 		}
-		devices = append(devices, &device)
+		devices = append(devices, device.ToProtoDevice())
 	}
 
 	if err := cursor.Err(); err != nil {
@@ -123,3 +264,29 @@ func (s *Storage) GetAllDevices(ctx context.Context, req *genprotos.GetAllDevice
 
 	return &genprotos.GetAllDevicesResponse{Devices: devices}, nil
 }
+
+func deviceSortValue(device *genprotos.Device, field string) string {
+	switch field {
+	case "name":
+		return device.Name
+	case "type":
+		return device.Type
+	case "status":
+		return device.Status
+	}
+	return ""
+}
+
+// presenceFilter matches the devices of a presence. Whether a device is
+// online is set by the controller; one it never heard from has no flag.
+func presenceFilter(presence string) (interface{}, error) {
+	switch presence {
+	case models.PresenceOnline:
+		return true, nil
+	case models.PresenceOffline:
+		return false, nil
+	case models.PresenceUnknown:
+		return bson.M{"$exists": false}, nil
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "presence must be %s, %s or %s", models.PresenceOnline, models.PresenceOffline, models.PresenceUnknown)
+}
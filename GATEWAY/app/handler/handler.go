@@ -3,15 +3,19 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/k0kubun/pp"
+	"github.com/golang-jwt/jwt"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/shared/access"
 	controlrpc "github.com/ruziba3vich/smart-house/genprotos/controller_submodule"
 	devicesrpc "github.com/ruziba3vich/smart-house/genprotos/devices_submodule"
 	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
@@ -19,12 +23,24 @@ import (
 	"github.com/ruziba3vich/smart-house/internal/config"
 	models "github.com/ruziba3vich/smart-house/internal/modules"
 	"github.com/ruziba3vich/smart-house/internal/msgbroker"
+	"github.com/ruziba3vich/smart-house/internal/policy"
+	"github.com/ruziba3vich/smart-house/internal/ratelimit"
+	"github.com/ruziba3vich/smart-house/internal/sessions"
+	"github.com/ruziba3vich/smart-house/internal/stream"
 	"github.com/ruziba3vich/smart-house/internal/utils"
+	middleware "github.com/ruziba3vich/smart-house/midd-ware"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	_ "github.com/ruziba3vich/smart-house/docs"
 )
 
+// ActorHeader is the metadata key the backend services read the acting user from
+const ActorHeader = msgbroker.ActorHeader
+
 type (
 	RbmqHandler struct {
 		logger           *log.Logger
@@ -33,6 +49,10 @@ type (
 		usersClient      usersprotos.UsersServiceClient
 		devicesClient    devicesrpc.DeviceServiceClient
 		controllerClient controlrpc.ControllerServiceClient
+		hub              *stream.Hub
+		accountLimiter   *ratelimit.SlidingWindow
+		sessions         *sessions.Store
+		policy           *policy.Policy
 		cfg              *config.Config
 		rq               amqp.Queue
 		uq               amqp.Queue
@@ -46,6 +66,9 @@ func NewRbmqHandler(logger *log.Logger,
 	usersClient usersprotos.UsersServiceClient,
 	devicesClient devicesrpc.DeviceServiceClient,
 	controllerClient controlrpc.ControllerServiceClient,
+	hub *stream.Hub,
+	accountLimiter *ratelimit.SlidingWindow,
+	sessions *sessions.Store,
 	cfg *config.Config,
 	rq amqp.Queue,
 	uq amqp.Queue,
@@ -56,6 +79,10 @@ func NewRbmqHandler(logger *log.Logger,
 		usersClient:      usersClient,
 		devicesClient:    devicesClient,
 		controllerClient: controllerClient,
+		hub:              hub,
+		accountLimiter:   accountLimiter,
+		sessions:         sessions,
+		policy:           policy.New(controllerClient, devicesClient, usersClient),
 		tokenizer:        tokenizer,
 		cfg:              cfg,
 		rq:               rq,
@@ -76,13 +103,17 @@ func NewRbmqHandler(logger *log.Logger,
 
 // LoginUser godoc
 // @Summary Login
-// @Description Login an existing user
+// @Description Login an existing user. With two-factor authentication on, the response carries an mfa_challenge instead of a token, to be completed at /users/login/2fa
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param body body usersprotos.LoginRequest true "User login information"
 // @Success 201 {object} models.UserResponse
+// @Success 202 {object} models.UserResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/login [post]
 func (r *RbmqHandler) LoginUser(c *gin.Context) {
@@ -92,16 +123,222 @@ func (r *RbmqHandler) LoginUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	allowed, wait, err := r.accountLimiter.Allow(c, strings.ToLower(strings.TrimSpace(req.Email)))
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if !allowed {
+		middleware.TooManyRequests(c, wait)
+		return
+	}
 	response, err := r.usersClient.LoginUser(c, &req)
-	pp.Println(&response)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		r.writeLoginError(c, err)
+		return
+	}
+	if response.MfaRequired {
+		c.JSON(http.StatusAccepted, models.UserResponse{Response: response})
 		return
 	}
 	c.JSON(http.StatusCreated, models.UserResponse{Response: response})
 }
 
+// RefreshToken godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. A refresh token can be used once, using it again revokes its session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body usersprotos.RefreshTokenRequest true "Refresh token"
+// @Success 200 {object} models.UserResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /users/refresh [post]
+func (r *RbmqHandler) RefreshToken(c *gin.Context) {
+	var req usersprotos.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	response, err := r.usersClient.RefreshToken(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.UserResponse{Response: response})
+}
+
+// Logout godoc
+// @Summary Logout
+// @Description End the session of the access token used for the request
+// @Tags auth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} usersprotos.Response
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/logout [post]
+func (r *RbmqHandler) Logout(c *gin.Context) {
+	req := usersprotos.LogoutRequest{UserId: subjectOf(c), SessionId: sessionOf(c)}
+	response, err := r.usersClient.Logout(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// LogoutAll godoc
+// @Summary Logout of all sessions
+// @Description End every session of the caller, on all devices
+// @Tags auth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} usersprotos.Response
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/logout-all [post]
+func (r *RbmqHandler) LogoutAll(c *gin.Context) {
+	req := usersprotos.LogoutRequest{UserId: subjectOf(c)}
+	response, err := r.usersClient.LogoutAll(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// JWKS godoc
+// @Summary Token signing keys
+// @Description The public keys access tokens are signed with, as a JSON Web Key Set
+// @Tags auth
+// @Produce json
+// @Success 200 {object} usersprotos.JWKS
+// @Failure 503 {object} models.ErrorResponse
+// @Router /.well-known/jwks.json [get]
+func (r *RbmqHandler) JWKS(c *gin.Context) {
+	jwks, err := r.tokenizer.JWKS(c)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, jwks)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Verify the email address of a newly registered user with the token mailed to them
+// @Tags auth
+// @Produce json
+// @Param token query string true "Verification token"
+// @Success 200 {object} usersprotos.Response
+// @Failure 400 {object} models.ErrorResponse
+// @Router /users/verify [get]
+func (r *RbmqHandler) VerifyEmail(c *gin.Context) {
+	req := usersprotos.VerifyEmailRequest{Token: c.Query("token")}
+	if len(req.Token) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "token is required"})
+		return
+	}
+	response, err := r.usersClient.VerifyEmail(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ForgotPassword godoc
+// @Summary Forgot password
+// @Description Mail a password reset token to the given address, if it belongs to an account
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body usersprotos.ForgotPasswordRequest true "Email address"
+// @Success 202 {object} usersprotos.Response
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/password/forgot [post]
+func (r *RbmqHandler) ForgotPassword(c *gin.Context) {
+	var req usersprotos.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	response, err := r.usersClient.ForgotPassword(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, response)
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Choose a new password with a token from the forgot password flow. Every session of the user is logged out
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body usersprotos.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} usersprotos.Response
+// @Failure 400 {object} models.ErrorResponse
+// @Router /users/password/reset [post]
+func (r *RbmqHandler) ResetPassword(c *gin.Context) {
+	var req usersprotos.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	response, err := r.usersClient.ResetPassword(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UnlockAccount godoc
+// @Summary Unlock an account
+// @Description Lift the lockout of an account locked after too many failed logins. Administrators only
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body models.UnlockAccountRequest true "Email address of the account"
+// @Success 200 {object} usersprotos.Response
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/unlock [post]
+func (r *RbmqHandler) UnlockAccount(c *gin.Context) {
+	var req models.UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	ctx := r.outgoingContext(c)
+	if !r.authorizeWith(c, r.policy.RequireAdmin(ctx, subjectOf(c))) {
+		return
+	}
+	response, err := r.usersClient.UnlockAccount(ctx, &usersprotos.GetByFieldRequest{GetByField: req.Email})
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // RegisterUser godoc
 // @Summary Register
 // @Description Register a new user
@@ -135,27 +372,24 @@ func (r *RbmqHandler) RegisterUser(c *gin.Context) {
 		return
 	}
 
-	err = r.Msgbroker.PublishToQueue(r.Msgbroker.Registration, body, r.rq, "create_reply", r.cfg.ContentType)
+	reply, err := r.Msgbroker.Call(r.outgoingContext(c), body, r.rq, r.cfg.ContentType)
 	if err != nil {
 		r.logger.Println("-- ERROR FROM SERVER -- `: ", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		r.writeCallError(c, err)
+		return
+	}
+	if reply.Status != models.ReplyStatusSuccess {
+		r.writeReplyError(c, reply)
 		return
 	}
 
-	select {
-	case <-time.After(time.Second * 5):
-		user, err := r.usersClient.GetByEmail(c, &usersprotos.GetByFieldRequest{GetByField: req.Email})
-		if err != nil {
-			r.logger.Println("ERROR FROM SERVER: ", err)
-			c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
-			return
-		}
-		c.JSON(http.StatusCreated, models.UserResponse{Response: user})
-	case <-time.After(time.Second * 10):
-		r.logger.Println("ERROR: TIMEOUT WAITING FOR USER CREATION")
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: "Timeout waiting for user creation"})
+	user, err := r.usersClient.GetByEmail(c, &usersprotos.GetByFieldRequest{GetByField: req.Email})
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	c.JSON(http.StatusCreated, models.UserResponse{Response: user})
 }
 
 // UpdateUser godoc
@@ -169,6 +403,7 @@ func (r *RbmqHandler) RegisterUser(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Success 201 {object} models.UserResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/{id} [put]
 func (r *RbmqHandler) UpdateUser(c *gin.Context) {
@@ -178,6 +413,9 @@ func (r *RbmqHandler) UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if !r.authorize(c, access.ActionOwnAccount, policy.Resource{UserId: c.Param("id")}) {
+		return
+	}
 	strUserId, err := primitive.ObjectIDFromHex(c.Param("id"))
 	if err != nil {
 		r.logger.Println("ERROR WHILE GETTING USER ID")
@@ -192,7 +430,17 @@ func (r *RbmqHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	r.Msgbroker.PublishToQueue(r.Msgbroker.Updates, body, r.uq, "update_reply", r.cfg.ContentType)
+	reply, err := r.Msgbroker.Call(r.outgoingContext(c), body, r.uq, r.cfg.ContentType)
+	if err != nil {
+		r.logger.Println("ERROR WHILE PUBLISHING UPDATE", err.Error())
+		r.writeCallError(c, err)
+		return
+	}
+	if reply.Status != models.ReplyStatusSuccess {
+		r.writeReplyError(c, reply)
+		return
+	}
+
 	user, err := r.usersClient.GetById(c, &usersprotos.GetByFieldRequest{
 		GetByField: req.Id.Hex(),
 	})
@@ -214,9 +462,13 @@ func (r *RbmqHandler) UpdateUser(c *gin.Context) {
 // @Security ApiKeyAuth
 // @Success 201 {object} models.UserResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/delete/{id} [delete]
 func (r *RbmqHandler) DeleteUserById(c *gin.Context) {
+	if !r.authorize(c, access.ActionOwnAccount, policy.Resource{UserId: c.Param("id")}) {
+		return
+	}
 	req := models.DeleteUserRequest{
 		UserId: c.Param("id"),
 	}
@@ -228,42 +480,65 @@ func (r *RbmqHandler) DeleteUserById(c *gin.Context) {
 		return
 	}
 
-	r.Msgbroker.PublishToQueue(r.Msgbroker.Deletes, body, r.dq, "delete_reply", r.cfg.ContentType)
-	user, err := r.usersClient.GetById(c, &usersprotos.GetByFieldRequest{
-		GetByField: req.UserId,
-	})
+	reply, err := r.Msgbroker.Call(r.outgoingContext(c), body, r.dq, r.cfg.ContentType)
 	if err != nil {
-		r.logger.Println("ERROR HAS BEEN RETURNED FROM THE SERVER", err.Error())
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		r.logger.Println("ERROR WHILE PUBLISHING DELETION", err.Error())
+		r.writeCallError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, models.UserResponse{Response: user})
+	if reply.Status != models.ReplyStatusSuccess {
+		r.writeReplyError(c, reply)
+		return
+	}
+	c.JSON(http.StatusOK, models.UserResponse{Response: reply.Message})
 }
 
 // GetAllUsers godoc
 // @Summary Get all users
-// @Description Retrieve all users
+// @Description Retrieve users a page at a time. Only administrators see the emails and addresses of the users, and may filter by address or sort by email
 // @Tags users
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Success 200 {object} []models.User
+// @Param limit query int false "Users per page, 20 by default and 100 at most"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param address query string false "Only users living at this address, for administrators only"
+// @Param deleted query bool false "List the deleted users instead, for administrators only"
+// @Param sort_by query string false "username, or email for administrators, creation order by default"
+// @Param descending query bool false "Sort in descending order"
+// @Success 200 {object} models.UsersPage
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users [get]
 func (g *RbmqHandler) GetAllUsers(c *gin.Context) {
-	var req usersprotos.GetAllUsersRequest
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	deleted, _ := strconv.ParseBool(c.Query("deleted"))
+	descending, _ := strconv.ParseBool(c.Query("descending"))
+	req := usersprotos.GetAllUsersRequest{
+		Limit:      int32(limit),
+		Cursor:     c.Query("cursor"),
+		Address:    c.Query("address"),
+		Deleted:    deleted,
+		SortBy:     c.Query("sort_by"),
+		Descending: descending,
+	}
 
-	resp, err := g.usersClient.GetAllUsers(c, &req)
+	resp, err := g.usersClient.GetAllUsers(g.outgoingContext(c), &req)
 	if err != nil {
 		g.logger.Println("ERROR RETURNED FROM THE SERVER :", err.Error())
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		writeRPCError(c, err)
 		return
 	}
-	var response []*models.User
+	response := models.UsersPage{
+		Users:      []*models.User{},
+		NextCursor: resp.NextCursor,
+		Total:      resp.Total,
+	}
 	for i := range resp.Users {
 		var user models.User
 		user.FromProtoUser(resp.Users[i])
-		response = append(response, &user)
+		response.Users = append(response.Users, &user)
 	}
 	c.JSON(http.StatusOK, response)
 }
@@ -280,6 +555,137 @@ func (g *RbmqHandler) GetAllUsers(c *gin.Context) {
    rpc GetUsersByAddress(GetUsersByAddressRequest) returns (GetAllUsersResponse);
 */
 
+// writeLoginError answers a failed login: 429 with Retry-After for a locked
+// account, 401 for wrong credentials or codes, 403 for an address that has
+// not been verified
+func (r *RbmqHandler) writeLoginError(c *gin.Context, err error) {
+	st, ok := status.FromError(err)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	switch st.Code() {
+	case codes.ResourceExhausted:
+		for _, detail := range st.Details() {
+			if info, ok := detail.(*errdetails.RetryInfo); ok {
+				middleware.TooManyRequests(c, info.RetryDelay.AsDuration())
+				return
+			}
+		}
+		c.JSON(http.StatusTooManyRequests, models.ErrorResponse{Error: st.Message()})
+	case codes.Unauthenticated:
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{Error: st.Message()})
+	case codes.PermissionDenied:
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: st.Message()})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: st.Message()})
+	}
+}
+
+// writeRPCError answers a failed call to a backend service: 400 for the
+// InvalidArgument it reports for bad input, 403 for PermissionDenied, 404 for
+// NotFound, 409 for the FailedPrecondition of a command to an offline device,
+// 503 for a device that is Unavailable, 500 otherwise
+func writeRPCError(c *gin.Context, err error) {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: status.Convert(err).Message()})
+		return
+	case codes.PermissionDenied:
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: status.Convert(err).Message()})
+		return
+	case codes.NotFound:
+		c.JSON(http.StatusNotFound, models.ErrorResponse{Error: status.Convert(err).Message()})
+		return
+	case codes.FailedPrecondition:
+		c.JSON(http.StatusConflict, models.ErrorResponse{Error: status.Convert(err).Message()})
+		return
+	case codes.Unavailable:
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{Error: status.Convert(err).Message()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+}
+
+// writeCallError reports a failed RPC-over-AMQP call, telling a timeout apart
+// from a broker failure.
+func (r *RbmqHandler) writeCallError(c *gin.Context, err error) {
+	if errors.Is(err, msgbroker.ErrTimeout) {
+		c.JSON(http.StatusGatewayTimeout, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+}
+
+// authorize checks the policy for the authenticated user, answering 403 on
+// denial. It reports whether the handler may go on.
+func (r *RbmqHandler) authorize(c *gin.Context, action access.Action, resource policy.Resource) bool {
+	return r.authorizeWith(c, r.policy.Authorize(r.outgoingContext(c), subjectOf(c), action, resource))
+}
+
+// authorizeWith answers the outcome of a policy check that was already made
+func (r *RbmqHandler) authorizeWith(c *gin.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, access.ErrForbidden) {
+		r.logger.Println("ACCESS DENIED: ", err)
+		c.JSON(http.StatusForbidden, models.ErrorResponse{Error: err.Error()})
+		return false
+	}
+	r.logger.Println("ERROR WHILE CHECKING ACCESS: ", err)
+	writeRPCError(c, err)
+	return false
+}
+
+// writeReplyError turns a non-successful consumer reply into an HTTP error.
+func (r *RbmqHandler) writeReplyError(c *gin.Context, reply *models.Reply) {
+	r.logger.Printf("COMMAND FAILED: %s: %s\n", reply.Message, reply.Error)
+	status := http.StatusInternalServerError
+	switch reply.Status {
+	case models.ReplyStatusBadRequest:
+		status = http.StatusBadRequest
+	case models.ReplyStatusForbidden:
+		status = http.StatusForbidden
+	}
+	c.JSON(status, models.ErrorResponse{Error: reply.Error})
+}
+
+// outgoingContext carries the authenticated user id to the backend services so
+// the events they emit are attributed to whoever made the request.
+func (r *RbmqHandler) outgoingContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	sub := subjectOf(c)
+	if len(sub) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, ActorHeader, sub)
+}
+
+// subjectOf returns the id of the authenticated user, or "" when the route is
+// not behind AuthMiddleware
+func subjectOf(c *gin.Context) string {
+	return claimOf(c, "sub")
+}
+
+// sessionOf returns the login session the caller's access token belongs to
+func sessionOf(c *gin.Context) string {
+	return claimOf(c, "sid")
+}
+
+func claimOf(c *gin.Context, name string) string {
+	claims, ok := c.Get("userClaims")
+	if !ok {
+		return ""
+	}
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+	value, _ := mapClaims[name].(string)
+	return value
+}
+
 func (r *RbmqHandler) checkIfUserExists(ctx context.Context, req *models.User) (bool, error) {
 	someReq := usersprotos.GetByFieldRequest{GetByField: req.Email}
 	user, _ := r.usersClient.GetByEmail(ctx, &someReq)
@@ -306,6 +712,7 @@ func (r *RbmqHandler) checkIfUserExists(ctx context.Context, req *models.User) (
 // @Param body body devicesprotos.CreateDeviceRequest true "Device creation information"
 // @Success 201 {object} devicesprotos.CreateDeviceResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /devices [post]
 func (r *RbmqHandler) CreateDevice(c *gin.Context) {
@@ -315,10 +722,17 @@ func (r *RbmqHandler) CreateDevice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
-	response, err := r.devicesClient.CreateDevice(c, &req)
+	if req.Device == nil || len(req.Device.HouseId) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "device.house_id is required"})
+		return
+	}
+	if !r.authorize(c, access.ActionManageDevices, policy.Resource{HouseId: req.Device.HouseId}) {
+		return
+	}
+	response, err := r.devicesClient.CreateDevice(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusCreated, response)
@@ -334,6 +748,7 @@ func (r *RbmqHandler) CreateDevice(c *gin.Context) {
 // @Param body body devicesprotos.UpdateDeviceRequest true "Device update information"
 // @Success 200 {object} devicesprotos.UpdateDeviceResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /devices/{id} [put]
 func (r *RbmqHandler) UpdateDevice(c *gin.Context) {
@@ -343,11 +758,22 @@ func (r *RbmqHandler) UpdateDevice(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
 		return
 	}
+	if req.Device == nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "device is required"})
+		return
+	}
 	req.Device.Id = c.Param("id")
-	response, err := r.devicesClient.UpdateDevice(c, &req)
+	if !r.authorize(c, access.ActionManageDevices, policy.Resource{DeviceId: req.Device.Id}) {
+		return
+	}
+	// moving a device takes the same right in the house it moves to
+	if len(req.Device.HouseId) > 0 && !r.authorize(c, access.ActionManageDevices, policy.Resource{HouseId: req.Device.HouseId}) {
+		return
+	}
+	response, err := r.devicesClient.UpdateDevice(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
@@ -362,14 +788,19 @@ func (r *RbmqHandler) UpdateDevice(c *gin.Context) {
 // @Param id path string true "Device ID"
 // @Success 200 {object} devicesprotos.GetDeviceResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /devices/{id} [get]
 func (r *RbmqHandler) GetDevice(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{DeviceId: c.Param("id")}) {
+		return
+	}
 	req := devicesrpc.GetDeviceRequest{Id: c.Param("id")}
-	response, err := r.devicesClient.GetDevice(c, &req)
+	response, err := r.devicesClient.GetDevice(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
@@ -384,14 +815,19 @@ func (r *RbmqHandler) GetDevice(c *gin.Context) {
 // @Param id path string true "Device ID"
 // @Success 200 {object} devicesprotos.DeleteDeviceResponse
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /devices/{id} [delete]
 func (r *RbmqHandler) DeleteDevice(c *gin.Context) {
+	if !r.authorize(c, access.ActionDeleteDevice, policy.Resource{DeviceId: c.Param("id")}) {
+		return
+	}
 	req := devicesrpc.DeleteDeviceRequest{Id: c.Param("id")}
-	response, err := r.devicesClient.DeleteDevice(c, &req)
+	response, err := r.devicesClient.DeleteDevice(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
@@ -399,20 +835,70 @@ func (r *RbmqHandler) DeleteDevice(c *gin.Context) {
 
 // GetAllDevices godoc
 // @Summary Get all devices
-// @Description Retrieve all devices with pagination
+// @Description Retrieve the devices of a house a page at a time
 // @Tags devices
 // @Accept json
 // @Produce json
-// @Param page query int true "Page number"
-// @Param limit query int true "Items per page"
+// @Param house_id query string true "House of the devices"
+// @Param limit query int false "Devices per page, 20 by default and 100 at most"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param room_id query string false "Only devices of this room"
+// @Param type query string false "Only devices of this type"
+// @Param status query string false "Only devices with this status"
+// @Param location query string false "Only devices at this location"
+// @Param presence query string false "online, offline or unknown"
+// @Param deleted query bool false "List the deleted devices instead"
+// @Param sort_by query string false "name, type or status, creation order by default"
+// @Param descending query bool false "Sort in descending order"
 // @Success 200 {object} devicesprotos.GetAllDevicesResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /devices [get]
 func (r *RbmqHandler) GetAllDevices(c *gin.Context) {
-	page, _ := strconv.Atoi(c.Query("page"))
+	if len(c.Query("house_id")) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "house_id is required"})
+		return
+	}
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{HouseId: c.Query("house_id")}) {
+		return
+	}
 	limit, _ := strconv.Atoi(c.Query("limit"))
-	req := devicesrpc.GetAllDevicesRequest{Page: int32(page), Limit: int32(limit)}
+	deleted, _ := strconv.ParseBool(c.Query("deleted"))
+	descending, _ := strconv.ParseBool(c.Query("descending"))
+	req := devicesrpc.GetAllDevicesRequest{
+		Limit:      int32(limit),
+		Cursor:     c.Query("cursor"),
+		HouseId:    c.Query("house_id"),
+		RoomId:     c.Query("room_id"),
+		Type:       c.Query("type"),
+		Status:     c.Query("status"),
+		Location:   c.Query("location"),
+		Presence:   c.Query("presence"),
+		Deleted:    deleted,
+		SortBy:     c.Query("sort_by"),
+		Descending: descending,
+	}
 	response, err := r.devicesClient.GetAllDevices(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListDeviceTypes godoc
+// @Summary List the device types
+// @Description The catalog of device types, with the capabilities and attributes of each
+// @Tags devices
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} devicesprotos.ListDeviceTypesResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /devices/types [get]
+func (r *RbmqHandler) ListDeviceTypes(c *gin.Context) {
+	response, err := r.devicesClient.ListDeviceTypes(c, &devicesrpc.ListDeviceTypesRequest{})
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
@@ -428,7 +914,11 @@ func (r *RbmqHandler) GetAllDevices(c *gin.Context) {
 // @Param request body controlrpc.DeviceRequest true "Device Request"
 // @Success 200 {object} controlrpc.DeviceResponse
 // @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
 // @Failure 500 {object} gin.H
+// @Failure 503 {object} gin.H
 // @Router /devices/on [post]
 func (r *RbmqHandler) TurnDeviceOn(c *gin.Context) {
 	var req controlrpc.DeviceRequest
@@ -437,10 +927,13 @@ func (r *RbmqHandler) TurnDeviceOn(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	response, err := r.controllerClient.TurnDeviceOn(context.Background(), &req)
+	if !r.authorize(c, access.ActionToggleDevice, policy.Resource{DeviceId: req.DeviceId}) {
+		return
+	}
+	response, err := r.controllerClient.TurnDeviceOn(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
@@ -453,7 +946,11 @@ func (r *RbmqHandler) TurnDeviceOn(c *gin.Context) {
 // @Param request body controlrpc.DeviceRequest true "Device Request"
 // @Success 200 {object} controlrpc.DeviceResponse
 // @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 409 {object} gin.H
 // @Failure 500 {object} gin.H
+// @Failure 503 {object} gin.H
 // @Router /devices/off [post]
 func (r *RbmqHandler) TurnDeviceOff(c *gin.Context) {
 	var req controlrpc.DeviceRequest
@@ -462,15 +959,55 @@ func (r *RbmqHandler) TurnDeviceOff(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	response, err := r.controllerClient.TurnDeviceOff(context.Background(), &req)
+	if !r.authorize(c, access.ActionToggleDevice, policy.Resource{DeviceId: req.DeviceId}) {
+		return
+	}
+	response, err := r.controllerClient.TurnDeviceOff(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
 }
 
+// @Summary Set the state of a device
+// @Description Change any of the settings of a device its type supports: on/off, brightness, colour, target temperature, lock state or blind position. Only the fields that are set change.
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Param patch body controlrpc.DeviceStatePatch true "State patch"
+// @Success 200 {object} controlrpc.DeviceState
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Failure 503 {object} models.ErrorResponse
+// @Router /devices/{id}/state [patch]
+func (r *RbmqHandler) SetDeviceState(c *gin.Context) {
+	var patch controlrpc.DeviceStatePatch
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	deviceId := c.Param("id")
+	if !r.authorize(c, access.ActionToggleDevice, policy.Resource{DeviceId: deviceId}) {
+		return
+	}
+	state, err := r.controllerClient.SetDeviceState(r.outgoingContext(c), &controlrpc.SetDeviceStateRequest{
+		DeviceId: deviceId,
+		Patch:    &patch,
+	})
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, state)
+}
+
 // @Summary Add user to house
 // @Description Add a user to a house by IDs
 // @Accept json
@@ -478,6 +1015,8 @@ func (r *RbmqHandler) TurnDeviceOff(c *gin.Context) {
 // @Param request body controlrpc.UserRequest true "User Request"
 // @Success 200 {object} controlrpc.HouseResponse
 // @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 404 {object} gin.H
 // @Failure 500 {object} gin.H
 // @Router /users/add [post]
 func (r *RbmqHandler) AddUserToHouse(c *gin.Context) {
@@ -487,10 +1026,13 @@ func (r *RbmqHandler) AddUserToHouse(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	response, err := r.controllerClient.AddUserToHouse(context.Background(), &req)
+	if !r.authorizeWith(c, r.policy.AuthorizeGrant(r.outgoingContext(c), subjectOf(c), req.HouseId, req.UserId, req.Role)) {
+		return
+	}
+	response, err := r.controllerClient.AddUserToHouse(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
@@ -503,6 +1045,8 @@ func (r *RbmqHandler) AddUserToHouse(c *gin.Context) {
 // @Param request body controlrpc.UserRequest true "User Request"
 // @Success 200 {object} controlrpc.HouseResponse
 // @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 404 {object} gin.H
 // @Failure 500 {object} gin.H
 // @Router /users/remove [post]
 func (r *RbmqHandler) RemoveUserFromHouse(c *gin.Context) {
@@ -512,11 +1056,108 @@ func (r *RbmqHandler) RemoveUserFromHouse(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	response, err := r.controllerClient.RemoveUserFromHouse(context.Background(), &req)
+	if !r.authorizeWith(c, r.policy.AuthorizeRemoval(r.outgoingContext(c), subjectOf(c), req.HouseId, req.UserId)) {
+		return
+	}
+	response, err := r.controllerClient.RemoveUserFromHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get battery status
+// @Description Get the battery level of a device by ID
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Success 200 {object} controlrpc.BatteryResponse
+// @Failure 403 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /devices/{id}/battery [get]
+func (r *RbmqHandler) GetBatteryStatus(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{DeviceId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.DeviceRequest{DeviceId: c.Param("id")}
+	response, err := r.controllerClient.GetBatteryStatus(r.outgoingContext(c), &req)
 	if err != nil {
 		r.logger.Println("ERROR FROM SERVER: ", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeRPCError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, response)
 }
+
+// streamRecheckInterval is how often a stream makes sure its session is still
+// active and follows the houses its user joined or left
+const streamRecheckInterval = 30 * time.Second
+
+// @Summary Stream device changes
+// @Description Push device state and battery changes of the caller's houses as Server-Sent Events. The stream follows the houses the caller joins or leaves and ends with the caller's session
+// @Produce text/event-stream
+// @Security ApiKeyAuth
+// @Success 200 {object} stream.Event
+// @Failure 401 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /devices/stream [get]
+func (r *RbmqHandler) StreamDevices(c *gin.Context) {
+	ctx := r.outgoingContext(c)
+	userId, sessionId := subjectOf(c), sessionOf(c)
+	houses, err := r.policy.HousesOf(ctx, userId)
+	if err != nil {
+		r.logger.Println("ERROR WHILE LISTING HOUSES: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	subscriber := r.hub.Subscribe(houses)
+	defer r.hub.Unsubscribe(subscriber)
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	recheck := time.NewTicker(streamRecheckInterval)
+	defer recheck.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-subscriber.Events:
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			// a comment line keeps proxies from closing an idle stream
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		case <-recheck.C:
+			return r.recheckStream(ctx, subscriber, userId, sessionId)
+		case <-ctx.Done():
+			return false
+		}
+	})
+}
+
+// recheckStream reports whether a stream may go on: its session must still be
+// active, after a logout or a revocation it ends. The houses it receives the
+// events of are brought in line with the memberships of its user.
+func (r *RbmqHandler) recheckStream(ctx context.Context, subscriber *stream.Subscriber, userId, sessionId string) bool {
+	active, err := r.sessions.Active(ctx, sessionId)
+	if err != nil {
+		r.logger.Println("ENDING STREAM, SESSION CHECK FAILED: ", err)
+		return false
+	}
+	if !active {
+		return false
+	}
+	houses, err := r.policy.HousesOf(ctx, userId)
+	if err != nil {
+		r.logger.Println("ENDING STREAM, LISTING HOUSES FAILED: ", err)
+		return false
+	}
+	r.hub.Resubscribe(subscriber, houses)
+	return true
+}
@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/shared/access"
+	controlrpc "github.com/ruziba3vich/smart-house/genprotos/controller_submodule"
+	"github.com/ruziba3vich/smart-house/internal/policy"
+)
+
+// @Summary Create a house
+// @Description Create a house owned by the caller
+// @Tags houses
+// @Accept json
+// @Produce json
+// @Param request body controlrpc.CreateHouseRequest true "House information"
+// @Security ApiKeyAuth
+// @Success 201 {object} controlrpc.House
+// @Failure 400 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses [post]
+func (r *RbmqHandler) CreateHouse(c *gin.Context) {
+	var req controlrpc.CreateHouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.OwnerId = subjectOf(c)
+	response, err := r.controllerClient.CreateHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// @Summary Get a house
+// @Description Get a house by ID
+// @Tags houses
+// @Produce json
+// @Param id path string true "House ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.House
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id} [get]
+func (r *RbmqHandler) GetHouse(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{HouseId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.HouseIdRequest{HouseId: c.Param("id")}
+	response, err := r.controllerClient.GetHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary List houses
+// @Description List the houses the caller belongs to
+// @Tags houses
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.ListHousesResponse
+// @Failure 500 {object} gin.H
+// @Router /houses [get]
+func (r *RbmqHandler) ListHouses(c *gin.Context) {
+	req := controlrpc.UserHousesRequest{UserId: subjectOf(c)}
+	response, err := r.controllerClient.GetUserHouses(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Update a house
+// @Description Rename or move a house
+// @Tags houses
+// @Accept json
+// @Produce json
+// @Param id path string true "House ID"
+// @Param request body controlrpc.UpdateHouseRequest true "House information"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.House
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id} [put]
+func (r *RbmqHandler) UpdateHouse(c *gin.Context) {
+	var req controlrpc.UpdateHouseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.HouseId = c.Param("id")
+	if !r.authorize(c, access.ActionUpdateHouse, policy.Resource{HouseId: req.HouseId}) {
+		return
+	}
+	response, err := r.controllerClient.UpdateHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Delete a house
+// @Description Delete a house by ID
+// @Tags houses
+// @Produce json
+// @Param id path string true "House ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.HouseResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id} [delete]
+func (r *RbmqHandler) DeleteHouse(c *gin.Context) {
+	if !r.authorize(c, access.ActionDeleteHouse, policy.Resource{HouseId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.HouseIdRequest{HouseId: c.Param("id")}
+	response, err := r.controllerClient.DeleteHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary List house members
+// @Description List the ids of the users that belong to a house
+// @Tags houses
+// @Produce json
+// @Param id path string true "House ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.HouseMembersResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id}/members [get]
+func (r *RbmqHandler) GetHouseMembers(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{HouseId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.HouseIdRequest{HouseId: c.Param("id")}
+	response, err := r.controllerClient.GetHouseMembers(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Add a house member
+// @Description Add a user to a house
+// @Tags houses
+// @Accept json
+// @Produce json
+// @Param id path string true "House ID"
+// @Param request body controlrpc.UserRequest true "User Request"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.HouseResponse
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id}/members [post]
+func (r *RbmqHandler) AddHouseMember(c *gin.Context) {
+	var req controlrpc.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.HouseId = c.Param("id")
+	if !r.authorizeWith(c, r.policy.AuthorizeGrant(r.outgoingContext(c), subjectOf(c), req.HouseId, req.UserId, req.Role)) {
+		return
+	}
+	response, err := r.controllerClient.AddUserToHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Remove a house member
+// @Description Remove a user from a house
+// @Tags houses
+// @Produce json
+// @Param id path string true "House ID"
+// @Param user_id path string true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.HouseResponse
+// @Failure 403 {object} gin.H
+// @Failure 404 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id}/members/{user_id} [delete]
+func (r *RbmqHandler) RemoveHouseMember(c *gin.Context) {
+	req := controlrpc.UserRequest{HouseId: c.Param("id"), UserId: c.Param("user_id")}
+	if !r.authorizeWith(c, r.policy.AuthorizeRemoval(r.outgoingContext(c), subjectOf(c), req.HouseId, req.UserId)) {
+		return
+	}
+	response, err := r.controllerClient.RemoveUserFromHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Change a member's role
+// @Description Give a member of a house another role
+// @Tags houses
+// @Accept json
+// @Produce json
+// @Param id path string true "House ID"
+// @Param user_id path string true "User ID"
+// @Param request body controlrpc.UserRequest true "Role to grant"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.HouseResponse
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id}/members/{user_id}/role [put]
+func (r *RbmqHandler) SetMemberRole(c *gin.Context) {
+	var req controlrpc.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.HouseId = c.Param("id")
+	req.UserId = c.Param("user_id")
+	if !r.authorizeWith(c, r.policy.AuthorizeGrant(r.outgoingContext(c), subjectOf(c), req.HouseId, req.UserId, req.Role)) {
+		return
+	}
+	response, err := r.controllerClient.SetMemberRole(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary List a user's houses
+// @Description List the houses a user belongs to
+// @Tags houses
+// @Produce json
+// @Param id path string true "User ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.ListHousesResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /users/{id}/houses [get]
+func (r *RbmqHandler) GetUserHouses(c *gin.Context) {
+	if !r.authorize(c, access.ActionOwnAccount, policy.Resource{UserId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.UserHousesRequest{UserId: c.Param("id")}
+	response, err := r.controllerClient.GetUserHouses(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/shared/access"
+	controlrpc "github.com/ruziba3vich/smart-house/genprotos/controller_submodule"
+	devicesrpc "github.com/ruziba3vich/smart-house/genprotos/devices_submodule"
+	"github.com/ruziba3vich/smart-house/internal/policy"
+)
+
+// @Summary Create a room
+// @Description Add a room to a house
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param id path string true "House ID"
+// @Param request body controlrpc.CreateRoomRequest true "Room information"
+// @Security ApiKeyAuth
+// @Success 201 {object} controlrpc.Room
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id}/rooms [post]
+func (r *RbmqHandler) CreateRoom(c *gin.Context) {
+	var req controlrpc.CreateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.HouseId = c.Param("id")
+	if !r.authorize(c, access.ActionManageRooms, policy.Resource{HouseId: req.HouseId}) {
+		return
+	}
+	response, err := r.controllerClient.CreateRoom(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// @Summary List rooms
+// @Description List the rooms of a house
+// @Tags rooms
+// @Produce json
+// @Param id path string true "House ID"
+// @Param zone query string false "Only rooms of this zone"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.ListRoomsResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id}/rooms [get]
+func (r *RbmqHandler) ListRooms(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{HouseId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.ListRoomsRequest{HouseId: c.Param("id"), Zone: c.Query("zone")}
+	response, err := r.controllerClient.ListRooms(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get a room
+// @Description Get a room by ID
+// @Tags rooms
+// @Produce json
+// @Param id path string true "Room ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.Room
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /rooms/{id} [get]
+func (r *RbmqHandler) GetRoom(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{RoomId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.RoomIdRequest{RoomId: c.Param("id")}
+	response, err := r.controllerClient.GetRoom(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Update a room
+// @Description Rename a room or move it to another zone
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param id path string true "Room ID"
+// @Param request body controlrpc.UpdateRoomRequest true "Room information"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.Room
+// @Failure 400 {object} gin.H
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /rooms/{id} [put]
+func (r *RbmqHandler) UpdateRoom(c *gin.Context) {
+	var req controlrpc.UpdateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.RoomId = c.Param("id")
+	if !r.authorize(c, access.ActionManageRooms, policy.Resource{RoomId: req.RoomId}) {
+		return
+	}
+	response, err := r.controllerClient.UpdateRoom(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Delete a room
+// @Description Delete a room; its devices stay in the house, unplaced
+// @Tags rooms
+// @Produce json
+// @Param id path string true "Room ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.HouseResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /rooms/{id} [delete]
+func (r *RbmqHandler) DeleteRoom(c *gin.Context) {
+	if !r.authorize(c, access.ActionManageRooms, policy.Resource{RoomId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.RoomIdRequest{RoomId: c.Param("id")}
+	response, err := r.controllerClient.DeleteRoom(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Turn off a room
+// @Description Turn off every device of a room
+// @Tags rooms
+// @Produce json
+// @Param id path string true "Room ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} controlrpc.DeviceResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /rooms/{id}/off [post]
+func (r *RbmqHandler) TurnRoomOff(c *gin.Context) {
+	if !r.authorize(c, access.ActionToggleDevice, policy.Resource{RoomId: c.Param("id")}) {
+		return
+	}
+	req := controlrpc.RoomIdRequest{RoomId: c.Param("id")}
+	response, err := r.controllerClient.TurnRoomOff(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Devices of a room
+// @Description List the devices placed in a room
+// @Tags rooms
+// @Produce json
+// @Param id path string true "Room ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} devicesprotos.GetAllDevicesResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /rooms/{id}/devices [get]
+func (r *RbmqHandler) GetDevicesByRoom(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{RoomId: c.Param("id")}) {
+		return
+	}
+	req := devicesrpc.GetDevicesByRoomRequest{RoomId: c.Param("id")}
+	response, err := r.devicesClient.GetDevicesByRoom(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Devices of a house
+// @Description List the devices of a house, whatever room they are in
+// @Tags houses
+// @Produce json
+// @Param id path string true "House ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} devicesprotos.GetAllDevicesResponse
+// @Failure 403 {object} gin.H
+// @Failure 500 {object} gin.H
+// @Router /houses/{id}/devices [get]
+func (r *RbmqHandler) GetDevicesByHouse(c *gin.Context) {
+	if !r.authorize(c, access.ActionViewHouse, policy.Resource{HouseId: c.Param("id")}) {
+		return
+	}
+	req := devicesrpc.GetDevicesByHouseRequest{HouseId: c.Param("id")}
+	response, err := r.devicesClient.GetDevicesByHouse(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
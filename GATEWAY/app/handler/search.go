@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	devicesrpc "github.com/ruziba3vich/smart-house/genprotos/devices_submodule"
+	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
+	models "github.com/ruziba3vich/smart-house/internal/modules"
+)
+
+// Search godoc
+// @Summary Search
+// @Description Search users by username or name, and the devices of the caller's houses by name, type or location. Administrators also search users by email and address, and are the only ones to see them
+// @Tags search
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param q query string true "Search query"
+// @Param prefix query bool false "Match the words starting with q, for autocomplete"
+// @Param limit query int false "Results per entity type, 10 by default and 50 at most"
+// @Success 200 {object} models.SearchResults
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /search [get]
+func (r *RbmqHandler) Search(c *gin.Context) {
+	query := c.Query("q")
+	if len(query) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: "q is required"})
+		return
+	}
+	prefix, _ := strconv.ParseBool(c.Query("prefix"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	ctx := r.outgoingContext(c)
+	users, err := r.usersClient.Search(ctx, &usersprotos.SearchRequest{
+		Query:  query,
+		Prefix: prefix,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		writeRPCError(c, err)
+		return
+	}
+	results := models.SearchResults{
+		Users:   []*models.User{},
+		Devices: []*devicesrpc.Device{},
+	}
+	for _, found := range users.Users {
+		var user models.User
+		user.FromProtoUser(found)
+		results.Users = append(results.Users, &user)
+	}
+
+	// devices are only searched in the houses the caller belongs to
+	houses, err := r.policy.HousesOf(ctx, subjectOf(c))
+	if err != nil {
+		r.logger.Println("ERROR WHILE CHECKING ACCESS: ", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if len(houses) > 0 {
+		devices, err := r.devicesClient.Search(ctx, &devicesrpc.SearchDevicesRequest{
+			Query:    query,
+			Prefix:   prefix,
+			Limit:    int32(limit),
+			HouseIds: houses,
+		})
+		if err != nil {
+			r.logger.Println("ERROR FROM SERVER: ", err)
+			writeRPCError(c, err)
+			return
+		}
+		results.Devices = append(results.Devices, devices.Devices...)
+	}
+	c.JSON(http.StatusOK, results)
+}
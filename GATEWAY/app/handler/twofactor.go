@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
+	models "github.com/ruziba3vich/smart-house/internal/modules"
+)
+
+// @Summary Complete a login
+// @Description Exchange the challenge of a login with two-factor authentication on, and a TOTP or recovery code, for the tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body usersprotos.CompleteLoginRequest true "Login challenge and code"
+// @Success 201 {object} models.UserResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 429 {object} models.ErrorResponse
+// @Router /users/login/2fa [post]
+func (r *RbmqHandler) CompleteLogin(c *gin.Context) {
+	var req usersprotos.CompleteLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	response, err := r.usersClient.CompleteLogin(c, &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		r.writeLoginError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, models.UserResponse{Response: response})
+}
+
+// @Summary Enroll in two-factor authentication
+// @Description Create a TOTP secret for the caller. The otpauth URI is what authenticator apps scan; 2FA is on once a code is confirmed
+// @Tags auth
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} usersprotos.TOTPEnrollment
+// @Failure 400 {object} models.ErrorResponse
+// @Router /users/2fa/enroll [post]
+func (r *RbmqHandler) EnrollTOTP(c *gin.Context) {
+	req := usersprotos.TOTPRequest{UserId: subjectOf(c)}
+	response, err := r.usersClient.EnrollTOTP(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Confirm two-factor authentication
+// @Description Turn 2FA on with a code from the authenticator. The recovery codes in the response are not shown again
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body usersprotos.TOTPRequest true "TOTP code"
+// @Security ApiKeyAuth
+// @Success 200 {object} usersprotos.RecoveryCodes
+// @Failure 400 {object} models.ErrorResponse
+// @Router /users/2fa/confirm [post]
+func (r *RbmqHandler) ConfirmTOTP(c *gin.Context) {
+	var req usersprotos.TOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	req.UserId = subjectOf(c)
+	response, err := r.usersClient.ConfirmTOTP(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Disable two-factor authentication
+// @Description Turn 2FA off with a TOTP or recovery code
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body usersprotos.TOTPRequest true "TOTP or recovery code"
+// @Security ApiKeyAuth
+// @Success 200 {object} usersprotos.Response
+// @Failure 400 {object} models.ErrorResponse
+// @Router /users/2fa/disable [post]
+func (r *RbmqHandler) DisableTOTP(c *gin.Context) {
+	var req usersprotos.TOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		r.logger.Println("ERROR WHILE BINDING DATA: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	req.UserId = subjectOf(c)
+	response, err := r.usersClient.DisableTOTP(r.outgoingContext(c), &req)
+	if err != nil {
+		r.logger.Println("ERROR FROM SERVER: ", err)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{Error: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
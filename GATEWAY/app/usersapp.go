@@ -2,8 +2,11 @@ package app
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 	"github.com/ruziba3vich/smart-house/app/handler"
 	"github.com/ruziba3vich/smart-house/internal/config"
+	"github.com/ruziba3vich/smart-house/internal/ratelimit"
+	"github.com/ruziba3vich/smart-house/internal/sessions"
 	"github.com/ruziba3vich/smart-house/internal/utils"
 	middleware "github.com/ruziba3vich/smart-house/midd-ware"
 	swaggerFiles "github.com/swaggo/files"
@@ -22,24 +25,75 @@ func New(rbmqHandler *handler.RbmqHandler) *APP {
 	}
 }
 
-func (a *APP) RUN(cfg *config.Config, t *utils.TokenGenerator) error {
+func (a *APP) RUN(cfg *config.Config, t *utils.TokenGenerator, sessionStore *sessions.Store, redisDb *redis.Client) error {
 	router := gin.Default()
+	auth := middleware.AuthMiddleware(t, sessionStore)
+	loginLimit := middleware.RateLimitByIP(ratelimit.NewSlidingWindow(redisDb, "login_ip", cfg.LoginIPLimit, cfg.LoginLimitWindow))
+	registerLimit := middleware.RateLimitByIP(ratelimit.NewSlidingWindow(redisDb, "register_ip", cfg.RegisterIPLimit, cfg.RegisterLimitWindow))
+	// control covers the commands that reach devices, devices the rest of
+	// the device, house and room API
+	usersLimit := middleware.RateLimit(ratelimit.NewTokenBucket(redisDb, "users", cfg.UsersRateLimit, cfg.UsersRateBurst))
+	devicesLimit := middleware.RateLimit(ratelimit.NewTokenBucket(redisDb, "devices", cfg.DevicesRateLimit, cfg.DevicesRateBurst))
+	controlLimit := middleware.RateLimit(ratelimit.NewTokenBucket(redisDb, "control", cfg.ControlRateLimit, cfg.ControlRateBurst))
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/.well-known/jwks.json", a.rbmqHandler.JWKS)
+	router.GET("/search", auth, devicesLimit, a.rbmqHandler.Search)
 
 	usersRouter := router.Group("/users")
-	usersRouter.POST("/register", a.rbmqHandler.RegisterUser)
-	usersRouter.POST("/login", a.rbmqHandler.LoginUser)
-	usersRouter.PUT("/:id", middleware.AuthMiddleware(t), a.rbmqHandler.UpdateUser)
-	usersRouter.DELETE("/delete/:id", middleware.AuthMiddleware(t), a.rbmqHandler.DeleteUserById)
-	usersRouter.GET("/", middleware.AuthMiddleware(t), a.rbmqHandler.GetAllUsers)
+	usersRouter.POST("/register", usersLimit, registerLimit, a.rbmqHandler.RegisterUser)
+	usersRouter.POST("/login", usersLimit, loginLimit, a.rbmqHandler.LoginUser)
+	usersRouter.POST("/login/2fa", usersLimit, loginLimit, a.rbmqHandler.CompleteLogin)
+	usersRouter.POST("/refresh", usersLimit, a.rbmqHandler.RefreshToken)
+	usersRouter.GET("/verify", usersLimit, a.rbmqHandler.VerifyEmail)
+	usersRouter.POST("/password/forgot", usersLimit, a.rbmqHandler.ForgotPassword)
+	usersRouter.POST("/password/reset", usersLimit, a.rbmqHandler.ResetPassword)
+	usersRouter.POST("/logout", auth, usersLimit, a.rbmqHandler.Logout)
+	usersRouter.POST("/logout-all", auth, usersLimit, a.rbmqHandler.LogoutAll)
+	usersRouter.POST("/2fa/enroll", auth, usersLimit, a.rbmqHandler.EnrollTOTP)
+	usersRouter.POST("/2fa/confirm", auth, usersLimit, a.rbmqHandler.ConfirmTOTP)
+	usersRouter.POST("/2fa/disable", auth, usersLimit, a.rbmqHandler.DisableTOTP)
+	usersRouter.POST("/unlock", auth, usersLimit, a.rbmqHandler.UnlockAccount)
+	usersRouter.PUT("/:id", auth, usersLimit, a.rbmqHandler.UpdateUser)
+	usersRouter.DELETE("/delete/:id", auth, usersLimit, a.rbmqHandler.DeleteUserById)
+	usersRouter.GET("/", auth, usersLimit, a.rbmqHandler.GetAllUsers)
+	usersRouter.POST("/add", auth, usersLimit, a.rbmqHandler.AddUserToHouse)
+	usersRouter.POST("/remove", auth, usersLimit, a.rbmqHandler.RemoveUserFromHouse)
+	usersRouter.GET("/:id/houses", auth, usersLimit, a.rbmqHandler.GetUserHouses)
 
 	devicesRouter := router.Group("/devices")
-	devicesRouter.POST("/", middleware.AuthMiddleware(t), a.rbmqHandler.CreateDevice)
-	devicesRouter.PUT("/:id", middleware.AuthMiddleware(t), a.rbmqHandler.UpdateDevice)
-	devicesRouter.GET("/:id", middleware.AuthMiddleware(t), a.rbmqHandler.GetDevice)
-	devicesRouter.DELETE("/:id", middleware.AuthMiddleware(t), a.rbmqHandler.DeleteDevice)
-	devicesRouter.GET("/", middleware.AuthMiddleware(t), a.rbmqHandler.GetAllDevices)
+	devicesRouter.POST("/", auth, devicesLimit, a.rbmqHandler.CreateDevice)
+	devicesRouter.PUT("/:id", auth, devicesLimit, a.rbmqHandler.UpdateDevice)
+	devicesRouter.GET("/:id", auth, devicesLimit, a.rbmqHandler.GetDevice)
+	devicesRouter.DELETE("/:id", auth, devicesLimit, a.rbmqHandler.DeleteDevice)
+	devicesRouter.GET("/", auth, devicesLimit, a.rbmqHandler.GetAllDevices)
+	devicesRouter.GET("/types", auth, devicesLimit, a.rbmqHandler.ListDeviceTypes)
+	devicesRouter.POST("/on", auth, controlLimit, a.rbmqHandler.TurnDeviceOn)
+	devicesRouter.POST("/off", auth, controlLimit, a.rbmqHandler.TurnDeviceOff)
+	devicesRouter.PATCH("/:id/state", auth, controlLimit, a.rbmqHandler.SetDeviceState)
+	devicesRouter.GET("/stream", auth, devicesLimit, a.rbmqHandler.StreamDevices)
+	devicesRouter.GET("/:id/battery", auth, devicesLimit, a.rbmqHandler.GetBatteryStatus)
+
+	housesRouter := router.Group("/houses")
+	housesRouter.POST("/", auth, devicesLimit, a.rbmqHandler.CreateHouse)
+	housesRouter.GET("/", auth, devicesLimit, a.rbmqHandler.ListHouses)
+	housesRouter.GET("/:id", auth, devicesLimit, a.rbmqHandler.GetHouse)
+	housesRouter.PUT("/:id", auth, devicesLimit, a.rbmqHandler.UpdateHouse)
+	housesRouter.DELETE("/:id", auth, devicesLimit, a.rbmqHandler.DeleteHouse)
+	housesRouter.GET("/:id/members", auth, devicesLimit, a.rbmqHandler.GetHouseMembers)
+	housesRouter.POST("/:id/members", auth, devicesLimit, a.rbmqHandler.AddHouseMember)
+	housesRouter.DELETE("/:id/members/:user_id", auth, devicesLimit, a.rbmqHandler.RemoveHouseMember)
+	housesRouter.PUT("/:id/members/:user_id/role", auth, devicesLimit, a.rbmqHandler.SetMemberRole)
+	housesRouter.POST("/:id/rooms", auth, devicesLimit, a.rbmqHandler.CreateRoom)
+	housesRouter.GET("/:id/rooms", auth, devicesLimit, a.rbmqHandler.ListRooms)
+	housesRouter.GET("/:id/devices", auth, devicesLimit, a.rbmqHandler.GetDevicesByHouse)
+
+	roomsRouter := router.Group("/rooms")
+	roomsRouter.GET("/:id", auth, devicesLimit, a.rbmqHandler.GetRoom)
+	roomsRouter.PUT("/:id", auth, devicesLimit, a.rbmqHandler.UpdateRoom)
+	roomsRouter.DELETE("/:id", auth, devicesLimit, a.rbmqHandler.DeleteRoom)
+	roomsRouter.POST("/:id/off", auth, controlLimit, a.rbmqHandler.TurnRoomOff)
+	roomsRouter.GET("/:id/devices", auth, devicesLimit, a.rbmqHandler.GetDevicesByRoom)
 
 	return router.Run(cfg.Port)
 }
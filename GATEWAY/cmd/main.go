@@ -6,21 +6,26 @@ import (
 	"os"
 	"time"
 
+	"github.com/go-redis/redis/v8"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/ruziba3vich/smart-house/app"
 	"github.com/ruziba3vich/smart-house/app/handler"
 
+	controlrpc "github.com/ruziba3vich/smart-house/genprotos/controller_submodule"
 	devicesrpc "github.com/ruziba3vich/smart-house/genprotos/devices_submodule"
 	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
 	"github.com/ruziba3vich/smart-house/internal/config"
 	"github.com/ruziba3vich/smart-house/internal/msgbroker"
+	"github.com/ruziba3vich/smart-house/internal/ratelimit"
+	"github.com/ruziba3vich/smart-house/internal/sessions"
+	"github.com/ruziba3vich/smart-house/internal/stream"
 	"github.com/ruziba3vich/smart-house/internal/utils"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
@@ -42,26 +47,42 @@ func main() {
 	}
 	defer ch.Close()
 
-	registration, rq, err := getMessages("create", ch)
+	rq, err := getQueue("create", ch)
 	if err != nil {
-		logger.Fatalf("Error getting registration messages: %v", err)
+		logger.Fatalf("Error declaring registration queue: %v", err)
 	}
 
-	updates, uq, err := getMessages("update", ch)
+	uq, err := getQueue("update", ch)
 	if err != nil {
-		logger.Fatalf("Error getting update messages: %v", err)
+		logger.Fatalf("Error declaring update queue: %v", err)
 	}
 
-	deletions, dq, err := getMessages("delete", ch)
+	dq, err := getQueue("delete", ch)
 	if err != nil {
-		logger.Fatalf("Error getting deletion messages: %v", err)
+		logger.Fatalf("Error declaring deletion queue: %v", err)
 	}
 
-	msgBroker, err := msgbroker.NewRPCClient(ch, 10*time.Second, registration, updates, deletions, ctx)
+	msgBroker, err := msgbroker.NewRPCClient(ch, 10*time.Second, logger, ctx)
 	if err != nil {
 		logger.Fatalf("Error creating RPC client: %v", err)
 	}
 
+	streamCh, err := conn.Channel()
+	if err != nil {
+		logger.Fatalf("Error opening channel: %v", err)
+	}
+	defer streamCh.Close()
+
+	hub, err := stream.NewHub(streamCh, config.EventsExchange, logger)
+	if err != nil {
+		logger.Fatalf("Error creating device stream: %v", err)
+	}
+	go func() {
+		if err := hub.Run(ctx); err != nil && ctx.Err() == nil {
+			logger.Fatalf("Device stream stopped: %v", err)
+		}
+	}()
+
 	usersConn, err := grpc.Dial("localhost:7000", grpc.WithTransportCredentials(insecure.NewCredentials()))
 	if err != nil {
 		logger.Fatalf("Failed to connect to users service: %v", err)
@@ -74,21 +95,35 @@ func main() {
 	}
 	defer devicesConn.Close()
 
+	controllerConn, err := grpc.Dial(config.ControllerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		logger.Fatalf("Failed to connect to controller service: %v", err)
+	}
+	defer controllerConn.Close()
+
 	usersClient := usersprotos.NewUsersServiceClient(usersConn)
 	devicesClient := devicesrpc.NewDeviceServiceClient(devicesConn)
+	controllerClient := controlrpc.NewControllerServiceClient(controllerConn)
+
+	redisDb := redis.NewClient(&redis.Options{
+		Addr: config.RedisURI,
+		DB:   0,
+	})
+	sessionStore := sessions.New(redisDb)
+	accountLimiter := ratelimit.NewSlidingWindow(redisDb, "login_account", config.LoginAccountLimit, config.LoginLimitWindow)
 
-	
+	tokenizer := utils.NewTokenGenerator(utils.NewKeyCache(usersClient, config.JWKSCacheTTL))
 
 	app := app.New(
-		handler.NewRbmqHandler(logger, msgBroker, utils.NewTokenGenerator(config), usersClient, devicesClient, config, rq, uq, dq),
+		handler.NewRbmqHandler(logger, msgBroker, tokenizer, usersClient, devicesClient, controllerClient, hub, accountLimiter, sessionStore, config, rq, uq, dq),
 	)
-	if err := app.RUN(config, utils.NewTokenGenerator(config)); err != nil {
+	if err := app.RUN(config, tokenizer, sessionStore, redisDb); err != nil {
 		logger.Fatalf("Application error: %v", err)
 	}
 }
 
-func getMessages(queueName string, ch *amqp.Channel) (<-chan amqp.Delivery, amqp.Queue, error) {
-	q, err := ch.QueueDeclare(
+func getQueue(queueName string, ch *amqp.Channel) (amqp.Queue, error) {
+	return ch.QueueDeclare(
 		queueName, // name
 		true,      // durable
 		false,     // delete when unused
@@ -96,18 +131,4 @@ func getMessages(queueName string, ch *amqp.Channel) (<-chan amqp.Delivery, amqp
 		false,     // no-wait
 		nil,       // arguments
 	)
-	if err != nil {
-		return nil, q, err
-	}
-
-	messages, err := ch.Consume(
-		queueName, // queue
-		"",        // consumer
-		true,      // auto-ack
-		false,     // exclusive
-		false,     // no-local
-		false,     // no-wait
-		nil,       // args
-	)
-	return messages, q, err
 }
@@ -19,11 +19,15 @@ import (
 const _ = grpc.SupportPackageIsVersion8
 
 const (
-	DeviceService_CreateDevice_FullMethodName  = "/devices.DeviceService/CreateDevice"
-	DeviceService_UpdateDevice_FullMethodName  = "/devices.DeviceService/UpdateDevice"
-	DeviceService_GetDevice_FullMethodName     = "/devices.DeviceService/GetDevice"
-	DeviceService_DeleteDevice_FullMethodName  = "/devices.DeviceService/DeleteDevice"
-	DeviceService_GetAllDevices_FullMethodName = "/devices.DeviceService/GetAllDevices"
+	DeviceService_CreateDevice_FullMethodName      = "/devices.DeviceService/CreateDevice"
+	DeviceService_UpdateDevice_FullMethodName      = "/devices.DeviceService/UpdateDevice"
+	DeviceService_GetDevice_FullMethodName         = "/devices.DeviceService/GetDevice"
+	DeviceService_DeleteDevice_FullMethodName      = "/devices.DeviceService/DeleteDevice"
+	DeviceService_GetAllDevices_FullMethodName     = "/devices.DeviceService/GetAllDevices"
+	DeviceService_GetDevicesByRoom_FullMethodName  = "/devices.DeviceService/GetDevicesByRoom"
+	DeviceService_GetDevicesByHouse_FullMethodName = "/devices.DeviceService/GetDevicesByHouse"
+	DeviceService_Search_FullMethodName            = "/devices.DeviceService/Search"
+	DeviceService_ListDeviceTypes_FullMethodName   = "/devices.DeviceService/ListDeviceTypes"
 )
 
 // DeviceServiceClient is the client API for DeviceService service.
@@ -35,6 +39,10 @@ type DeviceServiceClient interface {
 	GetDevice(ctx context.Context, in *GetDeviceRequest, opts ...grpc.CallOption) (*GetDeviceResponse, error)
 	DeleteDevice(ctx context.Context, in *DeleteDeviceRequest, opts ...grpc.CallOption) (*DeleteDeviceResponse, error)
 	GetAllDevices(ctx context.Context, in *GetAllDevicesRequest, opts ...grpc.CallOption) (*GetAllDevicesResponse, error)
+	GetDevicesByRoom(ctx context.Context, in *GetDevicesByRoomRequest, opts ...grpc.CallOption) (*GetAllDevicesResponse, error)
+	GetDevicesByHouse(ctx context.Context, in *GetDevicesByHouseRequest, opts ...grpc.CallOption) (*GetAllDevicesResponse, error)
+	Search(ctx context.Context, in *SearchDevicesRequest, opts ...grpc.CallOption) (*GetAllDevicesResponse, error)
+	ListDeviceTypes(ctx context.Context, in *ListDeviceTypesRequest, opts ...grpc.CallOption) (*ListDeviceTypesResponse, error)
 }
 
 type deviceServiceClient struct {
@@ -95,6 +103,46 @@ func (c *deviceServiceClient) GetAllDevices(ctx context.Context, in *GetAllDevic
 	return out, nil
 }
 
+func (c *deviceServiceClient) GetDevicesByRoom(ctx context.Context, in *GetDevicesByRoomRequest, opts ...grpc.CallOption) (*GetAllDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetAllDevicesResponse)
+	err := c.cc.Invoke(ctx, DeviceService_GetDevicesByRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) GetDevicesByHouse(ctx context.Context, in *GetDevicesByHouseRequest, opts ...grpc.CallOption) (*GetAllDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetAllDevicesResponse)
+	err := c.cc.Invoke(ctx, DeviceService_GetDevicesByHouse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) Search(ctx context.Context, in *SearchDevicesRequest, opts ...grpc.CallOption) (*GetAllDevicesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetAllDevicesResponse)
+	err := c.cc.Invoke(ctx, DeviceService_Search_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceServiceClient) ListDeviceTypes(ctx context.Context, in *ListDeviceTypesRequest, opts ...grpc.CallOption) (*ListDeviceTypesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDeviceTypesResponse)
+	err := c.cc.Invoke(ctx, DeviceService_ListDeviceTypes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DeviceServiceServer is the server API for DeviceService service.
 // All implementations must embed UnimplementedDeviceServiceServer
 // for forward compatibility
@@ -104,6 +152,10 @@ type DeviceServiceServer interface {
 	GetDevice(context.Context, *GetDeviceRequest) (*GetDeviceResponse, error)
 	DeleteDevice(context.Context, *DeleteDeviceRequest) (*DeleteDeviceResponse, error)
 	GetAllDevices(context.Context, *GetAllDevicesRequest) (*GetAllDevicesResponse, error)
+	GetDevicesByRoom(context.Context, *GetDevicesByRoomRequest) (*GetAllDevicesResponse, error)
+	GetDevicesByHouse(context.Context, *GetDevicesByHouseRequest) (*GetAllDevicesResponse, error)
+	Search(context.Context, *SearchDevicesRequest) (*GetAllDevicesResponse, error)
+	ListDeviceTypes(context.Context, *ListDeviceTypesRequest) (*ListDeviceTypesResponse, error)
 	mustEmbedUnimplementedDeviceServiceServer()
 }
 
@@ -126,6 +178,18 @@ func (UnimplementedDeviceServiceServer) DeleteDevice(context.Context, *DeleteDev
 func (UnimplementedDeviceServiceServer) GetAllDevices(context.Context, *GetAllDevicesRequest) (*GetAllDevicesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAllDevices not implemented")
 }
+func (UnimplementedDeviceServiceServer) GetDevicesByRoom(context.Context, *GetDevicesByRoomRequest) (*GetAllDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDevicesByRoom not implemented")
+}
+func (UnimplementedDeviceServiceServer) GetDevicesByHouse(context.Context, *GetDevicesByHouseRequest) (*GetAllDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDevicesByHouse not implemented")
+}
+func (UnimplementedDeviceServiceServer) Search(context.Context, *SearchDevicesRequest) (*GetAllDevicesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedDeviceServiceServer) ListDeviceTypes(context.Context, *ListDeviceTypesRequest) (*ListDeviceTypesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeviceTypes not implemented")
+}
 func (UnimplementedDeviceServiceServer) mustEmbedUnimplementedDeviceServiceServer() {}
 
 // UnsafeDeviceServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -229,6 +293,78 @@ func _DeviceService_GetAllDevices_Handler(srv interface{}, ctx context.Context,
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DeviceService_GetDevicesByRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDevicesByRoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).GetDevicesByRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_GetDevicesByRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).GetDevicesByRoom(ctx, req.(*GetDevicesByRoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_GetDevicesByHouse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDevicesByHouseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).GetDevicesByHouse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_GetDevicesByHouse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).GetDevicesByHouse(ctx, req.(*GetDevicesByHouseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_Search_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchDevicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).Search(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_Search_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).Search(ctx, req.(*SearchDevicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceService_ListDeviceTypes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeviceTypesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceServiceServer).ListDeviceTypes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceService_ListDeviceTypes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceServiceServer).ListDeviceTypes(ctx, req.(*ListDeviceTypesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // DeviceService_ServiceDesc is the grpc.ServiceDesc for DeviceService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -256,6 +392,22 @@ var DeviceService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAllDevices",
 			Handler:    _DeviceService_GetAllDevices_Handler,
 		},
+		{
+			MethodName: "GetDevicesByRoom",
+			Handler:    _DeviceService_GetDevicesByRoom_Handler,
+		},
+		{
+			MethodName: "GetDevicesByHouse",
+			Handler:    _DeviceService_GetDevicesByHouse_Handler,
+		},
+		{
+			MethodName: "Search",
+			Handler:    _DeviceService_Search_Handler,
+		},
+		{
+			MethodName: "ListDeviceTypes",
+			Handler:    _DeviceService_ListDeviceTypes_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "devices_submodule/devices.proto",
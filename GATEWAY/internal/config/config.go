@@ -3,16 +3,36 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Port        string
-	Protocol    string
-	secretKey   string
-	rabbitMqUri string
-	ContentType string
+	Port              string
+	Protocol          string
+	rabbitMqUri       string
+	ContentType       string
+	ControllerAddress string
+	EventsExchange    string
+	RedisURI          string
+	JWKSCacheTTL      time.Duration
+	// the sliding-window limits on logging in, per client IP and per
+	// account, and on registering, per client IP
+	LoginIPLimit        int
+	LoginAccountLimit   int
+	LoginLimitWindow    time.Duration
+	RegisterIPLimit     int
+	RegisterLimitWindow time.Duration
+	// the token-bucket limits on the API, per user or per client IP: the
+	// requests a minute a bucket refills at and the burst it holds
+	UsersRateLimit   int
+	UsersRateBurst   int
+	DevicesRateLimit int
+	DevicesRateBurst int
+	ControlRateLimit int
+	ControlRateBurst int
 }
 
 // LoadConfig reads configuration from environment variables or .env file
@@ -22,11 +42,25 @@ func LoadConfig() (*Config, error) {
 	}
 
 	return &Config{
-		Port:        getEnv("PORT", "8080"),
-		Protocol:    getEnv("PROTOCOL", "tcp"),
-		ContentType: getEnv("CONTENT_TYPE", "application/json"),
-		secretKey:   getEnv("SECRET_KEY", "prodonik"),
-		rabbitMqUri: getEnv("RABBITMQ_URI", "amqp://rabbitmq:5672"),
+		Port:                getEnv("PORT", "8080"),
+		Protocol:            getEnv("PROTOCOL", "tcp"),
+		ContentType:         getEnv("CONTENT_TYPE", "application/json"),
+		ControllerAddress:   getEnv("CONTROLLER_ADDRESS", "localhost:7002"),
+		EventsExchange:      getEnv("EVENTS_EXCHANGE", "smart_house.events"),
+		RedisURI:            getEnv("REDIS_URI", "redis:6379"),
+		JWKSCacheTTL:        getEnvDuration("JWKS_CACHE_TTL", 10*time.Minute),
+		LoginIPLimit:        getEnvInt("LOGIN_IP_LIMIT", 20),
+		LoginAccountLimit:   getEnvInt("LOGIN_ACCOUNT_LIMIT", 10),
+		LoginLimitWindow:    getEnvDuration("LOGIN_LIMIT_WINDOW", 15*time.Minute),
+		RegisterIPLimit:     getEnvInt("REGISTER_IP_LIMIT", 5),
+		RegisterLimitWindow: getEnvDuration("REGISTER_LIMIT_WINDOW", time.Hour),
+		UsersRateLimit:      getEnvInt("USERS_RATE_LIMIT", 60),
+		UsersRateBurst:      getEnvInt("USERS_RATE_BURST", 20),
+		DevicesRateLimit:    getEnvInt("DEVICES_RATE_LIMIT", 120),
+		DevicesRateBurst:    getEnvInt("DEVICES_RATE_BURST", 40),
+		ControlRateLimit:    getEnvInt("CONTROL_RATE_LIMIT", 30),
+		ControlRateBurst:    getEnvInt("CONTROL_RATE_BURST", 10),
+		rabbitMqUri:         getEnv("RABBITMQ_URI", "amqp://rabbitmq:5672"),
 	}, nil
 }
 
@@ -38,10 +72,28 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func (c *Config) GetSecretKey() string {
-	return c.secretKey
-}
-
 func (c *Config) GetRabbitMqURI() string {
 	return c.rabbitMqUri
 }
+
+// Helper function to get an integer environment variable with a fallback value
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %d\n", key, fallback)
+	}
+	return fallback
+}
+
+// Helper function to get a duration environment variable with a fallback value
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %s\n", key, fallback)
+	}
+	return fallback
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"encoding/json"
+
+	devicesrpc "github.com/ruziba3vich/smart-house/genprotos/devices_submodule"
 	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -9,8 +12,8 @@ type (
 	User struct {
 		Id       primitive.ObjectID `bson:"_id" json:"id"`
 		Username string             `bson:"username" json:"username"`
-		Email    string             `bson:"email" json:"email"`
-		Password string             `bson:"password" json:"password"`
+		Email    string             `bson:"email" json:"email,omitempty"`
+		Password string             `bson:"password" json:"password,omitempty"`
 		Profile  Profile            `bson:"profile" json:"profile"`
 		Deleted  bool               `bson:"deleted" json:"deleted"`
 		// Method   Method
@@ -18,7 +21,7 @@ type (
 
 	Profile struct {
 		Name    string `bson:"name" json:"name"`
-		Address string `bson:"address" json:"address"`
+		Address string `bson:"address" json:"address,omitempty"`
 	}
 
 	GetByFieldRequest struct {
@@ -30,6 +33,11 @@ type (
 		UserId string `json:"user_id"`
 	}
 
+	// UnlockAccountRequest names the account to unlock by its email
+	UnlockAccountRequest struct {
+		Email string `json:"email" binding:"required"`
+	}
+
 	ErrorResponse struct {
 		Error string `json:"message"`
 	}
@@ -37,6 +45,36 @@ type (
 	UserResponse struct {
 		Response interface{} `json:"response"`
 	}
+
+	// UsersPage is a page of GET /users; next_cursor is passed as the cursor
+	// of the next request and is empty on the last page
+	UsersPage struct {
+		Users      []*User `json:"users"`
+		NextCursor string  `json:"next_cursor"`
+		Total      int64   `json:"total"`
+	}
+
+	// SearchResults is what GET /search finds, grouped by entity type
+	SearchResults struct {
+		Users   []*User              `json:"users"`
+		Devices []*devicesrpc.Device `json:"devices"`
+	}
+
+	// Reply is what the USERS and DEVICES consumers publish back to the
+	// ReplyTo queue of a command once it has been handled.
+	Reply struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Error   string          `json:"error,omitempty"`
+		Data    json.RawMessage `json:"data,omitempty"`
+	}
+)
+
+const (
+	ReplyStatusSuccess    = "success"
+	ReplyStatusError      = "error"
+	ReplyStatusBadRequest = "bad_request"
+	ReplyStatusForbidden  = "forbidden"
 )
 
 func (u *User) Update(obj *usersprotos.UpdateUserReuqest) {
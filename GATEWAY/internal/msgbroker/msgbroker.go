@@ -2,39 +2,104 @@ package msgbroker
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	models "github.com/ruziba3vich/smart-house/internal/modules"
+	"google.golang.org/grpc/metadata"
 )
 
+// ActorHeader names the user a command is issued on behalf of, both in gRPC
+// metadata and in AMQP message headers.
+const ActorHeader = "x-actor-id"
+
+// ErrTimeout is returned by Call when no reply arrived in time.
+var ErrTimeout = errors.New("timed out waiting for a reply")
+
+// MsgBroker is an RPC-over-AMQP client: every request is published with a
+// fresh correlation id and the caller blocks until the consumer publishes a
+// reply carrying the same id to this client's private reply queue.
 type MsgBroker struct {
-	ch           *amqp.Channel
-	timeoutCh    <-chan time.Time
-	Registration <-chan amqp.Delivery
-	Updates      <-chan amqp.Delivery
-	Deletes      <-chan amqp.Delivery
-	ctx          context.Context
+	ch         *amqp.Channel
+	timeout    time.Duration
+	replyQueue amqp.Queue
+	pending    map[string]chan amqp.Delivery
+	mu         sync.Mutex
+	logger     *log.Logger
+	ctx        context.Context
 }
 
-func NewRPCClient(ch *amqp.Channel, timeout time.Duration, Registration <-chan amqp.Delivery, Updates <-chan amqp.Delivery, Deletes <-chan amqp.Delivery, ctx context.Context) (*MsgBroker, error) {
-	timeoutCh := time.After(timeout)
-	return &MsgBroker{
-		ch:           ch,
-		timeoutCh:    timeoutCh,
-		Registration: Registration,
-		Updates:      Updates,
-		Deletes:      Deletes,
-		ctx:          ctx,
-	}, nil
+func NewRPCClient(ch *amqp.Channel, timeout time.Duration, logger *log.Logger, ctx context.Context) (*MsgBroker, error) {
+	replyQueue, err := ch.QueueDeclare(
+		"",    // name, let the server generate one
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare reply queue: %s", err.Error())
+	}
+
+	replies, err := ch.Consume(
+		replyQueue.Name, // queue
+		"",              // consumer
+		true,            // auto-ack
+		true,            // exclusive
+		false,           // no-local
+		false,           // no-wait
+		nil,             // args
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume reply queue: %s", err.Error())
+	}
+
+	m := &MsgBroker{
+		ch:         ch,
+		timeout:    timeout,
+		replyQueue: replyQueue,
+		pending:    make(map[string]chan amqp.Delivery),
+		logger:     logger,
+		ctx:        ctx,
+	}
+	go m.dispatchReplies(replies)
+	return m, nil
 }
 
-func (m *MsgBroker) PublishToQueue(messages <-chan amqp.Delivery, body []byte, q amqp.Queue, replyToQueue, contentType string) error {
+// Call publishes body to q and waits for the matching reply. The wait is
+// bounded by the client timeout and by ctx, whichever ends first.
+func (m *MsgBroker) Call(ctx context.Context, body []byte, q amqp.Queue, contentType string) (*models.Reply, error) {
 	corrId := uuid.New().String()
-	fmt.Println("Generated a new CorrelationId:", corrId)
 
-	return m.ch.Publish(
+	waiter := make(chan amqp.Delivery, 1)
+	m.mu.Lock()
+	m.pending[corrId] = waiter
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		delete(m.pending, corrId)
+		m.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+
+	var headers amqp.Table
+	if md, ok := metadata.FromOutgoingContext(ctx); ok {
+		if actor := md.Get(ActorHeader); len(actor) > 0 {
+			headers = amqp.Table{ActorHeader: actor[0]}
+		}
+	}
+
+	err := m.ch.PublishWithContext(
+		ctx,
 		"",     // exchange
 		q.Name, // routing key
 		false,  // mandatory
@@ -42,8 +107,41 @@ func (m *MsgBroker) PublishToQueue(messages <-chan amqp.Delivery, body []byte, q
 		amqp.Publishing{
 			ContentType:   contentType,
 			CorrelationId: corrId,
-			ReplyTo:       replyToQueue,
+			Headers:       headers,
+			ReplyTo:       m.replyQueue.Name,
 			Body:          body,
 		},
 	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish to %s: %s", q.Name, err.Error())
+	}
+
+	select {
+	case d := <-waiter:
+		var reply models.Reply
+		if err := json.Unmarshal(d.Body, &reply); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal reply: %s", err.Error())
+		}
+		return &reply, nil
+	case <-ctx.Done():
+		return nil, ErrTimeout
+	case <-m.ctx.Done():
+		return nil, m.ctx.Err()
+	}
+}
+
+func (m *MsgBroker) dispatchReplies(replies <-chan amqp.Delivery) {
+	for d := range replies {
+		m.mu.Lock()
+		waiter, ok := m.pending[d.CorrelationId]
+		m.mu.Unlock()
+		if !ok {
+			m.logger.Printf("DROPPING REPLY WITH UNKNOWN CORRELATION ID %s\n", d.CorrelationId)
+			continue
+		}
+		select {
+		case waiter <- d:
+		default:
+		}
+	}
 }
@@ -0,0 +1,193 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ruziba3vich/shared/access"
+	controlrpc "github.com/ruziba3vich/smart-house/genprotos/controller_submodule"
+	devicesrpc "github.com/ruziba3vich/smart-house/genprotos/devices_submodule"
+	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type (
+	// Resource names the target of an action. Only the fields needed to find
+	// the house the target belongs to have to be set.
+	Resource struct {
+		HouseId  string
+		RoomId   string
+		DeviceId string
+		UserId   string
+	}
+
+	// Policy looks up the role of a user in the house a resource belongs
+	// to and checks it against the access table. What belongs to no house, such as a device whose house was deleted, is
+	// left to the administrators, who act on it as its owner would.
+	Policy struct {
+		controllerClient controlrpc.ControllerServiceClient
+		devicesClient    devicesrpc.DeviceServiceClient
+		usersClient      usersprotos.UsersServiceClient
+	}
+)
+
+func New(controllerClient controlrpc.ControllerServiceClient, devicesClient devicesrpc.DeviceServiceClient, usersClient usersprotos.UsersServiceClient) *Policy {
+	return &Policy{
+		controllerClient: controllerClient,
+		devicesClient:    devicesClient,
+		usersClient:      usersClient,
+	}
+}
+
+// Authorize checks that the user sub may perform action on resource. It
+// returns an error wrapping access.ErrForbidden on denial, or the error met
+// while looking the resource up.
+func (p *Policy) Authorize(ctx context.Context, sub string, action access.Action, resource Resource) error {
+	if len(sub) == 0 {
+		return fmt.Errorf("%w: no authenticated user", access.ErrForbidden)
+	}
+	if action == access.ActionOwnAccount {
+		if resource.UserId != sub {
+			return fmt.Errorf("%w: users can only change their own account", access.ErrForbidden)
+		}
+		return nil
+	}
+
+	role, err := p.RoleIn(ctx, sub, resource)
+	if err != nil {
+		return err
+	}
+	return access.Check(role, action)
+}
+
+// AuthorizeGrant checks that sub may give role to userId in a house, either
+// as a new member or as a role change. Owners grant any role; admins only
+// grant the member and guest roles, to users that are not admins already.
+func (p *Policy) AuthorizeGrant(ctx context.Context, sub, houseId, userId, role string) error {
+	callerRole, targetRole, err := p.memberRoles(ctx, sub, houseId, userId)
+	if err != nil {
+		return err
+	}
+	return access.CheckGrant(callerRole, targetRole, role)
+}
+
+// AuthorizeRemoval checks that sub may remove userId from a house. Anyone may
+// leave a house; owners remove anyone else, admins only members and guests.
+func (p *Policy) AuthorizeRemoval(ctx context.Context, sub, houseId, userId string) error {
+	if sub == userId {
+		return nil
+	}
+	callerRole, targetRole, err := p.memberRoles(ctx, sub, houseId, userId)
+	if err != nil {
+		return err
+	}
+	return access.CheckRemoval(callerRole, targetRole)
+}
+
+// memberRoles returns the roles of sub and userId in a house. The role of
+// userId only matters to admins, so it is only looked up for them
+func (p *Policy) memberRoles(ctx context.Context, sub, houseId, userId string) (string, string, error) {
+	if len(sub) == 0 {
+		return "", "", fmt.Errorf("%w: no authenticated user", access.ErrForbidden)
+	}
+	callerRole, err := p.RoleIn(ctx, sub, Resource{HouseId: houseId})
+	if err != nil || callerRole != access.RoleAdmin {
+		return callerRole, "", err
+	}
+	targetRole, err := p.RoleIn(ctx, userId, Resource{HouseId: houseId})
+	if err != nil {
+		return "", "", err
+	}
+	return callerRole, targetRole, nil
+}
+
+// RequireAdmin checks that sub is an administrator
+func (p *Policy) RequireAdmin(ctx context.Context, sub string) error {
+	admin, err := p.isAdmin(ctx, sub)
+	if err != nil {
+		return err
+	}
+	if !admin {
+		return fmt.Errorf("%w: only administrators can do this", access.ErrForbidden)
+	}
+	return nil
+}
+
+// HousesOf returns the ids of the houses sub is a member of, as the
+// controller has them now rather than as the token listed them at login
+func (p *Policy) HousesOf(ctx context.Context, sub string) ([]string, error) {
+	response, err := p.controllerClient.GetUserHouses(ctx, &controlrpc.UserHousesRequest{UserId: sub})
+	if err != nil {
+		return nil, err
+	}
+	houses := make([]string, 0, len(response.Houses))
+	for _, house := range response.Houses {
+		houses = append(houses, house.Id)
+	}
+	return houses, nil
+}
+
+// RoleIn returns the role of userId in the house resource belongs to, or ""
+// when the user is not a member of it. Administrators are the owners of
+// what belongs to no house.
+func (p *Policy) RoleIn(ctx context.Context, userId string, resource Resource) (string, error) {
+	houseId, err := p.houseOf(ctx, resource)
+	if err != nil {
+		return "", err
+	}
+	if len(houseId) == 0 {
+		admin, err := p.isAdmin(ctx, userId)
+		if err != nil {
+			return "", err
+		}
+		if !admin {
+			return "", fmt.Errorf("%w: the resource does not belong to a house", access.ErrForbidden)
+		}
+		return access.RoleOwner, nil
+	}
+	response, err := p.controllerClient.GetMemberRole(ctx, &controlrpc.UserRequest{UserId: userId, HouseId: houseId})
+	if err != nil {
+		return "", err
+	}
+	return response.Role, nil
+}
+
+func (p *Policy) houseOf(ctx context.Context, resource Resource) (string, error) {
+	switch {
+	case len(resource.HouseId) > 0:
+		return resource.HouseId, nil
+	case len(resource.RoomId) > 0:
+		room, err := p.controllerClient.GetRoom(ctx, &controlrpc.RoomIdRequest{RoomId: resource.RoomId})
+		if err != nil {
+			return "", err
+		}
+		return room.HouseId, nil
+	case len(resource.DeviceId) > 0:
+		device, err := p.devicesClient.GetDevice(ctx, &devicesrpc.GetDeviceRequest{Id: resource.DeviceId})
+		if err != nil {
+			return "", err
+		}
+		return device.Device.HouseId, nil
+	}
+	return "", nil
+}
+
+// isAdmin asks the users service, rather than the token, whether userId is an
+// administrator, so a revoked grant takes effect at once
+func (p *Policy) isAdmin(ctx context.Context, userId string) (bool, error) {
+	if len(userId) == 0 {
+		return false, nil
+	}
+	user, err := p.usersClient.GetById(ctx, &usersprotos.GetByFieldRequest{GetByField: userId})
+	if status.Code(err) == codes.Unknown {
+		// the users service answers a missing user, like most of its
+		// failures, with a plain error; either way the user is not known to be an
+		// administrator
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.Admin, nil
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript refills a bucket for the time since it was last used,
+// then takes a token from it if it has one. It returns whether a token was
+// taken, the whole tokens left, the milliseconds until the next token and
+// the milliseconds until the bucket is full again
+var tokenBucketScript = redis.NewScript(`
+local key = KEYS[1]
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(now - ts, 0) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+local full = math.ceil((capacity - tokens) / rate)
+
+redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', key, full + 1000)
+return {allowed, math.floor(tokens), wait, full}
+`)
+
+type (
+	// TokenBucket lets every key make bursts of up to burst requests, refilled
+	// at perMinute requests a minute. Buckets live in Redis, so gateway
+	// replicas share them
+	TokenBucket struct {
+		redisDb   *redis.Client
+		name      string
+		burst     int
+		perMinute int
+	}
+
+	// Decision is the outcome of taking a token, with what the RateLimit-*
+	// headers report
+	Decision struct {
+		Allowed    bool
+		Limit      int
+		Remaining  int
+		RetryAfter time.Duration
+		Reset      time.Duration
+	}
+)
+
+// NewTokenBucket creates a limiter; name separates the keys of limiters
+// sharing a Redis
+func NewTokenBucket(redisDb *redis.Client, name string, perMinute, burst int) *TokenBucket {
+	if perMinute < 1 {
+		perMinute = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		redisDb:   redisDb,
+		name:      name,
+		burst:     burst,
+		perMinute: perMinute,
+	}
+}
+
+// Take takes a token from the bucket of key
+func (t *TokenBucket) Take(ctx context.Context, key string) (*Decision, error) {
+	rate := float64(t.perMinute) / float64(time.Minute.Milliseconds())
+	result, err := tokenBucketScript.Run(ctx, t.redisDb,
+		[]string{"ratelimit:" + t.name + ":" + key},
+		t.burst, rate, time.Now().UnixMilli(),
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("could not check rate limit: %s", err.Error())
+	}
+	return &Decision{
+		Allowed:    result[0] == 1,
+		Limit:      t.burst,
+		Remaining:  int(result[1]),
+		RetryAfter: time.Duration(result[2]) * time.Millisecond,
+		Reset:      time.Duration(result[3]) * time.Millisecond,
+	}, nil
+}
+
+// Window is how long an empty bucket takes to fill up again
+func (t *TokenBucket) Window() time.Duration {
+	return time.Duration(t.burst) * time.Minute / time.Duration(t.perMinute)
+}
+
+// Limit is the size of the bucket, the most requests a key can make at once
+func (t *TokenBucket) Limit() int {
+	return t.burst
+}
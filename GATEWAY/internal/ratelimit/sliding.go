@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// slidingWindowScript keeps the attempts of a key as a sorted set scored by
+// time. It drops the attempts that left the window, then either records the
+// new one or, when the window is full, returns the milliseconds until the
+// oldest attempt leaves it
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, 0, now - window)
+if redis.call('ZCARD', key) < limit then
+	redis.call('ZADD', key, now, ARGV[4])
+	redis.call('PEXPIRE', key, window)
+	return 0
+end
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+return math.max(tonumber(oldest[2]) + window - now, 1)
+`)
+
+type (
+	// SlidingWindow allows at most limit attempts per key within any window
+	// long stretch of time. Its state is in Redis, so every gateway instance
+	// counts against the same limit
+	SlidingWindow struct {
+		redisDb *redis.Client
+		name    string
+		limit   int
+		window  time.Duration
+	}
+)
+
+// NewSlidingWindow creates a limiter; name separates the keys of limiters
+// sharing a Redis
+func NewSlidingWindow(redisDb *redis.Client, name string, limit int, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{
+		redisDb: redisDb,
+		name:    name,
+		limit:   limit,
+		window:  window,
+	}
+}
+
+// Allow records an attempt for key. When the limit has been reached it
+// records nothing and returns how long until the next attempt is allowed
+func (s *SlidingWindow) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	now := time.Now().UnixMilli()
+	member := fmt.Sprintf("%d-%d", now, rand.Int63())
+	wait, err := slidingWindowScript.Run(ctx, s.redisDb,
+		[]string{"ratelimit:" + s.name + ":" + key},
+		now, s.window.Milliseconds(), s.limit, member,
+	).Int64()
+	if err != nil {
+		return false, 0, fmt.Errorf("could not check rate limit: %s", err.Error())
+	}
+	if wait > 0 {
+		return false, time.Duration(wait) * time.Millisecond, nil
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,38 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// sessionPrefix must match the keys the users service writes its sessions
+// under
+const sessionPrefix = "session:"
+
+type (
+	// Store tells whether the login session an access token belongs to is still
+	// active. Sessions are created, refreshed and revoked by the users service
+	Store struct {
+		redisDb *redis.Client
+	}
+)
+
+func New(redisDb *redis.Client) *Store {
+	return &Store{
+		redisDb: redisDb,
+	}
+}
+
+// Active reports whether a session has neither been revoked nor expired
+func (s *Store) Active(ctx context.Context, sessionId string) (bool, error) {
+	if len(sessionId) == 0 {
+		return false, nil
+	}
+	n, err := s.redisDb.Exists(ctx, sessionPrefix+sessionId).Result()
+	if err != nil {
+		return false, fmt.Errorf("could not check session: %s", err.Error())
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,189 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Routing keys of the events streamed to the clients
+const (
+	DeviceStateChanged    = "device.state_changed"
+	DeviceBatteryChanged  = "device.battery_changed"
+	DevicePresenceChanged = "device.presence_changed"
+)
+
+// subscriberBuffer is how many events a slow client may fall behind before
+// further events are dropped for it.
+const subscriberBuffer = 32
+
+type (
+	// Event is a device change as it is pushed to the clients
+	Event struct {
+		Id         string          `json:"id"`
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		HouseId    string          `json:"house_id"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+
+	// Subscriber receives the events of the houses it was subscribed to
+	Subscriber struct {
+		Events chan *Event
+		houses map[string]bool
+	}
+
+	// Hub fans the device events published by the backend services out to
+	// the connected clients.
+	Hub struct {
+		ch          *amqp.Channel
+		queue       amqp.Queue
+		subscribers map[*Subscriber]struct{}
+		mu          sync.RWMutex
+		logger      *log.Logger
+	}
+)
+
+// NewHub binds a private queue of this gateway instance to the device events
+// on the given exchange.
+func NewHub(ch *amqp.Channel, exchange string, logger *log.Logger) (*Hub, error) {
+	if err := ch.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare events exchange: %s", err.Error())
+	}
+	queue, err := ch.QueueDeclare(
+		"",    // name, let the server generate one
+		false, // durable
+		true,  // delete when unused
+		true,  // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare stream queue: %s", err.Error())
+	}
+	for _, key := range []string{DeviceStateChanged, DeviceBatteryChanged, DevicePresenceChanged} {
+		if err := ch.QueueBind(queue.Name, key, exchange, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to bind stream queue: %s", err.Error())
+		}
+	}
+	return &Hub{
+		ch:          ch,
+		queue:       queue,
+		subscribers: make(map[*Subscriber]struct{}),
+		logger:      logger,
+	}, nil
+}
+
+// Run dispatches the consumed events until ctx is done
+func (h *Hub) Run(ctx context.Context) error {
+	deliveries, err := h.ch.Consume(
+		h.queue.Name, // queue
+		"",           // consumer
+		true,         // auto-ack
+		true,         // exclusive
+		false,        // no-local
+		false,        // no-wait
+		nil,          // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to consume stream queue: %s", err.Error())
+	}
+	for {
+		select {
+		case d, ok := <-deliveries:
+			if !ok {
+				return fmt.Errorf("stream queue delivery channel closed")
+			}
+			event, err := decode(d.Body)
+			if err != nil {
+				h.logger.Printf("DROPPING UNREADABLE EVENT: %s\n", err.Error())
+				continue
+			}
+			h.broadcast(event)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Subscribe registers a client interested in the given houses
+func (h *Hub) Subscribe(houses []string) *Subscriber {
+	s := &Subscriber{
+		Events: make(chan *Event, subscriberBuffer),
+		houses: houseSet(houses),
+	}
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+// Resubscribe replaces the houses a client receives the events of, once its
+// user joined or left some
+func (h *Hub) Resubscribe(s *Subscriber, houses []string) {
+	set := houseSet(houses)
+	h.mu.Lock()
+	s.houses = set
+	h.mu.Unlock()
+}
+
+// Unsubscribe forgets a client once its connection is gone
+func (h *Hub) Unsubscribe(s *Subscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, s)
+	h.mu.Unlock()
+}
+
+func (h *Hub) broadcast(event *Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for s := range h.subscribers {
+		if !s.houses[event.HouseId] {
+			continue
+		}
+		select {
+		case s.Events <- event:
+		default:
+			h.logger.Printf("SUBSCRIBER TOO SLOW, DROPPING EVENT %s\n", event.Id)
+		}
+	}
+}
+
+func houseSet(houses []string) map[string]bool {
+	set := make(map[string]bool, len(houses))
+	for _, house := range houses {
+		set[house] = true
+	}
+	return set
+}
+
+// decode reads the event envelope published by the backend services
+func decode(body []byte) (*Event, error) {
+	var envelope struct {
+		Id         string          `json:"id"`
+		Type       string          `json:"type"`
+		OccurredAt time.Time       `json:"occurred_at"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, err
+	}
+	var target struct {
+		HouseId string `json:"house_id"`
+	}
+	if err := json.Unmarshal(envelope.Payload, &target); err != nil {
+		return nil, err
+	}
+	return &Event{
+		Id:         envelope.Id,
+		Type:       envelope.Type,
+		OccurredAt: envelope.OccurredAt,
+		HouseId:    target.HouseId,
+		Payload:    envelope.Payload,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
+)
+
+// minRefreshInterval keeps tokens with unknown key ids, or an unreachable
+// users service, from making the cache ask for the keys on every request
+const minRefreshInterval = 10 * time.Second
+
+type (
+	// KeyCache holds the public keys the users service signs tokens with. They
+	// are fetched again once they are older than the ttl, or when a token names
+	// a key the cache has not seen yet, which is how rotated keys are picked up
+	KeyCache struct {
+		usersClient usersprotos.UsersServiceClient
+		ttl         time.Duration
+		mu          sync.Mutex
+		jwks        *usersprotos.JWKS
+		keys        map[string]*rsa.PublicKey
+		fetchedAt   time.Time
+		attemptedAt time.Time
+	}
+)
+
+func NewKeyCache(usersClient usersprotos.UsersServiceClient, ttl time.Duration) *KeyCache {
+	return &KeyCache{
+		usersClient: usersClient,
+		ttl:         ttl,
+		keys:        map[string]*rsa.PublicKey{},
+	}
+}
+
+// Key returns the public key with the given id
+func (k *KeyCache) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	key, ok := k.keys[kid]
+	if ok && time.Since(k.fetchedAt) < k.ttl {
+		return key, nil
+	}
+	if time.Since(k.attemptedAt) >= minRefreshInterval {
+		// a known key keeps working while the users service is unreachable
+		if err := k.refresh(ctx); err != nil && !ok {
+			return nil, err
+		} else if err == nil {
+			key, ok = k.keys[kid]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+	return key, nil
+}
+
+// JWKS returns the cached key set, fetching it when it is stale
+func (k *KeyCache) JWKS(ctx context.Context) (*usersprotos.JWKS, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.jwks != nil && (time.Since(k.fetchedAt) < k.ttl || time.Since(k.attemptedAt) < minRefreshInterval) {
+		return k.jwks, nil
+	}
+	if err := k.refresh(ctx); err != nil && k.jwks == nil {
+		return nil, err
+	}
+	return k.jwks, nil
+}
+
+func (k *KeyCache) refresh(ctx context.Context) error {
+	k.attemptedAt = time.Now()
+	jwks, err := k.usersClient.GetJWKS(ctx, &usersprotos.JWKSRequest{})
+	if err != nil {
+		return fmt.Errorf("could not fetch signing keys: %s", err.Error())
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return fmt.Errorf("could not parse signing key %s: %s", jwk.Kid, err.Error())
+		}
+		keys[jwk.Kid] = key
+	}
+	k.jwks = jwks
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	return nil
+}
+
+func parseJWK(jwk *usersprotos.JsonWebKey) (*rsa.PublicKey, error) {
+	if jwk.Kty != "RSA" {
+		return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(new(big.Int).SetBytes(e).Int64()),
+	}, nil
+}
@@ -1,48 +1,29 @@
 package utils
 
 import (
-	"encoding/hex"
+	"context"
 	"fmt"
-	"hash"
 
 	"github.com/golang-jwt/jwt"
-	"github.com/ruziba3vich/smart-house/internal/config"
+	usersprotos "github.com/ruziba3vich/smart-house/genprotos/submodules/users_submodule/protos"
 )
 
 type (
-	PasswordHasher struct {
-		hash hash.Hash
-	}
-
 	TokenGenerator struct {
-		secretKey string
+		keys *KeyCache
 	}
 )
 
-func NewPasswordHasher(hash hash.Hash) *PasswordHasher {
-	return &PasswordHasher{
-		hash: hash,
-	}
-}
-
-func NewTokenGenerator(cfg *config.Config) *TokenGenerator {
+// NewTokenGenerator creates a TokenGenerator verifying tokens against the
+// keys the users service publishes
+func NewTokenGenerator(keys *KeyCache) *TokenGenerator {
 	return &TokenGenerator{
-		secretKey: cfg.GetSecretKey(),
+		keys: keys,
 	}
 }
 
-func (p *PasswordHasher) HashPassword(password string) string {
-	p.hash.Write([]byte(password))
-	return hex.EncodeToString(p.hash.Sum(nil))
-}
-
-func (t *TokenGenerator) ExtractUserData(tokenString string) (string, string, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(t.secretKey), nil
-	})
+func (t *TokenGenerator) ExtractUserData(ctx context.Context, tokenString string) (string, string, error) {
+	token, err := jwt.Parse(tokenString, t.keyFunc(ctx))
 
 	if err != nil {
 		return "", "", fmt.Errorf("could not parse token: %s", err.Error())
@@ -60,13 +41,8 @@ func (t *TokenGenerator) ExtractUserData(tokenString string) (string, string, er
 	return "", "", fmt.Errorf("invalid token")
 }
 
-func (t *TokenGenerator) ValidateToken(tokenString string) (jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(t.secretKey), nil
-	})
+func (t *TokenGenerator) ValidateToken(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, t.keyFunc(ctx))
 
 	if err != nil {
 		return nil, err
@@ -78,3 +54,24 @@ func (t *TokenGenerator) ValidateToken(tokenString string) (jwt.MapClaims, error
 
 	return nil, fmt.Errorf("invalid token")
 }
+
+// JWKS returns the keys tokens are verified with, for clients that verify
+// tokens themselves
+func (t *TokenGenerator) JWKS(ctx context.Context) (*usersprotos.JWKS, error) {
+	return t.keys.JWKS(ctx)
+}
+
+// keyFunc only accepts RS256 tokens and picks the key named by their kid
+// header
+func (t *TokenGenerator) keyFunc(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("token has no key id")
+		}
+		return t.keys.Key(ctx, kid)
+	}
+}
@@ -5,11 +5,14 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/ruziba3vich/smart-house/internal/sessions"
 	"github.com/ruziba3vich/smart-house/internal/utils"
 )
 
-// AuthMiddleware is the middleware for token validation
-func AuthMiddleware(tokenGenerator *utils.TokenGenerator) gin.HandlerFunc {
+// AuthMiddleware is the middleware for token validation. Besides the token
+// itself it checks that the session the token was issued for has not been
+// logged out or revoked
+func AuthMiddleware(tokenGenerator *utils.TokenGenerator, sessionStore *sessions.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,13 +28,26 @@ func AuthMiddleware(tokenGenerator *utils.TokenGenerator) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := tokenGenerator.ValidateToken(tokenString)
+		claims, err := tokenGenerator.ValidateToken(c, tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		sessionId, _ := claims["sid"].(string)
+		active, err := sessionStore.Active(c, sessionId)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if !active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+
 		c.Set("userClaims", claims)
 		c.Next()
 	}
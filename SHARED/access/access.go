@@ -0,0 +1,117 @@
+// Package access is the table of what the role of a user in a house allows.
+// The gateway checks it for the HTTP routes; commands published straight to
+// a queue are checked again by the service consuming them, with the actor
+// they carry.
+package access
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrForbidden is returned when the actor of a command may not perform it.
+var ErrForbidden = errors.New("forbidden")
+
+// Roles a user can have in a house, as stored by the controller
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleGuest  = "guest"
+)
+
+// Action is something a user does to a house or to what it contains
+type Action string
+
+const (
+	// ActionOwnAccount covers changing or deleting a user account and
+	// listing its houses, which only the user may do; no role allows it
+	ActionOwnAccount Action = "user.account"
+
+	ActionViewHouse     Action = "house.view"
+	ActionUpdateHouse   Action = "house.update"
+	ActionDeleteHouse   Action = "house.delete"
+	ActionManageMembers Action = "house.members"
+	ActionManageRooms   Action = "house.rooms"
+	ActionManageDevices Action = "device.manage"
+	ActionDeleteDevice  Action = "device.delete"
+	ActionToggleDevice  Action = "device.toggle"
+)
+
+// permissions lists the roles allowed to perform each house action
+var permissions = map[Action][]string{
+	ActionViewHouse:     {RoleOwner, RoleAdmin, RoleMember, RoleGuest},
+	ActionToggleDevice:  {RoleOwner, RoleAdmin, RoleMember, RoleGuest},
+	ActionManageDevices: {RoleOwner, RoleAdmin, RoleMember},
+	ActionManageRooms:   {RoleOwner, RoleAdmin},
+	ActionManageMembers: {RoleOwner, RoleAdmin},
+	ActionUpdateHouse:   {RoleOwner, RoleAdmin},
+	ActionDeleteHouse:   {RoleOwner},
+	ActionDeleteDevice:  {RoleOwner},
+}
+
+// Allows reports whether role permits action
+func Allows(role string, action Action) bool {
+	for _, allowed := range permissions[action] {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error wrapping ErrForbidden unless role permits action
+func Check(role string, action Action) error {
+	if !Allows(role, action) {
+		return fmt.Errorf("%w: %s is not allowed to %s", ErrForbidden, roleName(role), action)
+	}
+	return nil
+}
+
+// CheckGrant checks that a caller of callerRole may give role to a user of
+// targetRole, "" for a user who is not a member yet. Owners grant any role;
+// admins only grant the member and guest roles, to users that are not admins
+// already.
+func CheckGrant(callerRole, targetRole, role string) error {
+	if err := Check(callerRole, ActionManageMembers); err != nil {
+		return err
+	}
+	if callerRole != RoleAdmin {
+		return nil
+	}
+	if role != RoleMember && role != RoleGuest && len(role) > 0 {
+		return fmt.Errorf("%w: admins can only grant the member and guest roles", ErrForbidden)
+	}
+	return checkAdminTarget(targetRole)
+}
+
+// CheckRemoval checks that a caller of callerRole may remove a member of
+// targetRole from a house. Owners remove anyone else, admins only members and
+// guests; leaving a house is not a removal and needs no check.
+func CheckRemoval(callerRole, targetRole string) error {
+	if err := Check(callerRole, ActionManageMembers); err != nil {
+		return err
+	}
+	if callerRole != RoleAdmin {
+		return nil
+	}
+	return checkAdminTarget(targetRole)
+}
+
+// checkAdminTarget keeps admins away from the owner and the other admins
+func checkAdminTarget(targetRole string) error {
+	if targetRole == RoleOwner || targetRole == RoleAdmin {
+		return fmt.Errorf("%w: admins can only manage members and guests", ErrForbidden)
+	}
+	return nil
+}
+
+func roleName(role string) string {
+	switch role {
+	case "":
+		return "a non-member"
+	case RoleOwner, RoleAdmin:
+		return "an " + role
+	}
+	return "a " + role
+}
@@ -0,0 +1,68 @@
+package access
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	if err := Check(RoleGuest, ActionToggleDevice); err != nil {
+		t.Errorf("a guest cannot toggle a device: %s", err)
+	}
+	if err := Check(RoleAdmin, ActionDeleteDevice); !errors.Is(err, ErrForbidden) {
+		t.Errorf("an admin deleting a device: err = %v, want ErrForbidden", err)
+	}
+	if err := Check("", ActionViewHouse); !errors.Is(err, ErrForbidden) {
+		t.Errorf("a non-member viewing a house: err = %v, want ErrForbidden", err)
+	}
+	if err := Check(RoleOwner, ActionOwnAccount); !errors.Is(err, ErrForbidden) {
+		t.Errorf("an owner acting on an account: err = %v, want ErrForbidden", err)
+	}
+}
+
+func TestCheckGrant(t *testing.T) {
+	cases := []struct {
+		caller, target, role string
+		allowed              bool
+	}{
+		{RoleOwner, RoleAdmin, RoleOwner, true},
+		{RoleOwner, "", RoleAdmin, true},
+		{RoleAdmin, "", RoleMember, true},
+		{RoleAdmin, RoleMember, RoleGuest, true},
+		{RoleAdmin, "", RoleAdmin, false},
+		{RoleAdmin, RoleAdmin, RoleMember, false},
+		{RoleAdmin, RoleOwner, RoleGuest, false},
+		{RoleMember, "", RoleGuest, false},
+	}
+	for _, c := range cases {
+		err := CheckGrant(c.caller, c.target, c.role)
+		if c.allowed && err != nil {
+			t.Errorf("%s granting %s to %q: %s", c.caller, c.role, c.target, err)
+		}
+		if !c.allowed && !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s granting %s to %q: err = %v, want ErrForbidden", c.caller, c.role, c.target, err)
+		}
+	}
+}
+
+func TestCheckRemoval(t *testing.T) {
+	cases := []struct {
+		caller, target string
+		allowed        bool
+	}{
+		{RoleOwner, RoleAdmin, true},
+		{RoleAdmin, RoleGuest, true},
+		{RoleAdmin, RoleAdmin, false},
+		{RoleAdmin, RoleOwner, false},
+		{RoleGuest, RoleMember, false},
+	}
+	for _, c := range cases {
+		err := CheckRemoval(c.caller, c.target)
+		if c.allowed && err != nil {
+			t.Errorf("%s removing %s: %s", c.caller, c.target, err)
+		}
+		if !c.allowed && !errors.Is(err, ErrForbidden) {
+			t.Errorf("%s removing %s: err = %v, want ErrForbidden", c.caller, c.target, err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Package devicetypes is the registry of the types of devices, with what
+// each can do. DEVICES checks the devices it stores against it and CONTROL
+// the commands and reports it takes.
+package devicetypes
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Capabilities are what a device of a type can do
+const (
+	CapabilityOnOff       = "on_off"
+	CapabilityBrightness  = "brightness"
+	CapabilityColor       = "color"
+	CapabilityTemperature = "temperature"
+	CapabilityLock        = "lock"
+	CapabilityPosition    = "position"
+	CapabilityMeasurement = "measurement"
+	CapabilityVideo       = "video"
+)
+
+// Attributes are the typed settings a device of a type can carry
+const (
+	AttributeBrightness        = "brightness"
+	AttributeColor             = "color"
+	AttributeTargetTemperature = "target_temperature"
+	AttributeLockState         = "lock_state"
+	AttributePosition          = "position"
+)
+
+// Kinds of attribute values
+const (
+	KindInt   = "int"
+	KindFloat = "float"
+	KindEnum  = "enum"
+	KindColor = "color"
+)
+
+type (
+	// Attribute describes the values an attribute can take. Min and Max bound
+	// the numeric kinds, Values lists those of an enum
+	Attribute struct {
+		Name   string
+		Kind   string
+		Min    float64
+		Max    float64
+		Values []string
+		Unit   string
+	}
+
+	// DeviceType is an entry of the registry
+	DeviceType struct {
+		Name         string
+		Description  string
+		Capabilities []string
+		Attributes   []Attribute
+	}
+)
+
+var (
+	brightness = Attribute{Name: AttributeBrightness, Kind: KindInt, Min: 0, Max: 100, Unit: "%"}
+	color      = Attribute{Name: AttributeColor, Kind: KindColor}
+	targetTemp = Attribute{Name: AttributeTargetTemperature, Kind: KindFloat, Min: 5, Max: 35, Unit: "°C"}
+	lockState  = Attribute{Name: AttributeLockState, Kind: KindEnum, Values: []string{"locked", "unlocked"}}
+	position   = Attribute{Name: AttributePosition, Kind: KindInt, Min: 0, Max: 100, Unit: "%"}
+
+	hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+	registry = map[string]*DeviceType{
+		"switch": {
+			Name:         "switch",
+			Description:  "Turns whatever is plugged into it on and off",
+			Capabilities: []string{CapabilityOnOff},
+		},
+		"dimmer": {
+			Name:         "dimmer",
+			Description:  "A light that can be dimmed",
+			Capabilities: []string{CapabilityOnOff, CapabilityBrightness},
+			Attributes:   []Attribute{brightness},
+		},
+		"light": {
+			Name:         "light",
+			Description:  "A light that can be dimmed and coloured",
+			Capabilities: []string{CapabilityOnOff, CapabilityBrightness, CapabilityColor},
+			Attributes:   []Attribute{brightness, color},
+		},
+		"thermostat": {
+			Name:         "thermostat",
+			Description:  "Heats or cools a room to a target temperature",
+			Capabilities: []string{CapabilityOnOff, CapabilityTemperature},
+			Attributes:   []Attribute{targetTemp},
+		},
+		"lock": {
+			Name:         "lock",
+			Description:  "A door lock",
+			Capabilities: []string{CapabilityLock},
+			Attributes:   []Attribute{lockState},
+		},
+		"sensor": {
+			Name:         "sensor",
+			Description:  "Reports measurements and has nothing to set",
+			Capabilities: []string{CapabilityMeasurement},
+		},
+		"camera": {
+			Name:         "camera",
+			Description:  "A camera that can be switched on and off",
+			Capabilities: []string{CapabilityOnOff, CapabilityVideo},
+		},
+		"blind": {
+			Name:         "blind",
+			Description:  "A blind or shutter that opens partway",
+			Capabilities: []string{CapabilityPosition},
+			Attributes:   []Attribute{position},
+		},
+	}
+)
+
+// Lookup returns the registered type of the given name
+func Lookup(name string) (*DeviceType, bool) {
+	deviceType, ok := registry[name]
+	return deviceType, ok
+}
+
+// Resolve is Lookup with an error that lists the known types
+func Resolve(name string) (*DeviceType, error) {
+	deviceType, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown device type %q, expected one of %s", name, strings.Join(names(), ", "))
+	}
+	return deviceType, nil
+}
+
+// All returns the registered types sorted by name
+func All() []*DeviceType {
+	types := make([]*DeviceType, 0, len(registry))
+	for _, deviceType := range registry {
+		types = append(types, deviceType)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
+
+// Has tells whether devices of the type have a capability
+func (t *DeviceType) Has(capability string) bool {
+	for _, c := range t.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// Attribute returns the attribute of the given name, if the type has it
+func (t *DeviceType) Attribute(name string) (*Attribute, bool) {
+	for i := range t.Attributes {
+		if t.Attributes[i].Name == name {
+			return &t.Attributes[i], true
+		}
+	}
+	return nil, false
+}
+
+// CheckStatus checks the status of a device of the type: one that can be
+// switched is either on or off, or has no status yet; any other has none
+func (t *DeviceType) CheckStatus(status string) error {
+	if status == "" {
+		return nil
+	}
+	if !t.Has(CapabilityOnOff) {
+		return fmt.Errorf("a %s has no status", t.Name)
+	}
+	if status != "on" && status != "off" {
+		return fmt.Errorf("status of a %s must be on or off", t.Name)
+	}
+	return nil
+}
+
+// CheckAttribute checks that the type has the attribute and that value, a
+// float64 for the numeric kinds and a string otherwise, is one it allows
+func (t *DeviceType) CheckAttribute(name string, value interface{}) error {
+	attribute, ok := t.Attribute(name)
+	if !ok {
+		return fmt.Errorf("a %s has no %s", t.Name, name)
+	}
+	return attribute.check(value)
+}
+
+func (a *Attribute) check(value interface{}) error {
+	switch a.Kind {
+	case KindEnum:
+		for _, allowed := range a.Values {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s", a.Name, strings.Join(a.Values, ", "))
+	case KindColor:
+		if text, ok := value.(string); !ok || !hexColor.MatchString(text) {
+			return fmt.Errorf("%s must be a colour like #ff8800", a.Name)
+		}
+		return nil
+	default:
+		number, ok := value.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) || number < a.Min || number > a.Max {
+			return fmt.Errorf("%s must be between %g and %g", a.Name, a.Min, a.Max)
+		}
+		return nil
+	}
+}
+
+func names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Version is the version of the envelope layout below. Bump it on any
+// breaking change so subscribers can tell the layouts apart.
+const Version = 1
+
+// ActorHeader carries the id of the user behind a command, both as gRPC
+// metadata and as an AMQP header.
+const ActorHeader = "x-actor-id"
+
+// Domain event types, also used as routing keys on the events exchange.
+const (
+	UserRegistered         = "user.registered"
+	UserUpdated            = "user.updated"
+	UserDeleted            = "user.deleted"
+	DeviceCreated          = "device.created"
+	DeviceUpdated          = "device.updated"
+	DeviceDeleted          = "device.deleted"
+	DeviceStateChanged     = "device.state_changed"
+	DeviceBatteryChanged   = "device.battery_changed"
+	DevicePresenceChanged  = "device.presence_changed"
+	HouseCreated           = "house.created"
+	HouseUpdated           = "house.updated"
+	HouseDeleted           = "house.deleted"
+	HouseMemberAdded       = "house.member_added"
+	HouseMemberRemoved     = "house.member_removed"
+	HouseMemberRoleChanged = "house.member_role_changed"
+	RoomCreated            = "room.created"
+	RoomUpdated            = "room.updated"
+	RoomDeleted            = "room.deleted"
+)
+
+type (
+	// Envelope wraps every domain event published on the events exchange.
+	Envelope struct {
+		Id         string      `json:"id"`
+		Type       string      `json:"type"`
+		Version    int         `json:"version"`
+		OccurredAt time.Time   `json:"occurred_at"`
+		Actor      string      `json:"actor"`
+		Payload    interface{} `json:"payload"`
+	}
+
+	actorKey struct{}
+)
+
+// New wraps payload in an envelope of the given type, attributed to the actor
+// found in ctx.
+func New(ctx context.Context, eventType string, payload interface{}) *Envelope {
+	return &Envelope{
+		Id:         primitive.NewObjectID().Hex(),
+		Type:       eventType,
+		Version:    Version,
+		OccurredAt: time.Now().UTC(),
+		Actor:      ActorFromContext(ctx),
+		Payload:    payload,
+	}
+}
+
+// WithActor returns a copy of ctx that attributes events to actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored by WithActor, or "system" when
+// the change was not triggered by a user.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && len(actor) > 0 {
+		return actor
+	}
+	return "system"
+}
@@ -0,0 +1,124 @@
+// Package migrate applies versioned changes to the database of a service.
+// Each service lists its own migrations.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName is the Mongo collection recording the applied migrations.
+const CollectionName = "migrations"
+
+type (
+	// Migration is a versioned change to the database. Up has to be
+	// idempotent: a migration that fails, or whose process dies, before it is
+	// recorded runs again in full, and replicas starting together may run it
+	// at the same time.
+	Migration struct {
+		Version     int
+		Description string
+		Up          func(ctx context.Context) error
+	}
+
+	// Record is the document kept for every applied migration.
+	Record struct {
+		Version     int       `bson:"_id"`
+		Description string    `bson:"description"`
+		AppliedAt   time.Time `bson:"applied_at"`
+	}
+
+	// Migrator applies migrations in version order and records them, so each
+	// is applied once per database.
+	Migrator struct {
+		collection *mongo.Collection
+		migrations []Migration
+		logger     *log.Logger
+	}
+)
+
+// New creates a Migrator for migrations, which have to be sorted by version.
+func New(collection *mongo.Collection, migrations []Migration, logger *log.Logger) *Migrator {
+	return &Migrator{
+		collection: collection,
+		migrations: migrations,
+		logger:     logger,
+	}
+}
+
+// Pending returns the migrations that have not been applied yet, in the
+// order they would be applied.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	cursor, err := m.collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %s", err.Error())
+	}
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %s", err.Error())
+	}
+	applied := make(map[int]bool, len(records))
+	for _, record := range records {
+		applied[record.Version] = true
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Run applies the pending migrations, stopping at the first one that fails.
+// With dryRun set nothing is applied. Either way it returns the migrations
+// that were, or would have been, applied.
+func (m *Migrator) Run(ctx context.Context, dryRun bool) ([]Migration, error) {
+	pending, err := m.Pending(ctx)
+	if err != nil || dryRun {
+		return pending, err
+	}
+	for i, migration := range pending {
+		m.logger.Printf("Applying migration %d: %s\n", migration.Version, migration.Description)
+		if err := migration.Up(ctx); err != nil {
+			return pending[:i], fmt.Errorf("migration %d failed: %s", migration.Version, err.Error())
+		}
+		record := Record{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UTC(),
+		}
+		if _, err := m.collection.InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return pending[:i], fmt.Errorf("failed to record migration %d: %s", migration.Version, err.Error())
+		}
+	}
+	return pending, nil
+}
+
+// CreateIndexes is the Up of a migration creating indexes. Creating an index
+// that already exists with the same options does nothing.
+func CreateIndexes(collection *mongo.Collection, indexes ...mongo.IndexModel) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := collection.Indexes().CreateMany(ctx, indexes)
+		return err
+	}
+}
+
+// Backfill is the Up of a migration setting a field on the documents that
+// do not have it yet.
+func Backfill(collection *mongo.Collection, field string, value interface{}) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := collection.UpdateMany(ctx,
+			bson.M{field: bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{field: value}},
+		)
+		return err
+	}
+}
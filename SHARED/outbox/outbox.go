@@ -0,0 +1,167 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CollectionName is the Mongo collection holding outbox rows. It lives next
+// to the entities so both can be written in one transaction.
+const CollectionName = "outbox"
+
+type (
+	// Message is an outbox row: a message that has to be published once the
+	// transaction that wrote it has committed.
+	Message struct {
+		Id          primitive.ObjectID `bson:"_id"`
+		RoutingKey  string             `bson:"routing_key"`
+		ContentType string             `bson:"content_type"`
+		Body        []byte             `bson:"body"`
+		CreatedAt   time.Time          `bson:"created_at"`
+		SentAt      *time.Time         `bson:"sent_at"`
+		Attempts    int                `bson:"attempts"`
+		LastError   string             `bson:"last_error,omitempty"`
+	}
+
+	// Relay publishes pending outbox rows in insertion order and marks them
+	// sent once the broker has confirmed them. A crash between the confirm
+	// and the mark publishes the row again, so delivery is at-least-once and
+	// consumers should deduplicate on the message id.
+	Relay struct {
+		collection *mongo.Collection
+		channel    *amqp.Channel
+		exchange   string
+		interval   time.Duration
+		batchSize  int64
+		logger     *log.Logger
+	}
+)
+
+// NewMessage builds an outbox row carrying payload as JSON.
+func NewMessage(routingKey string, payload interface{}) (*Message, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal outbox payload: %s", err.Error())
+	}
+	return &Message{
+		Id:          primitive.NewObjectID(),
+		RoutingKey:  routingKey,
+		ContentType: "application/json",
+		Body:        body,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// NewRelay puts the channel in confirm mode and declares the topic exchange
+// the outbox is published to.
+func NewRelay(collection *mongo.Collection, channel *amqp.Channel, exchange string, interval time.Duration, batchSize int, logger *log.Logger) (*Relay, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("failed to enable publisher confirms: %s", err.Error())
+	}
+	if err := channel.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare exchange %s: %s", exchange, err.Error())
+	}
+	return &Relay{
+		collection: collection,
+		channel:    channel,
+		exchange:   exchange,
+		interval:   interval,
+		batchSize:  int64(batchSize),
+		logger:     logger,
+	}, nil
+}
+
+// Run polls the outbox until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Println("Context done, stopping outbox relay")
+			return
+		case <-ticker.C:
+			if err := r.publishPending(ctx); err != nil {
+				r.logger.Printf("OUTBOX RELAY ERROR: %s\n", err.Error())
+			}
+		}
+	}
+}
+
+// publishPending publishes one batch of unsent rows. It stops at the first
+// failure so rows are never published out of order.
+func (r *Relay) publishPending(ctx context.Context) error {
+	findOptions := options.Find().SetSort(bson.M{"_id": 1}).SetLimit(r.batchSize)
+	cursor, err := r.collection.Find(ctx, bson.M{"sent_at": nil}, findOptions)
+	if err != nil {
+		return fmt.Errorf("failed to find pending outbox rows: %s", err.Error())
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var msg Message
+		if err := cursor.Decode(&msg); err != nil {
+			return fmt.Errorf("failed to decode outbox row: %s", err.Error())
+		}
+		if err := r.publish(ctx, &msg); err != nil {
+			r.markFailed(ctx, &msg, err)
+			return err
+		}
+		now := time.Now().UTC()
+		_, err := r.collection.UpdateOne(ctx, bson.M{"_id": msg.Id}, bson.M{
+			"$set": bson.M{"sent_at": now},
+			"$inc": bson.M{"attempts": 1},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to mark outbox row %s as sent: %s", msg.Id.Hex(), err.Error())
+		}
+	}
+	return cursor.Err()
+}
+
+func (r *Relay) publish(ctx context.Context, msg *Message) error {
+	confirmation, err := r.channel.PublishWithDeferredConfirmWithContext(
+		ctx,
+		r.exchange,     // exchange
+		msg.RoutingKey, // routing key
+		false,          // mandatory
+		false,          // immediate
+		amqp.Publishing{
+			MessageId:    msg.Id.Hex(),
+			ContentType:  msg.ContentType,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    msg.CreatedAt,
+			Body:         msg.Body,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish outbox row %s: %s", msg.Id.Hex(), err.Error())
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for confirm of outbox row %s: %s", msg.Id.Hex(), err.Error())
+	}
+	if !acked {
+		return fmt.Errorf("broker rejected outbox row %s", msg.Id.Hex())
+	}
+	return nil
+}
+
+func (r *Relay) markFailed(ctx context.Context, msg *Message, cause error) {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": msg.Id}, bson.M{
+		"$set": bson.M{"last_error": cause.Error()},
+		"$inc": bson.M{"attempts": 1},
+	})
+	if err != nil {
+		r.logger.Printf("Failed to record outbox failure for %s: %s\n", msg.Id.Hex(), err.Error())
+	}
+}
@@ -0,0 +1,126 @@
+// Package pagination pages through collections with opaque cursors, ordered
+// by a field and then by _id.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+type (
+	// cursor is what the opaque cursors handed to clients decode to: the
+	// order the pages are listed in and where the last page ended
+	cursor struct {
+		SortBy     string             `json:"s,omitempty"`
+		Descending bool               `json:"d,omitempty"`
+		Value      string             `json:"v,omitempty"`
+		Id         primitive.ObjectID `json:"id"`
+	}
+
+	// Query lists the documents after a cursor, ordered by a field and then
+	// by _id, which keeps the order stable between equal values
+	Query struct {
+		sortBy     string
+		descending bool
+		limit      int64
+		after      *cursor
+	}
+)
+
+// NewQuery validates the paging part of a list request; sortable holds the
+// fields the documents can be ordered by. Requests it refuses fail with
+// InvalidArgument
+func NewQuery(limit int32, encoded, sortBy string, descending bool, sortable ...string) (*Query, error) {
+	if len(sortBy) > 0 && !contains(sortable, sortBy) {
+		return nil, status.Errorf(codes.InvalidArgument, "cannot sort by %s", sortBy)
+	}
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit cannot be negative")
+	}
+	query := Query{sortBy: sortBy, descending: descending, limit: int64(limit)}
+	if query.limit == 0 {
+		query.limit = DefaultPageSize
+	} else if query.limit > MaxPageSize {
+		query.limit = MaxPageSize
+	}
+	if len(encoded) == 0 {
+		return &query, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+	var after cursor
+	if err := json.Unmarshal(raw, &after); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+	if after.SortBy != sortBy || after.Descending != descending {
+		return nil, status.Error(codes.InvalidArgument, "cursor does not match the requested sort")
+	}
+	query.after = &after
+	return &query, nil
+}
+
+// SortBy returns the field the documents are ordered by, "" for _id alone
+func (q *Query) SortBy() string {
+	return q.sortBy
+}
+
+// Limit returns the size of a page
+func (q *Query) Limit() int64 {
+	return q.limit
+}
+
+// Apply narrows filter down to the documents after the cursor and returns
+// the options listing them. One document more than the page is fetched, to
+// tell whether there is a next page
+func (q *Query) Apply(filter bson.M) *options.FindOptions {
+	direction, after := 1, "$gt"
+	if q.descending {
+		direction, after = -1, "$lt"
+	}
+	sort := bson.D{{Key: "_id", Value: direction}}
+	if len(q.sortBy) > 0 {
+		sort = append(bson.D{{Key: q.sortBy, Value: direction}}, sort...)
+	}
+
+	if q.after != nil {
+		if len(q.sortBy) == 0 {
+			filter["_id"] = bson.M{after: q.after.Id}
+		} else {
+			filter["$or"] = bson.A{
+				bson.M{q.sortBy: bson.M{after: q.after.Value}},
+				bson.M{q.sortBy: q.after.Value, "_id": bson.M{after: q.after.Id}},
+			}
+		}
+	}
+	return options.Find().SetSort(sort).SetLimit(q.limit + 1)
+}
+
+// NextCursor returns the cursor of the page after the document a page ended
+// with
+func (q *Query) NextCursor(value string, id primitive.ObjectID) string {
+	raw, _ := json.Marshal(cursor{SortBy: q.sortBy, Descending: q.descending, Value: value, Id: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,22 +2,37 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"os"
 	"sync"
 
 	"github.com/go-redis/redis/v8"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/shared/migrate"
+	"github.com/ruziba3vich/shared/outbox"
 	"github.com/ruziba3vich/users/grpcapp"
 	"github.com/ruziba3vich/users/internal/config"
+	"github.com/ruziba3vich/users/internal/migrations"
 	"github.com/ruziba3vich/users/internal/msgbroker"
+	"github.com/ruziba3vich/users/internal/notifier"
 	"github.com/ruziba3vich/users/internal/redisservice"
 	"github.com/ruziba3vich/users/internal/service"
 	"github.com/ruziba3vich/users/internal/storage"
+	"github.com/ruziba3vich/users/internal/utils"
 )
 
 func main() {
+	dlqList := flag.String("dlq-list", "", "list the dead-lettered messages of the given queue and exit")
+	dlqReplay := flag.String("dlq-replay", "", "move the dead-lettered messages of the given queue back into it and exit")
+	dlqLimit := flag.Int("dlq-limit", 100, "maximum number of dead-lettered messages to list or replay")
+	migrateOnly := flag.Bool("migrate", false, "apply the pending database migrations and exit; they are also applied on startup")
+	dryRun := flag.Bool("dry-run", false, "only list the pending migrations instead of applying them, and exit")
+	grantAdmin := flag.String("grant-admin", "", "make the user with the given email an administrator and exit")
+	flag.Parse()
+
 	logger := log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
 
 	cfg, err := config.LoadConfig()
@@ -33,14 +48,42 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	hash := sha256.New()
+	migrator := migrate.New(db.Client.Database(cfg.DbConfig.MongoDB).Collection(migrate.CollectionName), migrations.All(db), logger)
+	if err := runMigrations(ctx, migrator, logger, *dryRun); err != nil {
+		logger.Fatal(err)
+	}
+	if *migrateOnly || *dryRun {
+		return
+	}
+	if len(*grantAdmin) > 0 {
+		if err := storage.NewStorage(db, logger).GrantAdmin(ctx, *grantAdmin); err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("%s is now an administrator\n", *grantAdmin)
+		return
+	}
 
 	redisService := redisservice.New(redis.NewClient(&redis.Options{
 		Addr: cfg.GetRedisURI(),
 		DB:   0,
 	}), logger)
+	if flushed, err := redisService.FlushLegacyUsers(ctx); err != nil {
+		logger.Printf("ERROR WHILE FLUSHING LEGACY CACHED USERS : %s\n", err.Error())
+	} else if flushed > 0 {
+		logger.Printf("Flushed %d cached users stored with their password hash\n", flushed)
+	}
+
+	tokens, err := utils.NewTokenGenerator(cfg)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
-	service := service.New(storage.NewStorage(db, logger, hash, cfg), redisService, logger)
+	notifier, err := notifier.New(cfg, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	service := service.New(storage.NewStorage(db, logger), redisService, tokens, notifier, cfg, logger)
 
 	conn, err := amqp.Dial(cfg.GetRabbitMqURI())
 	if err != nil {
@@ -54,6 +97,11 @@ func main() {
 	}
 	defer ch.Close()
 
+	if len(*dlqList) > 0 || len(*dlqReplay) > 0 {
+		runDeadLetterCommand(ctx, ch, logger, *dlqList, *dlqReplay, *dlqLimit)
+		return
+	}
+
 	grpcserver := grpcapp.NewUsersApp(service)
 
 	regQueue, err := getQueue(ch, "create")
@@ -83,7 +131,38 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	msgBroker := msgbroker.New(service, ch, logger, regMsgs, updMsgs, delMsgs, &sync.WaitGroup{}, 3)
+	policy := msgbroker.RetryPolicy{
+		MaxAttempts: cfg.RetryConfig.MaxAttempts,
+		BaseDelay:   cfg.RetryConfig.BaseDelay,
+		MaxDelay:    cfg.RetryConfig.MaxDelay,
+	}
+	for _, q := range []amqp.Queue{regQueue, updQueue, delQueue} {
+		if err := msgbroker.DeclareRetryTopology(ch, q.Name, policy); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	relayCh, err := conn.Channel()
+	if err != nil {
+		logger.Fatalf("Failed to open a channel: %v", err)
+	}
+	defer relayCh.Close()
+
+	relay, err := outbox.NewRelay(db.OutboxCollection, relayCh, cfg.OutboxConfig.Exchange, cfg.OutboxConfig.PollInterval, cfg.OutboxConfig.BatchSize, logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	relayCtx, stopRelay := context.WithCancel(ctx)
+	defer stopRelay()
+	go relay.Run(relayCtx)
+
+	msgBroker := msgbroker.New(service, ch, logger, regMsgs, updMsgs, delMsgs, &sync.WaitGroup{}, 3, policy)
+
+	membershipMsgs, err := msgbroker.DeclareMembershipQueue(ch, cfg.OutboxConfig.Exchange)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	go msgBroker.ConsumeMembershipEvents(ctx, membershipMsgs, service)
 
 	// Start gRPC server in a separate goroutine
 	go func() {
@@ -93,6 +172,47 @@ func main() {
 	msgBroker.StartToConsume(ctx, "application/json")
 }
 
+// runMigrations applies the pending migrations, or with dryRun lists them
+func runMigrations(ctx context.Context, migrator *migrate.Migrator, logger *log.Logger, dryRun bool) error {
+	applied, err := migrator.Run(ctx, dryRun)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		for _, migration := range applied {
+			logger.Printf("Pending migration %d: %s\n", migration.Version, migration.Description)
+		}
+		logger.Printf("%d pending migrations\n", len(applied))
+	} else if len(applied) > 0 {
+		logger.Printf("Applied %d migrations\n", len(applied))
+	}
+	return nil
+}
+
+// runDeadLetterCommand lists or replays the dead-lettered messages of a queue
+func runDeadLetterCommand(ctx context.Context, ch *amqp.Channel, logger *log.Logger, listQueue, replayQueue string, limit int) {
+	if len(listQueue) > 0 {
+		letters, err := msgbroker.ListDeadLetters(ch, listQueue, limit)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		for _, letter := range letters {
+			data, err := json.Marshal(letter)
+			if err != nil {
+				logger.Fatal(err)
+			}
+			fmt.Println(string(data))
+		}
+	}
+	if len(replayQueue) > 0 {
+		replayed, err := msgbroker.ReplayDeadLetters(ctx, ch, replayQueue, limit)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		logger.Printf("Replayed %d dead-lettered messages into %s\n", replayed, replayQueue)
+	}
+}
+
 func getQueue(ch *amqp.Channel, queueName string) (amqp.Queue, error) {
 	return ch.QueueDeclare(
 		queueName, // name
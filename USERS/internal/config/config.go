@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,14 +17,67 @@ type DbConfig struct {
 	Collection string
 }
 
+// RetryConfig holds the retry policy of the message consumers
+type RetryConfig struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// OutboxConfig holds the settings of the outbox relay
+type OutboxConfig struct {
+	Exchange     string
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// TokenConfig holds the lifetimes of the issued tokens and where the keys
+// they are signed with are kept. KeysDir holds one PEM encoded RSA private key
+// per file, named <kid>.pem
+type TokenConfig struct {
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// VerificationTokenTTL and ResetTokenTTL bound the one-time tokens
+	// mailed out to verify an email address and to reset a password
+	VerificationTokenTTL time.Duration
+	ResetTokenTTL        time.Duration
+	KeysDir              string
+	SigningKeyId         string
+}
+
+// NotifierConfig holds how users are notified. Kind is "smtp" or "log";
+// PublicURL is the address of the gateway the links sent to users point at
+type NotifierConfig struct {
+	Kind         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	LogFile      string
+	PublicURL    string
+}
+
+// LockoutConfig holds when repeated failed logins lock an account: after
+// Threshold failures for Base, doubling with every further failure up to Max
+type LockoutConfig struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
 // Config holds the application configuration
 type Config struct {
-	DbConfig    DbConfig
-	Port        string
-	Protocol    string
-	secretKey   string
-	redisUri    string
-	rabbitMqUri string
+	DbConfig       DbConfig
+	RetryConfig    RetryConfig
+	OutboxConfig   OutboxConfig
+	TokenConfig    TokenConfig
+	NotifierConfig NotifierConfig
+	LockoutConfig  LockoutConfig
+	Port           string
+	Protocol       string
+	redisUri       string
+	rabbitMqUri    string
 }
 
 // LoadConfig reads configuration from environment variables or .env file
@@ -30,18 +86,60 @@ func LoadConfig() (*Config, error) {
 		log.Println("No .env file found, using environment variables if set.")
 	}
 
-	return &Config{
+	cfg := &Config{
 		DbConfig: DbConfig{
 			MongoURI:   getEnv("MONGO_URI", "mongodb://localhost:27017"),
 			MongoDB:    getEnv("MONGO_DB", "test"),
 			Collection: getEnv("MONGO_COLLECTION", "users"),
 		},
+		RetryConfig: RetryConfig{
+			MaxAttempts: getEnvInt("RETRY_MAX_ATTEMPTS", 3),
+			BaseDelay:   getEnvDuration("RETRY_BASE_DELAY", time.Second),
+			MaxDelay:    getEnvDuration("RETRY_MAX_DELAY", time.Minute),
+		},
+		OutboxConfig: OutboxConfig{
+			Exchange:     getEnv("OUTBOX_EXCHANGE", "smart_house.events"),
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		},
+		TokenConfig: TokenConfig{
+			AccessTokenTTL:       getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL:      getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+			VerificationTokenTTL: getEnvDuration("VERIFICATION_TOKEN_TTL", 24*time.Hour),
+			ResetTokenTTL:        getEnvDuration("RESET_TOKEN_TTL", time.Hour),
+			KeysDir:              getEnv("JWT_KEYS_DIR", ""),
+			SigningKeyId:         getEnv("JWT_SIGNING_KEY_ID", ""),
+		},
+		NotifierConfig: NotifierConfig{
+			Kind:         getEnv("NOTIFIER", "log"),
+			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+			SMTPPort:     getEnvInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			From:         getEnv("SMTP_FROM", "no-reply@smart-house.local"),
+			LogFile:      getEnv("NOTIFIER_LOG_FILE", ""),
+			PublicURL:    getEnv("PUBLIC_URL", "http://localhost:7777"),
+		},
+		LockoutConfig: LockoutConfig{
+			Threshold: getEnvInt("LOCKOUT_THRESHOLD", 5),
+			Base:      getEnvDuration("LOCKOUT_BASE", time.Minute),
+			Max:       getEnvDuration("LOCKOUT_MAX", time.Hour),
+		},
 		Port:        getEnv("PORT", "8080"),
 		Protocol:    getEnv("PROTOCOL", "tcp"),
-		secretKey:   getEnv("SECRET_KEY", "prodonik"),
 		redisUri:    getEnv("REDIS_URI", "redis:6379"),
 		rabbitMqUri: getEnv("RABBITMQ_URI", "amqp://rabbitmq:5672"),
-	}, nil
+	}
+
+	// tokens used to be signed with a shared secret that defaulted to a value
+	// checked into the repository; there is no default signing key any more
+	if len(cfg.TokenConfig.KeysDir) == 0 {
+		return nil, fmt.Errorf("JWT_KEYS_DIR is not set, refusing to start without signing keys")
+	}
+	if _, exists := os.LookupEnv("SECRET_KEY"); exists {
+		log.Println("SECRET_KEY is no longer used, tokens are signed with the keys in JWT_KEYS_DIR")
+	}
+	return cfg, nil
 }
 
 // Helper function to get environment variables with a fallback value
@@ -52,8 +150,26 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func (c *Config) GetSecretKey() string {
-	return c.secretKey
+// Helper function to get an integer environment variable with a fallback value
+func getEnvInt(key string, fallback int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %d\n", key, fallback)
+	}
+	return fallback
+}
+
+// Helper function to get a duration environment variable with a fallback value
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+		log.Printf("Invalid value for %s, using %s\n", key, fallback)
+	}
+	return fallback
 }
 
 func (c *Config) GetRedisURI() string {
@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ruziba3vich/shared/migrate"
+	"github.com/ruziba3vich/users/internal/storage"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// All returns the migrations of the users database. New migrations are
+// appended with the next version; applied ones must not change.
+func All(db *storage.DB) []migrate.Migration {
+	// soft-deleted users keep their email and username, which can be
+	// registered again
+	active := bson.M{"deleted": false}
+	return []migrate.Migration{
+		{
+			Version:     1,
+			Description: "mark users without a deleted flag as active",
+			Up:          migrate.Backfill(db.UsersCollection, "deleted", false),
+		},
+		{
+			Version:     2,
+			Description: "unique emails and usernames among active users",
+			// the duplicates are reported before the indexes would fail on
+			// them; which account to keep is for an operator to decide
+			Up: func(ctx context.Context) error {
+				if err := findDuplicates(ctx, db.UsersCollection, active, "email", "username"); err != nil {
+					return err
+				}
+				return migrate.CreateIndexes(db.UsersCollection,
+					mongo.IndexModel{
+						Keys:    bson.D{{Key: "email", Value: 1}},
+						Options: options.Index().SetName("users_email").SetUnique(true).SetPartialFilterExpression(active),
+					},
+					mongo.IndexModel{
+						Keys:    bson.D{{Key: "username", Value: 1}},
+						Options: options.Index().SetName("users_username").SetUnique(true).SetPartialFilterExpression(active),
+					},
+				)(ctx)
+			},
+		},
+		{
+			Version:     3,
+			Description: "index users by address",
+			Up: migrate.CreateIndexes(db.UsersCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "deleted", Value: 1}, {Key: "profile.address", Value: 1}},
+				Options: options.Index().SetName("users_deleted_address"),
+			}),
+		},
+		{
+			Version:     4,
+			Description: "text index for user search",
+			Up: migrate.CreateIndexes(db.UsersCollection, mongo.IndexModel{
+				Keys:    storage.UserSearchIndex,
+				Options: options.Index().SetName("users_search"),
+			}),
+		},
+		{
+			Version:     5,
+			Description: "index unsent outbox rows",
+			Up: migrate.CreateIndexes(db.OutboxCollection, mongo.IndexModel{
+				Keys:    bson.D{{Key: "sent_at", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("outbox_unsent"),
+			}),
+		},
+	}
+}
+
+// findDuplicates returns an error listing the values of fields shared by
+// several of the documents matching filter, with the ids of those documents
+func findDuplicates(ctx context.Context, collection *mongo.Collection, filter bson.M, fields ...string) error {
+	var duplicates []string
+	for _, field := range fields {
+		cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: filter}},
+			{{Key: "$group", Value: bson.M{"_id": "$" + field, "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+			{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to look for duplicate %ss: %s", field, err.Error())
+		}
+		var groups []struct {
+			Value interface{}          `bson:"_id"`
+			Ids   []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.All(ctx, &groups); err != nil {
+			return fmt.Errorf("failed to decode duplicate %ss: %s", field, err.Error())
+		}
+		for _, group := range groups {
+			ids := make([]string, 0, len(group.Ids))
+			for _, id := range group.Ids {
+				ids = append(ids, id.Hex())
+			}
+			duplicates = append(duplicates, fmt.Sprintf("%s %v is used by %s", field, group.Value, strings.Join(ids, ", ")))
+		}
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("active users share emails or usernames, delete or rename all but one of each and migrate again: %s", strings.Join(duplicates, "; "))
+	}
+	return nil
+}
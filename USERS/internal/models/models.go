@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+
 	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -13,6 +15,21 @@ type (
 		Password string             `bson:"password" json:"password"`
 		Profile  Profile            `bson:"profile" json:"profile"`
 		Deleted  bool               `bson:"deleted" json:"deleted"`
+		Houses   []string           `bson:"houses,omitempty" json:"-"`
+		// Admin is only ever granted by an operator, with -grant-admin
+		Admin bool `bson:"admin,omitempty" json:"admin"`
+		// PendingVerification is set on registration until the user follows
+		// the link mailed to them; accounts from before verification existed
+		// do not have it and count as verified
+		PendingVerification bool `bson:"pending_verification,omitempty" json:"-"`
+		// TOTPSecret is kept from enrolment on, TOTPEnabled only once the
+		// user has confirmed a code. TOTPLastStep is the time step of the last
+		// accepted code, so a code cannot be replayed; RecoveryCodes holds the
+		// hashes of the unused recovery codes
+		TOTPSecret    string   `bson:"totp_secret,omitempty" json:"-"`
+		TOTPEnabled   bool     `bson:"totp_enabled,omitempty" json:"-"`
+		TOTPLastStep  int64    `bson:"totp_last_step,omitempty" json:"-"`
+		RecoveryCodes []string `bson:"recovery_codes,omitempty" json:"-"`
 		// Method   Method
 	}
 
@@ -29,22 +46,30 @@ type (
 	DeleteUserRequest struct {
 		UserId string `json:"user_id"`
 	}
-)
 
-func (u *User) Update(obj *genprotos.UpdateUserReuqest) {
-	if len(obj.User.Email) > 0 {
-		u.Email = obj.User.Email
-	}
-	if len(obj.User.Username) > 0 {
-		u.Username = obj.User.Username
+	// HouseMembership is the payload of the house.member_* events CONTROL
+	// publishes
+	HouseMembership struct {
+		UserId  string `json:"user_id"`
+		HouseId string `json:"house_id"`
 	}
-	if len(obj.User.Profile.Name) > 0 {
-		u.Profile.Name = obj.User.Profile.Name
-	}
-	if len(obj.User.Profile.Address) > 0 {
-		u.Profile.Address = obj.User.Profile.Address
+
+	// Reply is published to the ReplyTo queue of a consumed command so the
+	// caller learns how it ended.
+	Reply struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Error   string          `json:"error,omitempty"`
+		Data    json.RawMessage `json:"data,omitempty"`
 	}
-}
+)
+
+const (
+	ReplyStatusSuccess    = "success"
+	ReplyStatusError      = "error"
+	ReplyStatusBadRequest = "bad_request"
+	ReplyStatusForbidden  = "forbidden"
+)
 
 func (p Profile) ToProtoProfile() *genprotos.Profile {
 	return &genprotos.Profile{
@@ -66,9 +91,18 @@ func (u *User) ToProtoUser() *genprotos.User {
 		Password: u.Password,
 		Profile:  u.Profile.ToProtoProfile(),
 		Deleted:  u.Deleted,
+		Admin:    u.Admin,
 	}
 }
 
+// ToPublicProtoUser is ToProtoUser without the password hash, for anything
+// that leaves the service
+func (u *User) ToPublicProtoUser() *genprotos.User {
+	user := u.ToProtoUser()
+	user.Password = ""
+	return user
+}
+
 func (u *User) FromProto(data *genprotos.CreateUserReuest) {
 	u.Email = data.Email
 	u.Password = data.Password
@@ -0,0 +1,20 @@
+package msgbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ruziba3vich/shared/events"
+)
+
+// errForbidden is returned when the actor of a command may not perform it
+var errForbidden = errors.New("forbidden")
+
+// checkActor lets users update or delete only their own account from a queue
+func checkActor(ctx context.Context, userId string) error {
+	if actor := events.ActorFromContext(ctx); actor != userId {
+		return fmt.Errorf("%w: %s can't change user %s", errForbidden, actor, userId)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package msgbroker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/shared/events"
+	"github.com/ruziba3vich/users/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MembershipQueue receives the house membership events published by CONTROL.
+// The houses a user belongs to end up in the user's token.
+const MembershipQueue = "users.house_membership"
+
+type (
+	// HouseMembershipStore records which houses a user belongs to
+	HouseMembershipStore interface {
+		AddUserHouse(ctx context.Context, userId, houseId string) error
+		RemoveUserHouse(ctx context.Context, userId, houseId string) error
+		DropHouse(ctx context.Context, houseId string) error
+	}
+
+	membershipEvent struct {
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	deletedHouse struct {
+		Id string `json:"id"`
+	}
+)
+
+// errDropEvent marks an event that can never be applied and is not requeued
+var errDropEvent = errors.New("event dropped")
+
+// DeclareMembershipQueue binds MembershipQueue to the events exchange and
+// starts consuming it
+func DeclareMembershipQueue(ch *amqp.Channel, exchange string) (<-chan amqp.Delivery, error) {
+	if err := ch.ExchangeDeclare(exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return nil, fmt.Errorf("failed to declare events exchange: %s", err.Error())
+	}
+	q, err := ch.QueueDeclare(MembershipQueue, true, false, false, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to declare membership queue: %s", err.Error())
+	}
+	for _, key := range []string{events.HouseMemberAdded, events.HouseMemberRemoved, events.HouseDeleted} {
+		if err := ch.QueueBind(q.Name, key, exchange, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to bind membership queue: %s", err.Error())
+		}
+	}
+	return ch.Consume(q.Name, "", false, false, false, false, nil)
+}
+
+// ConsumeMembershipEvents applies house membership and deletion events to the
+// users until ctx is done. All updates are idempotent, so a failed one is
+// simply requeued.
+func (m *MsgBroker) ConsumeMembershipEvents(ctx context.Context, messages <-chan amqp.Delivery, store HouseMembershipStore) {
+	for {
+		select {
+		case val, ok := <-messages:
+			if !ok {
+				m.logger.Println("Delivery channel closed, stopping membership consumer")
+				return
+			}
+			var event membershipEvent
+			if err := json.Unmarshal(val.Body, &event); err != nil {
+				m.logger.Printf("ERROR WHILE UNMARSHALING MEMBERSHIP EVENT: %s\n", err.Error())
+				val.Ack(false)
+				continue
+			}
+
+			err := m.applyMembershipEvent(ctx, &event, store)
+			if err == errDropEvent {
+				val.Ack(false)
+				continue
+			}
+			if err != nil {
+				m.logger.Printf("Failed to apply %s: %s\n", event.Type, err.Error())
+				val.Nack(false, true)
+				continue
+			}
+			val.Ack(false)
+		case <-ctx.Done():
+			m.logger.Println("Context done, stopping membership consumer")
+			return
+		}
+	}
+}
+
+func (m *MsgBroker) applyMembershipEvent(ctx context.Context, event *membershipEvent, store HouseMembershipStore) error {
+	if event.Type == events.HouseDeleted {
+		var house deletedHouse
+		if err := json.Unmarshal(event.Payload, &house); err != nil || len(house.Id) == 0 {
+			m.logger.Printf("DROPPING UNREADABLE %s EVENT\n", event.Type)
+			return errDropEvent
+		}
+		return store.DropHouse(ctx, house.Id)
+	}
+
+	var membership models.HouseMembership
+	if err := json.Unmarshal(event.Payload, &membership); err != nil {
+		m.logger.Printf("DROPPING UNREADABLE %s EVENT\n", event.Type)
+		return errDropEvent
+	}
+	if _, err := primitive.ObjectIDFromHex(membership.UserId); err != nil {
+		m.logger.Printf("DROPPING %s FOR INVALID USER ID %s\n", event.Type, membership.UserId)
+		return errDropEvent
+	}
+	if event.Type == events.HouseMemberAdded {
+		return store.AddUserHouse(ctx, membership.UserId, membership.HouseId)
+	}
+	return store.RemoveUserHouse(ctx, membership.UserId, membership.HouseId)
+}
@@ -3,6 +3,8 @@ package msgbroker
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -10,8 +12,10 @@ import (
 	"syscall"
 
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/shared/events"
 	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
 	"github.com/ruziba3vich/users/internal/models"
+	"github.com/ruziba3vich/users/internal/storage"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -25,6 +29,7 @@ type (
 		logger           *log.Logger
 		wg               *sync.WaitGroup
 		numberOfServices int
+		policy           RetryPolicy
 	}
 )
 
@@ -35,7 +40,8 @@ func New(service genprotos.UsersServiceServer,
 	profileUpdates <-chan amqp.Delivery,
 	profileDeletions <-chan amqp.Delivery,
 	wg *sync.WaitGroup,
-	numberOfServices int) *MsgBroker {
+	numberOfServices int,
+	policy RetryPolicy) *MsgBroker {
 	return &MsgBroker{
 		service:          service,
 		channel:          channel,
@@ -45,6 +51,7 @@ func New(service genprotos.UsersServiceServer,
 		logger:           logger,
 		wg:               wg,
 		numberOfServices: numberOfServices,
+		policy:           policy,
 	}
 }
 
@@ -53,9 +60,9 @@ func (m *MsgBroker) StartToConsume(ctx context.Context, contentType string) {
 	consumerCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	go m.consumeMessages(consumerCtx, m.registrations, m.service.RegisterUser, "registration")
-	go m.consumeMessages(consumerCtx, m.profileUpdates, m.service.UpdateUser, "update")
-	go m.consumeMessages(consumerCtx, m.profileDeletions, m.service.DeleteUserById, "deletion")
+	go m.consumeMessages(consumerCtx, m.registrations, m.service.RegisterUser, "registration", contentType)
+	go m.consumeMessages(consumerCtx, m.profileUpdates, m.service.UpdateUser, "update", contentType)
+	go m.consumeMessages(consumerCtx, m.profileDeletions, m.service.DeleteUserById, "deletion", contentType)
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -67,67 +74,138 @@ func (m *MsgBroker) StartToConsume(ctx context.Context, contentType string) {
 	m.logger.Println("All consumers have stopped")
 }
 
-func (m *MsgBroker) consumeMessages(ctx context.Context, messages <-chan amqp.Delivery, serviceFunc interface{}, logPrefix string) {
+func (m *MsgBroker) consumeMessages(ctx context.Context, messages <-chan amqp.Delivery, serviceFunc interface{}, logPrefix string, contentType string) {
 	defer m.wg.Done()
 	for {
 		select {
-		case val := <-messages:
+		case val, ok := <-messages:
+			if !ok {
+				m.logger.Printf("Delivery channel closed, stopping %s consumer", logPrefix)
+				return
+			}
 			var request interface{}
 			var response proto.Message
 			var err error
 
+			msgCtx := ctx
+			if actor, ok := val.Headers[events.ActorHeader].(string); ok {
+				msgCtx = events.WithActor(ctx, actor)
+			}
+
 			switch logPrefix {
 			case "registration":
 				var req models.User
 				if err := json.Unmarshal(val.Body, &req); err != nil {
 					m.logger.Printf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())
-					val.Nack(false, false)
-					// m.publishMessageBack(val, contentType, []byte(fmt.Sprintf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())))
+					m.handleFailure(ctx, val, contentType, &models.Reply{
+						Status:  models.ReplyStatusBadRequest,
+						Message: fmt.Sprintf("invalid %s payload", logPrefix),
+						Error:   err.Error(),
+					}, false)
 					continue
 				}
 				request = req.ToCreateUserRequest()
-				response, err = serviceFunc.(func(context.Context, *genprotos.CreateUserReuest) (*genprotos.Response, error))(ctx, request.(*genprotos.CreateUserReuest))
+				response, err = serviceFunc.(func(context.Context, *genprotos.CreateUserReuest) (*genprotos.Response, error))(msgCtx, request.(*genprotos.CreateUserReuest))
 			case "update":
 				var req models.User
 				if err := json.Unmarshal(val.Body, &req); err != nil {
 					m.logger.Printf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())
-					val.Nack(false, false)
-					// m.publishMessageBack(val, contentType, []byte(fmt.Sprintf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())))
+					m.handleFailure(ctx, val, contentType, &models.Reply{
+						Status:  models.ReplyStatusBadRequest,
+						Message: fmt.Sprintf("invalid %s payload", logPrefix),
+						Error:   err.Error(),
+					}, false)
 					continue
 				}
+				if err = checkActor(msgCtx, req.Id.Hex()); err != nil {
+					break
+				}
 				request = req.ToUpdateUserRequest()
-				response, err = serviceFunc.(func(context.Context, *genprotos.UpdateUserReuqest) (*genprotos.Response, error))(ctx, request.(*genprotos.UpdateUserReuqest))
+				response, err = serviceFunc.(func(context.Context, *genprotos.UpdateUserReuqest) (*genprotos.Response, error))(msgCtx, request.(*genprotos.UpdateUserReuqest))
 			case "deletion":
 				var req models.DeleteUserRequest
 				if err := json.Unmarshal(val.Body, &req); err != nil {
 					m.logger.Printf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())
-					val.Nack(false, false)
-					// m.publishMessageBack(val, contentType, []byte(fmt.Sprintf("ERROR WHILE UNMARSHALING DATA: %s\n", err.Error())))
+					m.handleFailure(ctx, val, contentType, &models.Reply{
+						Status:  models.ReplyStatusBadRequest,
+						Message: fmt.Sprintf("invalid %s payload", logPrefix),
+						Error:   err.Error(),
+					}, false)
 					continue
 				}
+				if err = checkActor(msgCtx, req.UserId); err != nil {
+					break
+				}
 				request = &genprotos.GetByFieldRequest{GetByField: req.UserId}
-				response, err = serviceFunc.(func(context.Context, *genprotos.GetByFieldRequest) (*genprotos.Response, error))(ctx, request.(*genprotos.GetByFieldRequest))
+				response, err = serviceFunc.(func(context.Context, *genprotos.GetByFieldRequest) (*genprotos.Response, error))(msgCtx, request.(*genprotos.GetByFieldRequest))
 			}
 
-			if err != nil {
+			if errors.Is(err, errForbidden) {
+				m.handleFailure(ctx, val, contentType, &models.Reply{
+					Status:  models.ReplyStatusForbidden,
+					Message: fmt.Sprintf("%s failed", logPrefix),
+					Error:   err.Error(),
+				}, false)
+				continue
+			} else if errors.Is(err, storage.ErrUserExists) {
+				m.handleFailure(ctx, val, contentType, &models.Reply{
+					Status:  models.ReplyStatusBadRequest,
+					Message: fmt.Sprintf("%s failed", logPrefix),
+					Error:   err.Error(),
+				}, false)
+				continue
+			} else if err != nil {
 				m.logger.Printf("Failed in %s: %s\n", logPrefix, err.Error())
-				val.Nack(false, false)
-				// m.publishMessageBack(val, contentType, []byte(fmt.Sprintf("Failed in %s: %s\n", logPrefix, err.Error())))
+				m.handleFailure(ctx, val, contentType, &models.Reply{
+					Status:  models.ReplyStatusError,
+					Message: fmt.Sprintf("%s failed", logPrefix),
+					Error:   err.Error(),
+				}, true)
 				continue
 			}
 
 			val.Ack(false)
 
-			_, err = proto.Marshal(response)
-			if err != nil {
+			reply := models.Reply{
+				Status:  models.ReplyStatusSuccess,
+				Message: fmt.Sprintf("%s succeeded", logPrefix),
+			}
+			if byteData, err := json.Marshal(response); err != nil {
 				m.logger.Printf("Failed to marshal response: %s\n", err.Error())
-				continue
+			} else {
+				reply.Data = byteData
 			}
-
-			// m.publishMessageBack(val, contentType, byteData)
+			m.publishMessageBack(val, contentType, &reply)
 		case <-ctx.Done():
 			m.logger.Printf("Context done, stopping %s consumer", logPrefix)
 			return
 		}
 	}
 }
+
+// publishMessageBack answers the caller of a command on its ReplyTo queue,
+// echoing the correlation id so the caller can match the reply.
+func (m *MsgBroker) publishMessageBack(val amqp.Delivery, contentType string, reply *models.Reply) {
+	if len(val.ReplyTo) == 0 {
+		return
+	}
+	body, err := json.Marshal(reply)
+	if err != nil {
+		m.logger.Printf("Failed to marshal reply: %s\n", err.Error())
+		return
+	}
+	err = m.channel.Publish(
+		"",          // exchange
+		val.ReplyTo, // routing key
+		false,       // mandatory
+		false,       // immediate
+		amqp.Publishing{
+			ContentType:   contentType,
+			CorrelationId: val.CorrelationId,
+			Body:          body,
+		},
+	)
+	if err != nil {
+		m.logger.Printf("Failed to publish reply to %s: %s\n", val.ReplyTo, err.Error())
+	}
+}
@@ -0,0 +1,237 @@
+package msgbroker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/ruziba3vich/users/internal/models"
+)
+
+const (
+	// DeadLetterExchange receives every command that ran out of attempts,
+	// routed by the name of the queue it was consumed from.
+	DeadLetterExchange = "dead_letters"
+
+	attemptHeader       = "x-attempt"
+	failureReasonHeader = "x-failure-reason"
+	failedAtHeader      = "x-failed-at"
+	originalQueueHeader = "x-original-queue"
+)
+
+type (
+	// RetryPolicy decides how often and how late a failed command is retried
+	// before it is parked in its dead-letter queue.
+	RetryPolicy struct {
+		MaxAttempts int
+		BaseDelay   time.Duration
+		MaxDelay    time.Duration
+	}
+
+	// DeadLetter is a command parked in a dead-letter queue.
+	DeadLetter struct {
+		Queue         string    `json:"queue"`
+		Reason        string    `json:"reason"`
+		Attempts      int       `json:"attempts"`
+		FailedAt      time.Time `json:"failed_at"`
+		CorrelationId string    `json:"correlation_id"`
+		Body          string    `json:"body"`
+	}
+)
+
+// Delay returns the backoff before the retry that follows the given attempt:
+// BaseDelay, 2*BaseDelay, 4*BaseDelay and so on, capped at MaxDelay.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	return delay
+}
+
+func retryQueueName(queue string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", queue, attempt)
+}
+
+func deadQueueName(queue string) string {
+	return queue + ".dead"
+}
+
+// DeclareRetryTopology declares, for the given work queue, one delayed-retry
+// queue per attempt and the dead-letter queue. A retry queue holds a message
+// for its TTL and then dead-letters it straight back into the work queue.
+func DeclareRetryTopology(ch *amqp.Channel, queue string, policy RetryPolicy) error {
+	if err := ch.ExchangeDeclare(DeadLetterExchange, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %s", err.Error())
+	}
+
+	dead, err := ch.QueueDeclare(deadQueueName(queue), true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %s", err.Error())
+	}
+	if err := ch.QueueBind(dead.Name, queue, DeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue: %s", err.Error())
+	}
+
+	for attempt := 1; attempt < policy.MaxAttempts; attempt++ {
+		_, err := ch.QueueDeclare(retryQueueName(queue, attempt), true, false, false, false, amqp.Table{
+			"x-message-ttl":             policy.Delay(attempt).Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue: %s", err.Error())
+		}
+	}
+	return nil
+}
+
+// attemptOf reports which attempt the delivery is; a message that has never
+// been retried carries no header and is the first attempt.
+func attemptOf(val amqp.Delivery) int {
+	switch v := val.Headers[attemptHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 1
+}
+
+// retry schedules another attempt of the delivery through the delayed-retry
+// queue of the current attempt. It reports false once attempts are exhausted.
+func (m *MsgBroker) retry(ctx context.Context, val amqp.Delivery) (bool, error) {
+	attempt := attemptOf(val)
+	if attempt >= m.policy.MaxAttempts {
+		return false, nil
+	}
+	headers := copyHeaders(val.Headers)
+	headers[attemptHeader] = int32(attempt + 1)
+	m.logger.Printf("Retrying message from %s in %s (attempt %d of %d)\n", val.RoutingKey, m.policy.Delay(attempt), attempt+1, m.policy.MaxAttempts)
+	return true, m.channel.PublishWithContext(ctx, "", retryQueueName(val.RoutingKey, attempt), false, false, republish(val, headers))
+}
+
+// deadLetter parks the delivery in the dead-letter queue of the queue it was
+// consumed from, recording why it failed.
+func (m *MsgBroker) deadLetter(ctx context.Context, val amqp.Delivery, reason string) error {
+	headers := copyHeaders(val.Headers)
+	headers[attemptHeader] = int32(attemptOf(val))
+	headers[failureReasonHeader] = reason
+	headers[failedAtHeader] = time.Now().UTC().Format(time.RFC3339)
+	headers[originalQueueHeader] = val.RoutingKey
+	m.logger.Printf("Dead-lettering message from %s: %s\n", val.RoutingKey, reason)
+	return m.channel.PublishWithContext(ctx, DeadLetterExchange, val.RoutingKey, false, false, republish(val, headers))
+}
+
+// handleFailure retries a failed command or, once it may not be retried any
+// more, dead-letters it and tells the caller how it ended.
+func (m *MsgBroker) handleFailure(ctx context.Context, val amqp.Delivery, contentType string, reply *models.Reply, retryable bool) {
+	if retryable {
+		scheduled, err := m.retry(ctx, val)
+		if err != nil {
+			m.logger.Printf("Failed to schedule retry: %s\n", err.Error())
+			val.Nack(false, true)
+			return
+		}
+		if scheduled {
+			val.Ack(false)
+			return
+		}
+	}
+	if err := m.deadLetter(ctx, val, reply.Error); err != nil {
+		m.logger.Printf("Failed to dead-letter message: %s\n", err.Error())
+		val.Nack(false, true)
+		return
+	}
+	val.Ack(false)
+	m.publishMessageBack(val, contentType, reply)
+}
+
+// ListDeadLetters returns up to limit messages parked for the given work
+// queue without removing them.
+func ListDeadLetters(ch *amqp.Channel, queue string, limit int) ([]DeadLetter, error) {
+	var letters []DeadLetter
+	var last uint64
+	for len(letters) < limit {
+		val, ok, err := ch.Get(deadQueueName(queue), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead-letter queue: %s", err.Error())
+		}
+		if !ok {
+			break
+		}
+		last = val.DeliveryTag
+		letter := DeadLetter{
+			Queue:         queue,
+			Attempts:      attemptOf(val),
+			CorrelationId: val.CorrelationId,
+			Body:          string(val.Body),
+		}
+		letter.Reason, _ = val.Headers[failureReasonHeader].(string)
+		if failedAt, ok := val.Headers[failedAtHeader].(string); ok {
+			letter.FailedAt, _ = time.Parse(time.RFC3339, failedAt)
+		}
+		letters = append(letters, letter)
+	}
+	if last > 0 {
+		if err := ch.Nack(last, true, true); err != nil {
+			return nil, fmt.Errorf("failed to requeue dead letters: %s", err.Error())
+		}
+	}
+	return letters, nil
+}
+
+// ReplayDeadLetters moves up to limit parked messages back into their work
+// queue with a fresh attempt budget and returns how many were moved.
+func ReplayDeadLetters(ctx context.Context, ch *amqp.Channel, queue string, limit int) (int, error) {
+	replayed := 0
+	for replayed < limit {
+		val, ok, err := ch.Get(deadQueueName(queue), false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read dead-letter queue: %s", err.Error())
+		}
+		if !ok {
+			break
+		}
+		headers := copyHeaders(val.Headers)
+		delete(headers, attemptHeader)
+		delete(headers, failureReasonHeader)
+		delete(headers, failedAtHeader)
+		delete(headers, originalQueueHeader)
+		delete(headers, "x-death")
+		if err := ch.PublishWithContext(ctx, "", queue, false, false, republish(val, headers)); err != nil {
+			val.Nack(false, true)
+			return replayed, fmt.Errorf("failed to replay dead letter: %s", err.Error())
+		}
+		if err := val.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to ack dead letter: %s", err.Error())
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	copied := amqp.Table{}
+	for k, v := range headers {
+		copied[k] = v
+	}
+	return copied
+}
+
+func republish(val amqp.Delivery, headers amqp.Table) amqp.Publishing {
+	return amqp.Publishing{
+		Headers:       headers,
+		ContentType:   val.ContentType,
+		CorrelationId: val.CorrelationId,
+		ReplyTo:       val.ReplyTo,
+		DeliveryMode:  amqp.Persistent,
+		Body:          val.Body,
+	}
+}
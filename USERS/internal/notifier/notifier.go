@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ruziba3vich/users/internal/config"
+)
+
+type (
+	// Message is a notification addressed to a user
+	Message struct {
+		To      string
+		Subject string
+		Body    string
+	}
+
+	// Notifier delivers messages to users, such as the links of the email
+	// verification and password reset flows
+	Notifier interface {
+		Notify(ctx context.Context, msg Message) error
+	}
+
+	// SMTPNotifier sends messages as plain text emails
+	SMTPNotifier struct {
+		addr string
+		from string
+		auth smtp.Auth
+	}
+
+	// LogNotifier writes messages to a logger, and to a file when one is
+	// configured, so the flows can be followed locally without a mail server
+	LogNotifier struct {
+		logger *log.Logger
+		path   string
+		mu     sync.Mutex
+	}
+)
+
+// New creates the notifier named by NOTIFIER, "smtp" or "log"
+func New(cfg *config.Config, logger *log.Logger) (Notifier, error) {
+	switch cfg.NotifierConfig.Kind {
+	case "smtp":
+		return NewSMTPNotifier(cfg.NotifierConfig), nil
+	case "log", "":
+		return NewLogNotifier(logger, cfg.NotifierConfig.LogFile), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %s", cfg.NotifierConfig.Kind)
+	}
+}
+
+func NewSMTPNotifier(cfg config.NotifierConfig) *SMTPNotifier {
+	var auth smtp.Auth
+	if len(cfg.SMTPUsername) > 0 {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return &SMTPNotifier{
+		addr: fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+		from: cfg.From,
+		auth: auth,
+	}
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, msg Message) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", n.from)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", msg.Subject)
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	body.WriteString(msg.Body)
+
+	if err := smtp.SendMail(n.addr, n.auth, n.from, []string{msg.To}, []byte(body.String())); err != nil {
+		return fmt.Errorf("could not send email to %s: %s", msg.To, err.Error())
+	}
+	return nil
+}
+
+func NewLogNotifier(logger *log.Logger, path string) *LogNotifier {
+	return &LogNotifier{
+		logger: logger,
+		path:   path,
+	}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, msg Message) error {
+	n.logger.Printf("NOTIFICATION TO %s: %s\n%s\n", msg.To, msg.Subject, msg.Body)
+	if len(n.path) == 0 {
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not open notifications file: %s", err.Error())
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "--- %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	if err != nil {
+		return fmt.Errorf("could not write notification: %s", err.Error())
+	}
+	return nil
+}
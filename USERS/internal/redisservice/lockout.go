@@ -0,0 +1,86 @@
+package redisservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Failed logins are counted per email address under login_failures:<email>
+// for failureWindow; once an address has too many, login_lock:<email> keeps it
+// locked. Keying by the address rather than the user means unknown addresses
+// lock the same way, so the lockout does not tell who is registered
+const (
+	loginFailuresPrefix = "login_failures:"
+	loginLockPrefix     = "login_lock:"
+	failureWindow       = 24 * time.Hour
+)
+
+type (
+	// LockoutPolicy locks an account for Base after Threshold failed logins,
+	// and for twice as long with every further failure, up to Max
+	LockoutPolicy struct {
+		Threshold int
+		Base      time.Duration
+		Max       time.Duration
+	}
+)
+
+// LockedFor returns how long an account stays locked, or 0 when it is not
+func (r *RedisService) LockedFor(ctx context.Context, email string) (time.Duration, error) {
+	ttl, err := r.redisDb.PTTL(ctx, loginLockPrefix+normalizeEmail(email)).Result()
+	if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return 0, err
+	}
+	// PTTL is negative for keys that do not exist or never expire
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// RecordLoginFailure counts a failed login and locks the account once the
+// policy says so. It returns how long the account is now locked for
+func (r *RedisService) RecordLoginFailure(ctx context.Context, email string, policy LockoutPolicy) (time.Duration, error) {
+	email = normalizeEmail(email)
+	failures, err := r.redisDb.Incr(ctx, loginFailuresPrefix+email).Result()
+	if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return 0, err
+	}
+	if err := r.redisDb.Expire(ctx, loginFailuresPrefix+email, failureWindow).Err(); err != nil {
+		return 0, err
+	}
+	if failures < int64(policy.Threshold) {
+		return 0, nil
+	}
+
+	lock := policy.Base
+	for i := int64(policy.Threshold); i < failures && lock < policy.Max; i++ {
+		lock *= 2
+	}
+	if lock > policy.Max {
+		lock = policy.Max
+	}
+	if err := r.redisDb.Set(ctx, loginLockPrefix+email, failures, lock).Err(); err != nil {
+		return 0, fmt.Errorf("could not lock account: %s", err.Error())
+	}
+	r.logger.Printf("ACCOUNT %s LOCKED FOR %s AFTER %d FAILED LOGINS\n", email, lock, failures)
+	return lock, nil
+}
+
+// ResetLoginFailures clears the failures and the lock of an account, after a
+// successful login or when an administrator unlocks it
+func (r *RedisService) ResetLoginFailures(ctx context.Context, email string) error {
+	email = normalizeEmail(email)
+	if err := r.redisDb.Del(ctx, loginFailuresPrefix+email, loginLockPrefix+email).Err(); err != nil {
+		return fmt.Errorf("could not reset login failures: %s", err.Error())
+	}
+	return nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
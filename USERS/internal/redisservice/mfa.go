@@ -0,0 +1,73 @@
+package redisservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// mfaPrefix keys the login challenges handed out between the password step
+// and the code step of a login, by the hash of the challenge
+const mfaPrefix = "mfa_challenge:"
+
+// maxMFAAttempts is how many codes can be tried against one challenge before
+// the login has to start over with the password
+const maxMFAAttempts = 5
+
+var (
+	ErrInvalidMFAChallenge = errors.New("invalid or expired login challenge")
+	ErrTooManyMFAAttempts  = errors.New("too many invalid codes, log in again")
+)
+
+// CreateMFAChallenge remembers that a user has passed the password step
+func (r *RedisService) CreateMFAChallenge(ctx context.Context, challenge, userId string, ttl time.Duration) error {
+	key := mfaKey(challenge)
+	_, err := r.redisDb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "user_id", userId, "attempts", 0)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		r.logger.Printf("ERROR WHILE CREATING LOGIN CHALLENGE FOR USER %s : %s\n", userId, err.Error())
+		return fmt.Errorf("could not create login challenge: %s", err.Error())
+	}
+	return nil
+}
+
+// MFAChallengeUser returns the user a challenge was handed to and counts an
+// attempt against it. The challenge is dropped once it runs out of attempts
+func (r *RedisService) MFAChallengeUser(ctx context.Context, challenge string) (string, error) {
+	key := mfaKey(challenge)
+	userId, err := r.redisDb.HGet(ctx, key, "user_id").Result()
+	if err == redis.Nil {
+		return "", ErrInvalidMFAChallenge
+	} else if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return "", err
+	}
+	attempts, err := r.redisDb.HIncrBy(ctx, key, "attempts", 1).Result()
+	if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return "", err
+	}
+	if attempts > maxMFAAttempts {
+		r.DeleteMFAChallenge(ctx, challenge)
+		return "", ErrTooManyMFAAttempts
+	}
+	return userId, nil
+}
+
+// DeleteMFAChallenge ends a challenge once the login has completed
+func (r *RedisService) DeleteMFAChallenge(ctx context.Context, challenge string) error {
+	return r.redisDb.Del(ctx, mfaKey(challenge)).Err()
+}
+
+func mfaKey(challenge string) string {
+	sum := sha256.Sum256([]byte(challenge))
+	return mfaPrefix + hex.EncodeToString(sum[:])
+}
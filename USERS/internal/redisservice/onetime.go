@@ -0,0 +1,49 @@
+package redisservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Purposes of the one-time tokens mailed to users. Each purpose has its own
+// key space, so a verification token cannot be used to reset a password
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+)
+
+var ErrInvalidOneTimeToken = errors.New("invalid or expired token")
+
+// StoreOneTimeToken keeps a token for a user until it is used or expires.
+// Only the token's hash is stored
+func (r *RedisService) StoreOneTimeToken(ctx context.Context, purpose, token, userId string, ttl time.Duration) error {
+	if err := r.redisDb.Set(ctx, oneTimeKey(purpose, token), userId, ttl).Err(); err != nil {
+		r.logger.Printf("ERROR WHILE STORING %s TOKEN FOR USER %s : %s\n", purpose, userId, err.Error())
+		return fmt.Errorf("could not store token: %s", err.Error())
+	}
+	return nil
+}
+
+// ConsumeOneTimeToken returns the user a token was issued to and removes the
+// token, so that it can be used only once
+func (r *RedisService) ConsumeOneTimeToken(ctx context.Context, purpose, token string) (string, error) {
+	userId, err := r.redisDb.GetDel(ctx, oneTimeKey(purpose, token)).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidOneTimeToken
+	} else if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return "", err
+	}
+	return userId, nil
+}
+
+func oneTimeKey(purpose, token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return purpose + ":" + hex.EncodeToString(sum[:])
+}
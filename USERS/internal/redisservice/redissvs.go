@@ -4,10 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+	"google.golang.org/protobuf/proto"
 )
 
 type (
@@ -17,6 +19,14 @@ type (
 	}
 )
 
+// userPrefix namespaces the cached users. Users used to be cached under their
+// bare id, password hash included; FlushLegacyUsers drops those entries
+const userPrefix = "user:"
+
+// legacyUserPattern matches the bare 24 hex digit ObjectIDs users used to be
+// cached under
+var legacyUserPattern = strings.Repeat("[0-9a-f]", 24)
+
 func New(redisDb *redis.Client, logger *log.Logger) *RedisService {
 	return &RedisService{
 		logger:  logger,
@@ -24,13 +34,17 @@ func New(redisDb *redis.Client, logger *log.Logger) *RedisService {
 	}
 }
 
+// StoreUserInRedis caches a user. The password hash is never cached, whatever
+// the caller hands in
 func (r *RedisService) StoreUserInRedis(ctx context.Context, user *genprotos.User) error {
-	userJSON, err := json.Marshal(user)
+	public := proto.Clone(user).(*genprotos.User)
+	public.Password = ""
+	userJSON, err := json.Marshal(public)
 	if err != nil {
 		return err
 	}
 
-	err = r.redisDb.Set(ctx, user.UserId, userJSON, time.Hour*24).Err()
+	err = r.redisDb.Set(ctx, userKey(user.UserId), userJSON, time.Hour*24).Err()
 	if err != nil {
 		return err
 	}
@@ -38,7 +52,7 @@ func (r *RedisService) StoreUserInRedis(ctx context.Context, user *genprotos.Use
 }
 
 func (r *RedisService) GetUserFromRedis(ctx context.Context, userId string) (*genprotos.User, error) {
-	userJSON, err := r.redisDb.Get(ctx, userId).Result()
+	userJSON, err := r.redisDb.Get(ctx, userKey(userId)).Result()
 	if err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
@@ -56,7 +70,7 @@ func (r *RedisService) GetUserFromRedis(ctx context.Context, userId string) (*ge
 }
 
 func (r *RedisService) DeleteUserFromRedis(ctx context.Context, userID string) error {
-	result, err := r.redisDb.Del(ctx, userID).Result()
+	result, err := r.redisDb.Del(ctx, userKey(userID)).Result()
 	if err != nil {
 		return err
 	}
@@ -69,3 +83,22 @@ func (r *RedisService) DeleteUserFromRedis(ctx context.Context, userID string) e
 
 	return nil
 }
+
+// FlushLegacyUsers deletes the users cached under their bare id, from before
+// the cache was namespaced and stopped holding password hashes, and returns
+// how many were dropped
+func (r *RedisService) FlushLegacyUsers(ctx context.Context) (int, error) {
+	flushed := 0
+	iter := r.redisDb.Scan(ctx, 0, legacyUserPattern, 100).Iterator()
+	for iter.Next(ctx) {
+		if err := r.redisDb.Del(ctx, iter.Val()).Err(); err != nil {
+			return flushed, err
+		}
+		flushed++
+	}
+	return flushed, iter.Err()
+}
+
+func userKey(userId string) string {
+	return userPrefix + userId
+}
@@ -0,0 +1,158 @@
+package redisservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Sessions live under session:<id> and are indexed per user under
+// user_sessions:<user id>. Refresh tokens are kept under refresh:<sha256 of the
+// token>, and a used one is kept until it expires so that presenting it again
+// is recognised as reuse. The gateway reads the session keys directly to tell
+// revoked access tokens apart
+const (
+	sessionPrefix      = "session:"
+	userSessionsPrefix = "user_sessions:"
+	refreshPrefix      = "refresh:"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used, the session has been revoked")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+type (
+	// RefreshSession is what a refresh token resolves to
+	RefreshSession struct {
+		UserId    string
+		SessionId string
+	}
+)
+
+// CreateSession starts a login session for a user and stores its first
+// refresh token
+func (r *RedisService) CreateSession(ctx context.Context, userId, sessionId, refreshToken string, ttl time.Duration) error {
+	_, err := r.redisDb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionPrefix+sessionId, "user_id", userId)
+		pipe.Expire(ctx, sessionPrefix+sessionId, ttl)
+		pipe.SAdd(ctx, userSessionsPrefix+userId, sessionId)
+		pipe.Expire(ctx, userSessionsPrefix+userId, ttl)
+		storeRefreshToken(ctx, pipe, userId, sessionId, refreshToken, ttl)
+		return nil
+	})
+	if err != nil {
+		r.logger.Printf("ERROR WHILE CREATING SESSION FOR USER %s : %s\n", userId, err.Error())
+		return fmt.Errorf("could not create session: %s", err.Error())
+	}
+	return nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one within the same
+// session. A refresh token can be used once; using it again revokes the whole
+// session, since either the client or an attacker holds a stolen copy
+func (r *RedisService) RotateRefreshToken(ctx context.Context, refreshToken, newRefreshToken string, ttl time.Duration) (*RefreshSession, error) {
+	key := refreshKey(refreshToken)
+	record, err := r.redisDb.HGetAll(ctx, key).Result()
+	if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return nil, err
+	}
+	session := RefreshSession{UserId: record["user_id"], SessionId: record["session_id"]}
+	if len(session.UserId) == 0 || len(session.SessionId) == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	uses, err := r.redisDb.HIncrBy(ctx, key, "used", 1).Result()
+	if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return nil, err
+	}
+	if uses > 1 {
+		r.logger.Printf("REFRESH TOKEN REUSE DETECTED FOR SESSION %s OF USER %s\n", session.SessionId, session.UserId)
+		if err := r.RevokeSession(ctx, session.UserId, session.SessionId); err != nil && err != ErrSessionNotFound {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	active, err := r.redisDb.Exists(ctx, sessionPrefix+session.SessionId).Result()
+	if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return nil, err
+	}
+	if active == 0 {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	_, err = r.redisDb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Expire(ctx, sessionPrefix+session.SessionId, ttl)
+		pipe.Expire(ctx, userSessionsPrefix+session.UserId, ttl)
+		storeRefreshToken(ctx, pipe, session.UserId, session.SessionId, newRefreshToken, ttl)
+		return nil
+	})
+	if err != nil {
+		r.logger.Printf("ERROR WHILE ROTATING REFRESH TOKEN OF SESSION %s : %s\n", session.SessionId, err.Error())
+		return nil, fmt.Errorf("could not rotate refresh token: %s", err.Error())
+	}
+	return &session, nil
+}
+
+// RevokeSession ends a session of a user, which invalidates its access and
+// refresh tokens
+func (r *RedisService) RevokeSession(ctx context.Context, userId, sessionId string) error {
+	owner, err := r.redisDb.HGet(ctx, sessionPrefix+sessionId, "user_id").Result()
+	if err == redis.Nil || (err == nil && owner != userId) {
+		return ErrSessionNotFound
+	} else if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return err
+	}
+	_, err = r.redisDb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, sessionPrefix+sessionId)
+		pipe.SRem(ctx, userSessionsPrefix+userId, sessionId)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not revoke session: %s", err.Error())
+	}
+	r.logger.Printf("Session %s of user %s has been revoked", sessionId, userId)
+	return nil
+}
+
+// RevokeAllSessions ends every session of a user
+func (r *RedisService) RevokeAllSessions(ctx context.Context, userId string) error {
+	sessionIds, err := r.redisDb.SMembers(ctx, userSessionsPrefix+userId).Result()
+	if err != nil {
+		r.logger.Printf("ERROR WHILE GETTING DATA FROM REDIS : %s\n", err.Error())
+		return err
+	}
+	keys := []string{userSessionsPrefix + userId}
+	for _, sessionId := range sessionIds {
+		keys = append(keys, sessionPrefix+sessionId)
+	}
+	if err := r.redisDb.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("could not revoke sessions: %s", err.Error())
+	}
+	r.logger.Printf("%d sessions of user %s have been revoked", len(sessionIds), userId)
+	return nil
+}
+
+func storeRefreshToken(ctx context.Context, pipe redis.Pipeliner, userId, sessionId, refreshToken string, ttl time.Duration) {
+	key := refreshKey(refreshToken)
+	pipe.HSet(ctx, key, "user_id", userId, "session_id", sessionId, "used", 0)
+	pipe.Expire(ctx, key, ttl)
+}
+
+// refreshKey keys refresh tokens by their hash, so a read of Redis does not
+// hand out usable tokens
+func refreshKey(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return refreshPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+	"github.com/ruziba3vich/users/internal/notifier"
+	"github.com/ruziba3vich/users/internal/redisservice"
+)
+
+// minPasswordLength is the shortest password a reset accepts
+const minPasswordLength = 8
+
+// VerifyEmail activates the account a verification token was mailed for
+func (s *Service) VerifyEmail(ctx context.Context, req *genprotos.VerifyEmailRequest) (*genprotos.Response, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <VerifyEmail> SERVICE --")
+	userId, err := s.redis.ConsumeOneTimeToken(ctx, redisservice.PurposeVerifyEmail, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.MarkEmailVerified(ctx, userId); err != nil {
+		return nil, err
+	}
+	return &genprotos.Response{
+		Message: "email address has successfully been verified",
+	}, nil
+}
+
+// ForgotPassword mails a password reset link. It answers the same whether or
+// not the address belongs to an account, so it cannot be used to find out
+// who is registered
+func (s *Service) ForgotPassword(ctx context.Context, req *genprotos.ForgotPasswordRequest) (*genprotos.Response, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <ForgotPassword> SERVICE --")
+	response := genprotos.Response{
+		Message: "if the address belongs to an account, a password reset link has been sent to it",
+	}
+	user, err := s.storage.GetUserByEmail(ctx, &genprotos.GetByFieldRequest{GetByField: req.Email})
+	if err != nil {
+		return &response, nil
+	}
+
+	token, err := s.tokens.NewOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redis.StoreOneTimeToken(ctx, redisservice.PurposeResetPassword, token, user.UserId, s.tokenConfig.ResetTokenTTL); err != nil {
+		return nil, err
+	}
+	err = s.notifier.Notify(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nuse the token below to choose a new password. It expires in %s.\n\n%s\n\nSend it with your new password to %s/users/password/reset. If you did not ask for this, ignore this email.\n",
+			user.Username, s.tokenConfig.ResetTokenTTL, token, s.publicURL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword and ends
+// every session of the user
+func (s *Service) ResetPassword(ctx context.Context, req *genprotos.ResetPasswordRequest) (*genprotos.Response, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <ResetPassword> SERVICE --")
+	if len(req.Password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters long", minPasswordLength)
+	}
+	userId, err := s.redis.ConsumeOneTimeToken(ctx, redisservice.PurposeResetPassword, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.SetPassword(ctx, userId, req.Password); err != nil {
+		return nil, err
+	}
+	if err := s.redis.RevokeAllSessions(ctx, userId); err != nil {
+		return nil, err
+	}
+	return &genprotos.Response{
+		Message: "password has successfully been reset",
+	}, nil
+}
+
+func (s *Service) sendVerification(ctx context.Context, user *genprotos.User) error {
+	token, err := s.tokens.NewOneTimeToken()
+	if err != nil {
+		return err
+	}
+	if err := s.redis.StoreOneTimeToken(ctx, redisservice.PurposeVerifyEmail, token, user.UserId, s.tokenConfig.VerificationTokenTTL); err != nil {
+		return err
+	}
+	return s.notifier.Notify(ctx, notifier.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nopen the link below to verify your email address. It expires in %s.\n\n%s/users/verify?token=%s\n",
+			user.Username, s.tokenConfig.VerificationTokenTTL, s.publicURL, url.QueryEscape(token)),
+	})
+}
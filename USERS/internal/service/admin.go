@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ruziba3vich/shared/events"
+	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// isAdmin reports whether the user the gateway forwarded as the actor of the
+// call is an administrator. It is read from the database rather than from the
+// token, so a revoked grant takes effect at once
+func (s *Service) isAdmin(ctx context.Context) bool {
+	user, err := s.storage.FindUserById(ctx, events.ActorFromContext(ctx))
+	return err == nil && user.Admin
+}
+
+// requireAdmin refuses a call that is not made by an administrator
+func (s *Service) requireAdmin(ctx context.Context, action string) error {
+	if !s.isAdmin(ctx) {
+		return adminOnly(action)
+	}
+	return nil
+}
+
+// adminOnly is the error refusing action to someone who is not an
+// administrator
+func adminOnly(action string) error {
+	return status.Errorf(codes.PermissionDenied, "only administrators can %s", action)
+}
+
+// hidePrivateFields strips what only administrators may see of other users,
+// their email and address, from a listing
+func hidePrivateFields(response *genprotos.GetAllUsersResponse) *genprotos.GetAllUsersResponse {
+	for _, user := range response.Users {
+		user.Email = ""
+		if user.Profile != nil {
+			user.Profile.Address = ""
+		}
+	}
+	return response
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// UnlockAccount lifts the lockout of an account, by its email address. Only
+// administrators can unlock accounts
+func (s *Service) UnlockAccount(ctx context.Context, req *genprotos.GetByFieldRequest) (*genprotos.Response, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <UnlockAccount> SERVICE --")
+	if err := s.requireAdmin(ctx, "unlock accounts"); err != nil {
+		return nil, err
+	}
+	if err := s.redis.ResetLoginFailures(ctx, req.GetByField); err != nil {
+		return nil, err
+	}
+	return &genprotos.Response{
+		Message: "account has successfully been unlocked",
+	}, nil
+}
+
+// checkLockout refuses a login attempt on a locked account
+func (s *Service) checkLockout(ctx context.Context, email string) error {
+	locked, err := s.redis.LockedFor(ctx, email)
+	if err != nil {
+		return err
+	}
+	if locked > 0 {
+		return lockedError(locked)
+	}
+	return nil
+}
+
+// loginFailed counts a failed login against an account and returns the error
+// to answer with, which says for how long the account is locked once it is
+func (s *Service) loginFailed(ctx context.Context, email string, cause error) error {
+	locked, err := s.redis.RecordLoginFailure(ctx, email, s.lockout)
+	if err != nil {
+		s.logger.Printf("ERROR WHILE RECORDING FAILED LOGIN OF %s : %s\n", email, err.Error())
+	}
+	if locked > 0 {
+		return lockedError(locked)
+	}
+	return status.Error(codes.Unauthenticated, cause.Error())
+}
+
+// lockedError is a RESOURCE_EXHAUSTED status carrying how long to wait as
+// RetryInfo, which the gateway turns into a Retry-After header
+func lockedError(locked time.Duration) error {
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("account is locked after too many failed logins, try again in %s", locked.Round(time.Second)))
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(locked)})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
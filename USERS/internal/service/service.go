@@ -6,24 +6,42 @@ import (
 	"log"
 
 	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+	"github.com/ruziba3vich/users/internal/config"
+	"github.com/ruziba3vich/users/internal/models"
+	"github.com/ruziba3vich/users/internal/notifier"
 	"github.com/ruziba3vich/users/internal/redisservice"
 	"github.com/ruziba3vich/users/internal/storage"
+	"github.com/ruziba3vich/users/internal/utils"
 )
 
 type (
 	Service struct {
-		storage *storage.Storage
-		redis   *redisservice.RedisService
-		logger  *log.Logger
+		storage     *storage.Storage
+		redis       *redisservice.RedisService
+		tokens      *utils.TokenGenerator
+		notifier    notifier.Notifier
+		tokenConfig config.TokenConfig
+		publicURL   string
+		lockout     redisservice.LockoutPolicy
+		logger      *log.Logger
 		genprotos.UnimplementedUsersServiceServer
 	}
 )
 
-func New(storage *storage.Storage, redis *redisservice.RedisService, logger *log.Logger) *Service {
+func New(storage *storage.Storage, redis *redisservice.RedisService, tokens *utils.TokenGenerator, notifier notifier.Notifier, cfg *config.Config, logger *log.Logger) *Service {
 	return &Service{
-		storage: storage,
-		redis:   redis,
-		logger:  logger,
+		storage:     storage,
+		redis:       redis,
+		tokens:      tokens,
+		notifier:    notifier,
+		tokenConfig: cfg.TokenConfig,
+		publicURL:   cfg.NotifierConfig.PublicURL,
+		lockout: redisservice.LockoutPolicy{
+			Threshold: cfg.LockoutConfig.Threshold,
+			Base:      cfg.LockoutConfig.Base,
+			Max:       cfg.LockoutConfig.Max,
+		},
+		logger: logger,
 	}
 }
 
@@ -35,7 +53,12 @@ func (s *Service) RegisterUser(ctx context.Context, req *genprotos.CreateUserReu
 		if err := s.redis.StoreUserInRedis(ctx, user); err != nil {
 			return nil, err
 		}
-		response.Message = "user has successfully been registered"
+		// the account exists either way; a lost email can be recovered
+		// through the password reset flow
+		if err := s.sendVerification(ctx, user); err != nil {
+			s.logger.Printf("ERROR WHILE SENDING VERIFICATION TO %s : %s\n", user.Email, err.Error())
+		}
+		response.Message = "user has successfully been registered, check your email to verify the account"
 		return &response, nil
 	}
 	response.Message = "failed to register user"
@@ -44,11 +67,104 @@ func (s *Service) RegisterUser(ctx context.Context, req *genprotos.CreateUserReu
 
 func (s *Service) LoginUser(ctx context.Context, req *genprotos.LoginRequest) (*genprotos.RegisterUserResponse, error) {
 	s.logger.Println("-- RECEIVED A REQUEST IN <LoginUser> SERVICE --")
-	response, err := s.storage.LoginUser(ctx, req)
-	if err == nil {
-		s.redis.StoreUserInRedis(ctx, response.User)
+	if err := s.checkLockout(ctx, req.Email); err != nil {
+		return nil, err
+	}
+	user, err := s.storage.AuthenticateUser(ctx, req)
+	if err == storage.ErrInvalidCredentials {
+		return nil, s.loginFailed(ctx, req.Email, err)
+	} else if err != nil {
+		return nil, err
+	}
+	s.redis.StoreUserInRedis(ctx, user.ToPublicProtoUser())
+
+	if user.TOTPEnabled {
+		return s.challengeLogin(ctx, user)
+	}
+	return s.startSession(ctx, user)
+}
+
+// startSession opens a login session for an authenticated user
+func (s *Service) startSession(ctx context.Context, user *models.User) (*genprotos.RegisterUserResponse, error) {
+	if err := s.redis.ResetLoginFailures(ctx, user.Email); err != nil {
+		s.logger.Printf("ERROR WHILE RESETTING FAILED LOGINS OF %s : %s\n", user.Email, err.Error())
+	}
+	sessionId, err := s.tokens.NewSessionId()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := s.tokens.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redis.CreateSession(ctx, user.Id.Hex(), sessionId, refreshToken, s.tokens.RefreshTokenTTL()); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user, sessionId, refreshToken)
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new
+// refresh token of the same session
+func (s *Service) RefreshToken(ctx context.Context, req *genprotos.RefreshTokenRequest) (*genprotos.RegisterUserResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <RefreshToken> SERVICE --")
+	refreshToken, err := s.tokens.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.redis.RotateRefreshToken(ctx, req.RefreshToken, refreshToken, s.tokens.RefreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.storage.FindUserById(ctx, session.UserId)
+	if err != nil {
+		s.redis.RevokeSession(ctx, session.UserId, session.SessionId)
+		return nil, err
+	}
+	return s.issueTokens(user, session.SessionId, refreshToken)
+}
+
+// Logout ends the session the request's access token belongs to
+func (s *Service) Logout(ctx context.Context, req *genprotos.LogoutRequest) (*genprotos.Response, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <Logout> SERVICE --")
+	if err := s.redis.RevokeSession(ctx, req.UserId, req.SessionId); err != nil {
+		return nil, err
+	}
+	return &genprotos.Response{
+		Message: "user has successfully been logged out",
+	}, nil
+}
+
+// LogoutAll ends every session of a user
+func (s *Service) LogoutAll(ctx context.Context, req *genprotos.LogoutRequest) (*genprotos.Response, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <LogoutAll> SERVICE --")
+	if err := s.redis.RevokeAllSessions(ctx, req.UserId); err != nil {
+		return nil, err
 	}
-	return response, err
+	return &genprotos.Response{
+		Message: "user has successfully been logged out of all sessions",
+	}, nil
+}
+
+// GetJWKS publishes the public keys access tokens are signed with
+func (s *Service) GetJWKS(ctx context.Context, req *genprotos.JWKSRequest) (*genprotos.JWKS, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <GetJWKS> SERVICE --")
+	return s.tokens.JWKS(), nil
+}
+
+func (s *Service) issueTokens(user *models.User, sessionId, refreshToken string) (*genprotos.RegisterUserResponse, error) {
+	token, expiresAt, err := s.tokens.GenerateToken(user.Id.Hex(), user.Username, sessionId, user.Houses)
+	if err != nil {
+		s.logger.Printf("ERROR WHILE GENERATING TOKEN FOR USER %s\n", user.Email)
+		return nil, err
+	}
+	return &genprotos.RegisterUserResponse{
+		User: user.ToPublicProtoUser(),
+		Token: &genprotos.Token{
+			StringToken:  token,
+			RefreshToken: refreshToken,
+			ExpiresAt:    expiresAt.Unix(),
+		},
+	}, nil
 }
 
 func (s *Service) GetById(ctx context.Context, req *genprotos.GetByFieldRequest) (*genprotos.User, error) {
@@ -89,7 +205,22 @@ func (s *Service) UpdateUser(ctx context.Context, req *genprotos.UpdateUserReuqe
 
 func (s *Service) GetAllUsers(ctx context.Context, req *genprotos.GetAllUsersRequest) (*genprotos.GetAllUsersResponse, error) {
 	s.logger.Println("-- RECEIVED A REQUEST IN <GetAllUsers> SERVICE --")
-	return s.storage.GetAllUsers(ctx, req)
+	admin := s.isAdmin(ctx)
+	if req.Deleted && !admin {
+		return nil, adminOnly("list deleted users")
+	}
+	// emails and addresses are private, so is filtering or sorting by them
+	if len(req.Address) > 0 && !admin {
+		return nil, adminOnly("filter users by address")
+	}
+	if req.SortBy == "email" && !admin {
+		return nil, adminOnly("sort users by email")
+	}
+	response, err := s.storage.GetAllUsers(ctx, req)
+	if err != nil || admin {
+		return response, err
+	}
+	return hidePrivateFields(response), nil
 }
 
 func (s *Service) DeleteUserById(ctx context.Context, req *genprotos.GetByFieldRequest) (*genprotos.Response, error) {
@@ -100,6 +231,9 @@ func (s *Service) DeleteUserById(ctx context.Context, req *genprotos.GetByFieldR
 	if err := s.redis.DeleteUserFromRedis(ctx, req.GetByField); err != nil {
 		return nil, err
 	}
+	if err := s.redis.RevokeAllSessions(ctx, req.GetByField); err != nil {
+		return nil, err
+	}
 
 	return &genprotos.Response{
 		Message: "user has successfully been deleted",
@@ -108,9 +242,40 @@ func (s *Service) DeleteUserById(ctx context.Context, req *genprotos.GetByFieldR
 
 func (s *Service) GetUsersByAddress(ctx context.Context, req *genprotos.GetUsersByAddressRequest) (*genprotos.GetAllUsersResponse, error) {
 	s.logger.Println("-- RECEIVED A REQUEST IN <GetUsersByAddress> SERVICE --")
+	if err := s.requireAdmin(ctx, "look users up by address"); err != nil {
+		return nil, err
+	}
 	return s.storage.GetUserByAddress(ctx, req)
 }
 
+func (s *Service) Search(ctx context.Context, req *genprotos.SearchRequest) (*genprotos.GetAllUsersResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <Search> SERVICE --")
+	admin := s.isAdmin(ctx)
+	response, err := s.storage.Search(ctx, req, admin)
+	if err != nil || admin {
+		return response, err
+	}
+	return hidePrivateFields(response), nil
+}
+
+// AddUserHouse keeps the user's houses in step with a house.member_added event
+func (s *Service) AddUserHouse(ctx context.Context, userId, houseId string) error {
+	s.logger.Println("-- RECEIVED A REQUEST IN <AddUserHouse> SERVICE --")
+	return s.storage.AddUserHouse(ctx, userId, houseId)
+}
+
+// RemoveUserHouse keeps the user's houses in step with a house.member_removed event
+func (s *Service) RemoveUserHouse(ctx context.Context, userId, houseId string) error {
+	s.logger.Println("-- RECEIVED A REQUEST IN <RemoveUserHouse> SERVICE --")
+	return s.storage.RemoveUserHouse(ctx, userId, houseId)
+}
+
+// DropHouse keeps the users' houses in step with a house.deleted event
+func (s *Service) DropHouse(ctx context.Context, houseId string) error {
+	s.logger.Println("-- RECEIVED A REQUEST IN <DropHouse> SERVICE --")
+	return s.storage.DropHouse(ctx, houseId)
+}
+
 /*
    rpc RegisterUser(CreateUserReuest) returns (Response); /// ------------
    rpc LoginUser(LoginRequest) returns (RegisterUserResponse); -----------
@@ -121,4 +286,17 @@ func (s *Service) GetUsersByAddress(ctx context.Context, req *genprotos.GetUsers
    rpc GetAllUsers(google.protobuf.Empty) returns (GetAllUsersResponse); ----------
    rpc DeleteUserById(GetByFieldRequest) returns (Response); /// -----------
    rpc GetUsersByAddress(GetUsersByAddressRequest) returns (GetAllUsersResponse);
+   rpc RefreshToken(RefreshTokenRequest) returns (RegisterUserResponse);
+   rpc Logout(LogoutRequest) returns (Response);
+   rpc LogoutAll(LogoutRequest) returns (Response);
+   rpc GetJWKS(JWKSRequest) returns (JWKS);
+   rpc VerifyEmail(VerifyEmailRequest) returns (Response);
+   rpc ForgotPassword(ForgotPasswordRequest) returns (Response);
+   rpc ResetPassword(ResetPasswordRequest) returns (Response);
+   rpc EnrollTOTP(TOTPRequest) returns (TOTPEnrollment);
+   rpc ConfirmTOTP(TOTPRequest) returns (RecoveryCodes);
+   rpc DisableTOTP(TOTPRequest) returns (Response);
+   rpc CompleteLogin(CompleteLoginRequest) returns (RegisterUserResponse);
+   rpc UnlockAccount(GetByFieldRequest) returns (Response);
+   rpc Search(SearchRequest) returns (GetAllUsersResponse);
 */
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+	"github.com/ruziba3vich/users/internal/models"
+	"github.com/ruziba3vich/users/internal/utils"
+)
+
+// mfaChallengeTTL is how long a user has to enter their code after their
+// password
+const mfaChallengeTTL = 5 * time.Minute
+
+// EnrollTOTP starts two-factor enrolment with a new secret. 2FA is only on
+// once ConfirmTOTP has seen a code generated from it
+func (s *Service) EnrollTOTP(ctx context.Context, req *genprotos.TOTPRequest) (*genprotos.TOTPEnrollment, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <EnrollTOTP> SERVICE --")
+	user, err := s.storage.FindUserById(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := utils.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.storage.SetTOTPSecret(ctx, req.UserId, secret); err != nil {
+		return nil, err
+	}
+	return &genprotos.TOTPEnrollment{
+		Secret:     secret,
+		OtpauthUri: utils.TOTPURI(user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP turns 2FA on once the user proves their authenticator works,
+// and hands out the recovery codes. They are only ever shown here
+func (s *Service) ConfirmTOTP(ctx context.Context, req *genprotos.TOTPRequest) (*genprotos.RecoveryCodes, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <ConfirmTOTP> SERVICE --")
+	user, err := s.storage.FindUserById(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+	if len(user.TOTPSecret) == 0 {
+		return nil, fmt.Errorf("two-factor authentication has not been enrolled")
+	}
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, req.Code, time.Now())
+	if !ok {
+		return nil, fmt.Errorf("invalid code")
+	}
+
+	codes, err := utils.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, utils.HashRecoveryCode(code))
+	}
+	if err := s.storage.EnableTOTP(ctx, req.UserId, step, hashes); err != nil {
+		return nil, err
+	}
+	return &genprotos.RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableTOTP turns 2FA off, which takes a current code or a recovery code
+func (s *Service) DisableTOTP(ctx context.Context, req *genprotos.TOTPRequest) (*genprotos.Response, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <DisableTOTP> SERVICE --")
+	user, err := s.storage.FindUserById(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, fmt.Errorf("two-factor authentication is not enabled")
+	}
+	if err := s.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return nil, err
+	}
+	if err := s.storage.DisableTOTP(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+	return &genprotos.Response{
+		Message: "two-factor authentication has successfully been disabled",
+	}, nil
+}
+
+// CompleteLogin is the second step of a login with 2FA on: it exchanges the
+// challenge LoginUser returned and a code for the tokens
+func (s *Service) CompleteLogin(ctx context.Context, req *genprotos.CompleteLoginRequest) (*genprotos.RegisterUserResponse, error) {
+	s.logger.Println("-- RECEIVED A REQUEST IN <CompleteLogin> SERVICE --")
+	userId, err := s.redis.MFAChallengeUser(ctx, req.MfaChallenge)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.storage.FindUserById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkLockout(ctx, user.Email); err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, user, req.Code); err != nil {
+		return nil, s.loginFailed(ctx, user.Email, err)
+	}
+	if err := s.redis.DeleteMFAChallenge(ctx, req.MfaChallenge); err != nil {
+		s.logger.Printf("ERROR WHILE DELETING LOGIN CHALLENGE OF USER %s : %s\n", userId, err.Error())
+	}
+	return s.startSession(ctx, user)
+}
+
+// challengeLogin answers the password step of a login with 2FA on
+func (s *Service) challengeLogin(ctx context.Context, user *models.User) (*genprotos.RegisterUserResponse, error) {
+	challenge, err := s.tokens.NewOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redis.CreateMFAChallenge(ctx, challenge, user.Id.Hex(), mfaChallengeTTL); err != nil {
+		return nil, err
+	}
+	return &genprotos.RegisterUserResponse{
+		User:         user.ToPublicProtoUser(),
+		MfaRequired:  true,
+		MfaChallenge: challenge,
+	}, nil
+}
+
+// verifySecondFactor accepts a TOTP code that has not been used yet, or an
+// unused recovery code
+func (s *Service) verifySecondFactor(ctx context.Context, user *models.User, code string) error {
+	if step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now()); ok {
+		claimed, err := s.storage.ClaimTOTPStep(ctx, user.Id.Hex(), step)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			return fmt.Errorf("code has already been used")
+		}
+		return nil
+	}
+	used, err := s.storage.UseRecoveryCode(ctx, user.Id.Hex(), utils.HashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return fmt.Errorf("invalid code")
+	}
+	return nil
+}
@@ -3,9 +3,10 @@ package storage
 import (
 	"context"
 	"fmt"
-	"hash"
 	"log"
 
+	"github.com/ruziba3vich/shared/events"
+	"github.com/ruziba3vich/shared/outbox"
 	"github.com/ruziba3vich/users/internal/config"
 	"github.com/ruziba3vich/users/internal/utils"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -14,23 +15,22 @@ import (
 
 type (
 	DB struct {
-		Client          *mongo.Client
-		UsersCollection *mongo.Collection
+		Client           *mongo.Client
+		UsersCollection  *mongo.Collection
+		OutboxCollection *mongo.Collection
 	}
 	Storage struct {
 		database       *DB
 		logger         *log.Logger
 		passwordHasher *utils.PasswordHasher
-		tokenGenerator *utils.TokenGenerator
 	}
 )
 
-func NewStorage(database *DB, logger *log.Logger, hash hash.Hash, cfg *config.Config) *Storage {
+func NewStorage(database *DB, logger *log.Logger) *Storage {
 	return &Storage{
 		database:       database,
 		logger:         logger,
 		passwordHasher: utils.NewPasswordHasher(),
-		tokenGenerator: utils.NewTokenGenerator(cfg),
 	}
 }
 
@@ -48,8 +48,9 @@ func ConnectDB(cfg *config.Config, ctx context.Context) (*DB, error) {
 	}
 
 	return &DB{
-		Client:          client,
-		UsersCollection: client.Database(cfg.DbConfig.MongoDB).Collection(cfg.DbConfig.Collection),
+		Client:           client,
+		UsersCollection:  client.Database(cfg.DbConfig.MongoDB).Collection(cfg.DbConfig.Collection),
+		OutboxCollection: client.Database(cfg.DbConfig.MongoDB).Collection(outbox.CollectionName),
 	}, nil
 }
 
@@ -60,3 +61,32 @@ func (db *DB) DisconnectDB(ctx context.Context) error {
 	}
 	return nil
 }
+
+// withTransaction runs fn in a Mongo transaction, so an entity change and the
+// outbox row announcing it are committed or rolled back together
+func (s *Storage) withTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := s.database.Client.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %s", err.Error())
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// writeEvent wraps payload in a domain event envelope and stores it as an
+// outbox row inside the transaction of sessCtx
+func (s *Storage) writeEvent(sessCtx mongo.SessionContext, eventType string, payload interface{}) error {
+	msg, err := outbox.NewMessage(eventType, events.New(sessCtx, eventType, payload))
+	if err != nil {
+		return err
+	}
+	if _, err := s.database.OutboxCollection.InsertOne(sessCtx, msg); err != nil {
+		s.logger.Printf("Failed to insert outbox row: %s", err.Error())
+		return fmt.Errorf("failed to insert outbox row: %s", err.Error())
+	}
+	return nil
+}
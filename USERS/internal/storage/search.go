@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+	"github.com/ruziba3vich/users/internal/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// userSearchFields are the fields administrators search users by;
+// UserSearchIndex is the text index over them, which the migrations create.
+// Everyone else only searches publicSearchFields.
+var (
+	userSearchFields   = []string{"username", "profile.name", "email", "profile.address"}
+	publicSearchFields = []string{"username", "profile.name"}
+	UserSearchIndex    = textIndex(userSearchFields)
+)
+
+// Search finds the users matching a query, by any of userSearchFields when
+// everyField is set and by publicSearchFields otherwise
+func (s *Storage) Search(ctx context.Context, req *genprotos.SearchRequest, everyField bool) (*genprotos.GetAllUsersResponse, error) {
+	query := strings.TrimSpace(req.Query)
+	if len(query) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "query is required")
+	}
+	filter := bson.M{"deleted": false}
+	var findOptions *options.FindOptions
+	if everyField {
+		findOptions = searchQuery(filter, query, req.Prefix, req.Limit, userSearchFields)
+	} else {
+		findOptions = matchQuery(filter, query, req.Prefix, req.Limit, publicSearchFields)
+	}
+
+	cursor, err := s.database.UsersCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		s.logger.Printf("FAILED TO SEARCH USERS: %s", err.Error())
+		return nil, fmt.Errorf("failed to search users: %s", err.Error())
+	}
+	defer cursor.Close(ctx)
+
+	var response genprotos.GetAllUsersResponse
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			s.logger.Printf("Failed to decode user: %s", err.Error())
+			return nil, fmt.Errorf("failed to decode user: %s", err.Error())
+		}
+		response.Users = append(response.Users, user.ToPublicProtoUser())
+	}
+
+	if err := cursor.Err(); err != nil {
+		s.logger.Printf("Cursor error: %s", err.Error())
+		return nil, fmt.Errorf("cursor error: %s", err.Error())
+	}
+
+	return &response, nil
+}
+
+// searchQuery narrows filter down to the documents matching query and
+// returns the options listing them. Whole words are looked up in the text
+// index and ranked by relevance; with prefix, the documents with a word
+// starting with query in one of fields are listed instead, which is what
+// autocomplete needs while the last word is being typed
+func searchQuery(filter bson.M, query string, prefix bool, limit int32, fields []string) *options.FindOptions {
+	if prefix {
+		return matchQuery(filter, query, prefix, limit, fields)
+	}
+	score := bson.M{"$meta": "textScore"}
+	filter["$text"] = bson.M{"$search": query}
+	return options.Find().SetLimit(int64(searchLimit(limit))).SetProjection(bson.M{"score": score}).SetSort(bson.M{"score": score})
+}
+
+// matchQuery is searchQuery without the text index, for fields that are only
+// part of it: the documents with query as a word of one of fields are
+// listed, or with prefix a word starting with query
+func matchQuery(filter bson.M, query string, prefix bool, limit int32, fields []string) *options.FindOptions {
+	pattern := `(^|\s)` + regexp.QuoteMeta(query)
+	if !prefix {
+		pattern += `(\s|$)`
+	}
+	matches := bson.A{}
+	for _, field := range fields {
+		matches = append(matches, bson.M{field: primitive.Regex{Pattern: pattern, Options: "i"}})
+	}
+	filter["$or"] = matches
+	return options.Find().SetLimit(int64(searchLimit(limit))).SetSort(bson.D{{Key: fields[0], Value: 1}, {Key: "_id", Value: 1}})
+}
+
+// searchLimit bounds the number of results a search asks for
+func searchLimit(limit int32) int32 {
+	if limit <= 0 {
+		return defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		return maxSearchLimit
+	}
+	return limit
+}
+
+func textIndex(fields []string) bson.D {
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: "text"})
+	}
+	return keys
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SetTOTPSecret stores the secret of a TOTP enrolment that has not been
+// confirmed yet. Enrolling again replaces it, enrolling while 2FA is on is
+// refused
+func (s *Storage) SetTOTPSecret(ctx context.Context, userId, secret string) error {
+	filter, err := userFilter(userId)
+	if err != nil {
+		return err
+	}
+	filter["totp_enabled"] = bson.M{"$ne": true}
+	result, err := s.database.UsersCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_secret": secret}})
+	if err != nil {
+		s.logger.Printf("Failed to store TOTP secret of user %s: %s\n", userId, err.Error())
+		return fmt.Errorf("failed to update user: %s", err.Error())
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("two-factor authentication is already enabled or no user found with ID: %s", userId)
+	}
+	return nil
+}
+
+// EnableTOTP turns 2FA on with the given recovery code hashes
+func (s *Storage) EnableTOTP(ctx context.Context, userId string, step int64, recoveryCodes []string) error {
+	return s.updateAccount(ctx, userId, bson.M{"$set": bson.M{
+		"totp_enabled":   true,
+		"totp_last_step": step,
+		"recovery_codes": recoveryCodes,
+	}})
+}
+
+// DisableTOTP turns 2FA off and forgets the secret and the recovery codes
+func (s *Storage) DisableTOTP(ctx context.Context, userId string) error {
+	return s.updateAccount(ctx, userId, bson.M{"$unset": bson.M{
+		"totp_secret":    "",
+		"totp_enabled":   "",
+		"totp_last_step": "",
+		"recovery_codes": "",
+	}})
+}
+
+// ClaimTOTPStep records that the code of a time step has been used. It
+// reports false when that step, or a later one, was claimed already, which
+// makes every code usable once
+func (s *Storage) ClaimTOTPStep(ctx context.Context, userId string, step int64) (bool, error) {
+	filter, err := userFilter(userId)
+	if err != nil {
+		return false, err
+	}
+	filter["totp_last_step"] = bson.M{"$not": bson.M{"$gte": step}}
+	result, err := s.database.UsersCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"totp_last_step": step}})
+	if err != nil {
+		s.logger.Printf("Failed to claim TOTP step of user %s: %s\n", userId, err.Error())
+		return false, fmt.Errorf("failed to update user: %s", err.Error())
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// UseRecoveryCode removes a recovery code hash from a user. It reports false
+// when the user has no such code
+func (s *Storage) UseRecoveryCode(ctx context.Context, userId, codeHash string) (bool, error) {
+	filter, err := userFilter(userId)
+	if err != nil {
+		return false, err
+	}
+	filter["recovery_codes"] = codeHash
+	result, err := s.database.UsersCollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"recovery_codes": codeHash}})
+	if err != nil {
+		s.logger.Printf("Failed to use recovery code of user %s: %s\n", userId, err.Error())
+		return false, fmt.Errorf("failed to update user: %s", err.Error())
+	}
+	return result.ModifiedCount > 0, nil
+}
@@ -2,14 +2,27 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/ruziba3vich/shared/events"
+	"github.com/ruziba3vich/shared/pagination"
 	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
 	"github.com/ruziba3vich/users/internal/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrInvalidCredentials is returned for a failed login
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrUserExists is returned when another active user already has the
+	// email or username, as enforced by the unique indexes
+	ErrUserExists = errors.New("a user with this email or username already exists")
 )
 
 // CreateUser inserts a new user into the collection
@@ -17,8 +30,13 @@ func (s *Storage) CreateUser(ctx context.Context, req *genprotos.CreateUserReues
 	var user models.User
 	user.Id = primitive.NewObjectID()
 	user.FromProto(req)
-	user.Password = s.passwordHasher.HashPassword(user.Password)
-	s.logger.Println(user.Password, "-------------------------------------")
+	hash, err := s.passwordHasher.HashPassword(user.Password)
+	if err != nil {
+		s.logger.Printf("Failed to hash password: %s\n", err.Error())
+		return nil, err
+	}
+	user.Password = hash
+	user.PendingVerification = true
 
 	select {
 	case <-ctx.Done():
@@ -27,13 +45,20 @@ func (s *Storage) CreateUser(ctx context.Context, req *genprotos.CreateUserReues
 	default:
 	}
 
-	_, err := s.database.UsersCollection.InsertOne(ctx, user)
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := s.database.UsersCollection.InsertOne(sessCtx, user); mongo.IsDuplicateKeyError(err) {
+			return ErrUserExists
+		} else if err != nil {
+			s.logger.Printf("Failed to insert user: %s\n", err.Error())
+			return fmt.Errorf("failed to insert user: %s", err.Error())
+		}
+		return s.writeEvent(sessCtx, events.UserRegistered, user.ToPublicProtoUser())
+	})
 	if err != nil {
-		s.logger.Printf("Failed to insert user: %s\n", err.Error())
-		return nil, fmt.Errorf("failed to insert user: %s", err.Error())
+		return nil, err
 	}
 	s.logger.Printf("--------------------- USER HAS BEEN CREATED WITH EMAil %s -----------------------\n", user.Email)
-	return user.ToProtoUser(), nil
+	return user.ToPublicProtoUser(), nil
 }
 
 // UpdateUser updates an existing user
@@ -50,28 +75,76 @@ func (s *Storage) UpdateUser(ctx context.Context, req *genprotos.UpdateUserReuqe
 		return nil, fmt.Errorf("failed to get user: %s", err.Error())
 	}
 
-	user.Update(req)
-	objectId, err := primitive.ObjectIDFromHex(req.User.UserId)
-	if err != nil {
-		s.logger.Println("failed to convert the object ID:", err.Error())
-		return nil, fmt.Errorf("invalid ObjectID: %s", err.Error())
+	set := profileChanges(user, req)
+	if len(set) == 0 {
+		return user.ToPublicProtoUser(), nil
 	}
-	filter := bson.M{"_id": objectId, "deleted": false}
-
+	filter := bson.M{"_id": user.Id, "deleted": false}
 	update := bson.M{
-		"$set": user,
+		"$set": set,
 	}
 
-	updateResult, err := s.database.UsersCollection.UpdateOne(ctx, filter, update)
+	var updated models.User
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		err := s.database.UsersCollection.FindOneAndUpdate(sessCtx, filter, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&updated)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrUserExists
+		} else if err == mongo.ErrNoDocuments {
+			s.logger.Println("no rows updated")
+			return fmt.Errorf("no user found to update with ID: %s", req.User.UserId)
+		} else if err != nil {
+			s.logger.Printf("Failed to update document: %s", err.Error())
+			return fmt.Errorf("failed to update user: %s", err.Error())
+		}
+		return s.writeEvent(sessCtx, events.UserUpdated, updated.ToPublicProtoUser())
+	})
 	if err != nil {
-		s.logger.Printf("Failed to update document: %s", err.Error())
-		return nil, fmt.Errorf("failed to update user: %s", err.Error())
+		return nil, err
+	}
+	return updated.ToPublicProtoUser(), nil
+}
+
+// profileChanges lists the profile fields an update request changes. Only
+// those are written, so an update cannot put back the password, 2FA state,
+// houses or admin flag it read before another write changed them
+func profileChanges(user *models.User, req *genprotos.UpdateUserReuqest) bson.M {
+	set := bson.M{}
+	if len(req.User.Username) > 0 && req.User.Username != user.Username {
+		set["username"] = req.User.Username
+	}
+	if len(req.User.Email) > 0 && req.User.Email != user.Email {
+		set["email"] = req.User.Email
 	}
-	if updateResult.ModifiedCount == 0 {
-		s.logger.Println("no rows updated")
-		return nil, fmt.Errorf("no user found to update with ID: %s", req.User.UserId)
+	if req.User.Profile != nil {
+		if len(req.User.Profile.Name) > 0 && req.User.Profile.Name != user.Profile.Name {
+			set["profile.name"] = req.User.Profile.Name
+		}
+		if len(req.User.Profile.Address) > 0 && req.User.Profile.Address != user.Profile.Address {
+			set["profile.address"] = req.User.Profile.Address
+		}
 	}
-	return user.ToProtoUser(), nil
+	return set
+}
+
+// GrantAdmin makes the active user with the given email an administrator
+func (s *Storage) GrantAdmin(ctx context.Context, email string) error {
+	return s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		var user models.User
+		err := s.database.UsersCollection.FindOneAndUpdate(sessCtx,
+			bson.M{"email": email, "deleted": false},
+			bson.M{"$set": bson.M{"admin": true}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&user)
+		if err == mongo.ErrNoDocuments {
+			return fmt.Errorf("no user found with email: %s", email)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to grant admin: %s", err.Error())
+		}
+		return s.writeEvent(sessCtx, events.UserUpdated, user.ToPublicProtoUser())
+	})
 }
 
 // getByField finds a user by a specific field
@@ -110,6 +183,14 @@ func (s *Storage) getByField(ctx context.Context, req *models.GetByFieldRequest)
 	return &user, nil
 }
 
+// FindUserById gets a user that has not been deleted by its ID
+func (s *Storage) FindUserById(ctx context.Context, userId string) (*models.User, error) {
+	return s.getByField(ctx, &models.GetByFieldRequest{
+		Field: "_id",
+		Value: userId,
+	})
+}
+
 // GetUserByUsername gets a user by username
 func (s *Storage) GetUserByUsername(ctx context.Context, req *genprotos.GetByFieldRequest) (*genprotos.User, error) {
 	request := models.GetByFieldRequest{
@@ -120,7 +201,7 @@ func (s *Storage) GetUserByUsername(ctx context.Context, req *genprotos.GetByFie
 	if err != nil {
 		return nil, err
 	}
-	return user.ToProtoUser(), nil
+	return user.ToPublicProtoUser(), nil
 }
 
 // GetUserById gets a user by ID
@@ -133,7 +214,7 @@ func (s *Storage) GetUserById(ctx context.Context, req *genprotos.GetByFieldRequ
 	if err != nil {
 		return nil, err
 	}
-	return user.ToProtoUser(), nil
+	return user.ToPublicProtoUser(), nil
 }
 
 // GetUserByEmail gets a user by email
@@ -146,7 +227,7 @@ func (s *Storage) GetUserByEmail(ctx context.Context, req *genprotos.GetByFieldR
 	if err != nil {
 		return nil, err
 	}
-	return user.ToProtoUser(), nil
+	return user.ToPublicProtoUser(), nil
 }
 
 // GetUserByAddress gets users by a specific address
@@ -168,7 +249,7 @@ func (s *Storage) GetUserByAddress(ctx context.Context, req *genprotos.GetUsersB
 			s.logger.Printf("Failed to decode user: %s", err.Error())
 			return nil, fmt.Errorf("failed to decode user: %s", err.Error())
 		}
-		response.Users = append(response.Users, user.ToProtoUser())
+		response.Users = append(response.Users, user.ToPublicProtoUser())
 	}
 
 	if err := cursor.Err(); err != nil {
@@ -179,31 +260,47 @@ func (s *Storage) GetUserByAddress(ctx context.Context, req *genprotos.GetUsersB
 	return &response, nil
 }
 
-// GetAllUsers gets all users with pagination
+// GetAllUsers lists users a page at a time, optionally narrowed down to an
+// address. Pages follow each other through the opaque next_cursor of the
+// response
 func (s *Storage) GetAllUsers(ctx context.Context, req *genprotos.GetAllUsersRequest) (*genprotos.GetAllUsersResponse, error) {
-	skip := (req.Pagination - 1) * req.Limit
+	query, err := pagination.NewQuery(req.Limit, req.Cursor, req.SortBy, req.Descending, "username", "email")
+	if err != nil {
+		return nil, err
+	}
 
-	findOptions := options.Find()
-	findOptions.SetLimit(int64(req.Limit))
-	findOptions.SetSkip(int64(skip))
+	filter := bson.M{"deleted": req.Deleted}
+	if len(req.Address) > 0 {
+		filter["profile.address"] = req.Address
+	}
 
-	filter := bson.M{"deleted": false}
+	total, err := s.database.UsersCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		s.logger.Printf("FAILED TO COUNT USERS: %s", err.Error())
+		return nil, fmt.Errorf("failed to count users: %s", err.Error())
+	}
 
-	cursor, err := s.database.UsersCollection.Find(ctx, filter, findOptions)
+	cursor, err := s.database.UsersCollection.Find(ctx, filter, query.Apply(filter))
 	if err != nil {
 		s.logger.Printf("FAILED TO FIND USERS: %s", err.Error())
 		return nil, fmt.Errorf("failed to find users: %s", err.Error())
 	}
 	defer cursor.Close(ctx)
 
-	var response genprotos.GetAllUsersResponse
+	response := genprotos.GetAllUsersResponse{Total: total}
+	var last models.User
 	for cursor.Next(ctx) {
+		if int64(len(response.Users)) == query.Limit() {
+			response.NextCursor = query.NextCursor(userSortValue(&last, query.SortBy()), last.Id)
+			break
+		}
 		var user models.User
 		if err := cursor.Decode(&user); err != nil {
 			s.logger.Printf("Failed to decode user: %s", err.Error())
 			return nil, fmt.Errorf("failed to decode user: %s", err.Error())
 		}
-		response.Users = append(response.Users, user.ToProtoUser())
+		response.Users = append(response.Users, user.ToPublicProtoUser())
+		last = user
 	}
 
 	if err := cursor.Err(); err != nil {
@@ -214,6 +311,16 @@ func (s *Storage) GetAllUsers(ctx context.Context, req *genprotos.GetAllUsersReq
 	return &response, nil
 }
 
+func userSortValue(user *models.User, field string) string {
+	switch field {
+	case "username":
+		return user.Username
+	case "email":
+		return user.Email
+	}
+	return ""
+}
+
 // DeleteUserById marks a user as deleted by ID
 func (s *Storage) DeleteUserById(ctx context.Context, req *genprotos.GetByFieldRequest) error {
 	objectID, err := primitive.ObjectIDFromHex(req.GetByField)
@@ -236,41 +343,142 @@ func (s *Storage) DeleteUserById(ctx context.Context, req *genprotos.GetByFieldR
 	}
 
 	update := bson.M{
-		"deleted": true,
+		"$set": bson.M{"deleted": true},
 	}
-	_, err = s.database.UsersCollection.UpdateOne(ctx, filter, update)
+	user.Deleted = true
+
+	err = s.withTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		if _, err := s.database.UsersCollection.UpdateOne(sessCtx, filter, update); err != nil {
+			s.logger.Printf("Failed to update user: %s", err.Error())
+			return fmt.Errorf("failed to update user: %s", err.Error())
+		}
+		return s.writeEvent(sessCtx, events.UserDeleted, user.ToPublicProtoUser())
+	})
 	if err != nil {
-		s.logger.Printf("Failed to update user: %s", err.Error())
-		return fmt.Errorf("failed to update user: %s", err.Error())
+		return err
 	}
 
-	user.Deleted = true
-
 	s.logger.Printf("Successfully marked user as deleted with ID: %s\n", req.GetByField)
 	return nil
 }
 
-// LoginUser authenticates a user and returns a token
-func (s *Storage) LoginUser(ctx context.Context, req *genprotos.LoginRequest) (*genprotos.RegisterUserResponse, error) {
-	user, err := s.GetUserByEmail(ctx, &genprotos.GetByFieldRequest{
-		GetByField: req.Email,
+// AuthenticateUser checks a user's credentials and returns the user. An
+// unknown email address and a wrong password both give ErrInvalidCredentials,
+// and take about as long, so the answer does not tell whether an address is
+// registered
+func (s *Storage) AuthenticateUser(ctx context.Context, req *genprotos.LoginRequest) (*models.User, error) {
+	var user models.User
+	err := s.database.UsersCollection.FindOne(ctx, bson.M{"email": req.Email, "deleted": false}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		s.passwordHasher.HashPassword(req.Password)
+		return nil, ErrInvalidCredentials
+	} else if err != nil {
+		s.logger.Printf("Failed to find document: %s", err.Error())
+		return nil, fmt.Errorf("failed to find document: %s", err.Error())
+	}
+	if !s.passwordHasher.CheckPasswordHash(req.Password, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+	if s.passwordHasher.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, &user, req.Password)
+	}
+	if user.PendingVerification {
+		return nil, status.Errorf(codes.PermissionDenied, "email address %s has not been verified", user.Email)
+	}
+	return &user, nil
+}
+
+// MarkEmailVerified records that a user has proven they own their email
+// address
+func (s *Storage) MarkEmailVerified(ctx context.Context, userId string) error {
+	return s.updateAccount(ctx, userId, bson.M{"$unset": bson.M{"pending_verification": ""}})
+}
+
+// SetPassword replaces a user's password. Since it is only reached through a
+// link mailed to the user, it verifies their email address as well
+func (s *Storage) SetPassword(ctx context.Context, userId, password string) error {
+	hash, err := s.passwordHasher.HashPassword(password)
+	if err != nil {
+		s.logger.Printf("Failed to hash password: %s\n", err.Error())
+		return err
+	}
+	return s.updateAccount(ctx, userId, bson.M{
+		"$set":   bson.M{"password": hash},
+		"$unset": bson.M{"pending_verification": ""},
 	})
+}
+
+func (s *Storage) updateAccount(ctx context.Context, userId string, update bson.M) error {
+	filter, err := userFilter(userId)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	s.logger.Println("----------------------- user found -----------------------------")
-	if s.passwordHasher.CheckPasswordHash(req.Password, user.Password) {
-		token, err := s.tokenGenerator.GenerateToken(user.UserId, user.Username)
-		if err != nil {
-			s.logger.Printf("ERROR WHILE GENERATING TOKEN FOR USER %s\n", user.Email)
-			return nil, err
-		}
-		return &genprotos.RegisterUserResponse{
-			User: user,
-			Token: &genprotos.Token{
-				StringToken: token,
-			},
-		}, nil
-	}
-	return nil, fmt.Errorf("mismatch in password")
+	result, err := s.database.UsersCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		s.logger.Printf("Failed to update user %s: %s\n", userId, err.Error())
+		return fmt.Errorf("failed to update user: %s", err.Error())
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("no user found with ID: %s", userId)
+	}
+	return nil
+}
+
+// userFilter matches a user that has not been deleted by its ID
+func userFilter(userId string) (bson.M, error) {
+	objectId, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ObjectID: %s", err.Error())
+	}
+	return bson.M{"_id": objectId, "deleted": false}, nil
+}
+
+// rehashPassword upgrades a legacy or outdated password hash in place after a
+// successful login. A failure is only logged, the old hash keeps working
+func (s *Storage) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hash, err := s.passwordHasher.HashPassword(password)
+	if err != nil {
+		s.logger.Printf("Failed to rehash password of user %s: %s\n", user.Id.Hex(), err.Error())
+		return
+	}
+	filter := bson.M{"_id": user.Id, "password": user.Password}
+	if _, err := s.database.UsersCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"password": hash}}); err != nil {
+		s.logger.Printf("Failed to store rehashed password of user %s: %s\n", user.Id.Hex(), err.Error())
+		return
+	}
+	user.Password = hash
+}
+
+// AddUserHouse records that a user belongs to a house, so the house ends up in
+// the user's next token
+func (s *Storage) AddUserHouse(ctx context.Context, userId, houseId string) error {
+	return s.updateUserHouses(ctx, userId, bson.M{"$addToSet": bson.M{"houses": houseId}})
+}
+
+// RemoveUserHouse drops a house from the houses a user belongs to
+func (s *Storage) RemoveUserHouse(ctx context.Context, userId, houseId string) error {
+	return s.updateUserHouses(ctx, userId, bson.M{"$pull": bson.M{"houses": houseId}})
+}
+
+// DropHouse removes a deleted house from every user that belonged to it
+func (s *Storage) DropHouse(ctx context.Context, houseId string) error {
+	_, err := s.database.UsersCollection.UpdateMany(ctx, bson.M{"houses": houseId}, bson.M{"$pull": bson.M{"houses": houseId}})
+	if err != nil {
+		s.logger.Printf("Failed to drop house %s from its users: %s", houseId, err.Error())
+		return fmt.Errorf("failed to drop house %s from its users: %s", houseId, err.Error())
+	}
+	return nil
+}
+
+func (s *Storage) updateUserHouses(ctx context.Context, userId string, update bson.M) error {
+	objectID, err := primitive.ObjectIDFromHex(userId)
+	if err != nil {
+		s.logger.Printf("Invalid ObjectID: %s\n", userId)
+		return fmt.Errorf("invalid ObjectID: %s", userId)
+	}
+	if _, err := s.database.UsersCollection.UpdateOne(ctx, bson.M{"_id": objectID}, update); err != nil {
+		s.logger.Printf("Failed to update the houses of user %s: %s", userId, err.Error())
+		return fmt.Errorf("failed to update the houses of user %s: %s", userId, err.Error())
+	}
+	return nil
 }
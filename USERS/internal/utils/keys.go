@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
+)
+
+type (
+	// KeySet holds the RSA keys tokens are signed with. All of them are
+	// published so that tokens signed with a retired key stay valid until they
+	// expire, while new tokens are signed with the signing key only
+	KeySet struct {
+		signingKeyId string
+		keys         map[string]*rsa.PrivateKey
+	}
+)
+
+// LoadKeySet reads every <kid>.pem file of a directory. When signingKeyId is
+// empty the key whose id sorts last signs, so naming keys by the date they
+// were created rotates them by just adding a file
+func LoadKeySet(dir, signingKeyId string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list signing keys: %s", err.Error())
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no signing keys found in %s", dir)
+	}
+	sort.Strings(paths)
+
+	keySet := KeySet{keys: make(map[string]*rsa.PrivateKey, len(paths))}
+	for _, path := range paths {
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := readPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not load signing key %s: %s", kid, err.Error())
+		}
+		keySet.keys[kid] = key
+		keySet.signingKeyId = kid
+	}
+	if len(signingKeyId) > 0 {
+		if _, ok := keySet.keys[signingKeyId]; !ok {
+			return nil, fmt.Errorf("signing key %s not found in %s", signingKeyId, dir)
+		}
+		keySet.signingKeyId = signingKeyId
+	}
+	return &keySet, nil
+}
+
+// SigningKey returns the id and the key new tokens are signed with
+func (k *KeySet) SigningKey() (string, *rsa.PrivateKey) {
+	return k.signingKeyId, k.keys[k.signingKeyId]
+}
+
+// JWKS returns the public halves of the keys as a JSON Web Key Set
+func (k *KeySet) JWKS() *genprotos.JWKS {
+	kids := make([]string, 0, len(k.keys))
+	for kid := range k.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	jwks := genprotos.JWKS{Keys: make([]*genprotos.JsonWebKey, 0, len(kids))}
+	for _, kid := range kids {
+		public := k.keys[kid].PublicKey
+		jwks.Keys = append(jwks.Keys, &genprotos.JsonWebKey{
+			Kty: "RSA",
+			Kid: kid,
+			Alg: "RS256",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		})
+	}
+	return &jwks
+}
+
+func readPrivateKey(path string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA private key")
+	}
+	return key, nil
+}
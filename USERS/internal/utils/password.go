@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idPrefix = "$argon2id$"
+
+type (
+	// PasswordHasher hashes passwords with argon2id. Hashes are stored in the
+	// PHC string format, $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>,
+	// so the parameters a hash was made with travel with it
+	PasswordHasher struct {
+		memory  uint32
+		time    uint32
+		threads uint8
+		saltLen uint32
+		keyLen  uint32
+	}
+
+	argon2idHash struct {
+		memory  uint32
+		time    uint32
+		threads uint8
+		salt    []byte
+		key     []byte
+	}
+)
+
+// NewPasswordHasher creates a new PasswordHasher with the parameters OWASP
+// recommends for argon2id
+func NewPasswordHasher() *PasswordHasher {
+	return &PasswordHasher{
+		memory:  64 * 1024,
+		time:    3,
+		threads: 2,
+		saltLen: 16,
+		keyLen:  32,
+	}
+}
+
+// HashPassword hashes a password with argon2id and a random per-password salt
+func (p *PasswordHasher) HashPassword(password string) (string, error) {
+	salt := make([]byte, p.saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("could not generate salt: %s", err.Error())
+	}
+	key := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, p.keyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version, p.memory, p.time, p.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// CheckPasswordHash compares a plain password with its hash. Besides argon2id
+// hashes it still accepts the unsalted SHA-256 hex digests older accounts were
+// stored with, so those can be upgraded on login
+func (p *PasswordHasher) CheckPasswordHash(password, hash string) bool {
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return checkLegacyHash(password, hash)
+	}
+	decoded, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+	key := argon2.IDKey([]byte(password), decoded.salt, decoded.time, decoded.memory, decoded.threads, uint32(len(decoded.key)))
+	return subtle.ConstantTimeCompare(key, decoded.key) == 1
+}
+
+// NeedsRehash reports whether a stored hash is a legacy one or was made with
+// parameters other than the current ones
+func (p *PasswordHasher) NeedsRehash(hash string) bool {
+	if !strings.HasPrefix(hash, argon2idPrefix) {
+		return true
+	}
+	decoded, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+	return decoded.memory != p.memory ||
+		decoded.time != p.time ||
+		decoded.threads != p.threads ||
+		uint32(len(decoded.salt)) != p.saltLen ||
+		uint32(len(decoded.key)) != p.keyLen
+}
+
+func checkLegacyHash(password, hash string) bool {
+	sum := sha256.Sum256([]byte(password))
+	return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) == 1
+}
+
+func decodeArgon2id(hash string) (*argon2idHash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("malformed argon2id hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, fmt.Errorf("malformed argon2id version: %s", err.Error())
+	}
+	if version != argon2.Version {
+		return nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+	var decoded argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &decoded.memory, &decoded.time, &decoded.threads); err != nil {
+		return nil, fmt.Errorf("malformed argon2id parameters: %s", err.Error())
+	}
+	var err error
+	if decoded.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, fmt.Errorf("malformed argon2id salt: %s", err.Error())
+	}
+	if decoded.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return nil, fmt.Errorf("malformed argon2id key: %s", err.Error())
+	}
+	return &decoded, nil
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestHashPasswordRoundTrip(t *testing.T) {
+	hasher := NewPasswordHasher()
+	hash, err := hasher.HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Errorf("hash = %s, want an argon2id one with the current parameters", hash)
+	}
+	if !hasher.CheckPasswordHash("correct horse battery staple", hash) {
+		t.Error("the password does not match its own hash")
+	}
+	if hasher.CheckPasswordHash("correct horse battery stapler", hash) {
+		t.Error("a wrong password matches")
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Error("a fresh hash needs a rehash")
+	}
+}
+
+func TestHashPasswordSaltsEveryHash(t *testing.T) {
+	hasher := NewPasswordHasher()
+	first, _ := hasher.HashPassword("secret")
+	second, _ := hasher.HashPassword("secret")
+	if first == second {
+		t.Error("the same password hashed twice gives the same hash")
+	}
+}
+
+// TestNeedsRehashOnOtherParameters checks that hashes made with older
+// parameters still verify but are flagged for an upgrade
+func TestNeedsRehashOnOtherParameters(t *testing.T) {
+	current := NewPasswordHasher()
+	older := []*PasswordHasher{
+		{memory: 32 * 1024, time: 3, threads: 2, saltLen: 16, keyLen: 32},
+		{memory: 64 * 1024, time: 1, threads: 2, saltLen: 16, keyLen: 32},
+		{memory: 64 * 1024, time: 3, threads: 1, saltLen: 16, keyLen: 32},
+		{memory: 64 * 1024, time: 3, threads: 2, saltLen: 8, keyLen: 32},
+		{memory: 64 * 1024, time: 3, threads: 2, saltLen: 16, keyLen: 16},
+	}
+	for _, hasher := range older {
+		hash, err := hasher.HashPassword("secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !current.CheckPasswordHash("secret", hash) {
+			t.Errorf("%s does not verify with the current hasher", hash)
+		}
+		if !current.NeedsRehash(hash) {
+			t.Errorf("%s does not need a rehash", hash)
+		}
+	}
+}
+
+// TestLegacyHashIsUpgraded follows a login on an account stored with an
+// unsalted SHA-256 digest: it is accepted, flagged and rehashed with argon2id
+func TestLegacyHashIsUpgraded(t *testing.T) {
+	hasher := NewPasswordHasher()
+	sum := sha256.Sum256([]byte("secret"))
+	legacy := hex.EncodeToString(sum[:])
+
+	if !hasher.CheckPasswordHash("secret", legacy) {
+		t.Fatal("the legacy hash does not verify")
+	}
+	if hasher.CheckPasswordHash("Secret", legacy) {
+		t.Error("a wrong password matches the legacy hash")
+	}
+	if !hasher.NeedsRehash(legacy) {
+		t.Fatal("the legacy hash does not need a rehash")
+	}
+
+	upgraded, err := hasher.HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasher.CheckPasswordHash("secret", upgraded) || hasher.NeedsRehash(upgraded) {
+		t.Errorf("the upgraded hash %s is not a current argon2id one", upgraded)
+	}
+}
+
+func TestCheckPasswordHashRejectsMalformedHashes(t *testing.T) {
+	hasher := NewPasswordHasher()
+	hash, _ := hasher.HashPassword("secret")
+	parts := strings.Split(hash, "$")
+
+	malformed := map[string]string{
+		"missing key":   strings.Join(parts[:5], "$"),
+		"other version": strings.Replace(hash, "v=19", "v=16", 1),
+		"bad params":    strings.Replace(hash, parts[3], "m=x,t=3,p=2", 1),
+		"bad salt":      strings.Replace(hash, parts[4], "!!!", 1),
+		"bad key":       strings.Replace(hash, parts[5], "!!!", 1),
+		"empty":         "",
+	}
+	for name, hash := range malformed {
+		if hasher.CheckPasswordHash("secret", hash) {
+			t.Errorf("%s: a malformed hash matches", name)
+		}
+		if !hasher.NeedsRehash(hash) {
+			t.Errorf("%s: a malformed hash does not need a rehash", name)
+		}
+	}
+}
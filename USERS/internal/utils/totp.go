@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTP parameters as RFC 6238 and authenticator apps default to them
+const (
+	totpIssuer     = "Smart House"
+	totpPeriod     = 30
+	totpDigits     = 6
+	totpSkew       = 1
+	recoveryCodes  = 10
+	recoveryLength = 10
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret generates a base32 encoded 160-bit TOTP secret
+func NewTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("could not create TOTP secret: %s", err.Error())
+	}
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// TOTPURI builds the otpauth:// URI authenticator apps enroll from, usually
+// shown as a QR code
+func TOTPURI(account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(totpIssuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// ValidateTOTP checks a code against a secret, allowing one period of clock
+// skew either way. It returns the time step the code belongs to, so callers
+// can refuse a code that has been used before
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%uint32(math.Pow10(totpDigits)))
+}
+
+// NewRecoveryCodes generates the single-use codes that stand in for a TOTP
+// code when the authenticator is lost
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, recoveryCodes)
+	for i := 0; i < recoveryCodes; i++ {
+		raw := make([]byte, recoveryLength)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("could not create recovery codes: %s", err.Error())
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(raw))[:recoveryLength]
+		codes = append(codes, code[:recoveryLength/2]+"-"+code[recoveryLength/2:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode is how recovery codes are stored. Dashes and case are
+// ignored, since users type them in by hand
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
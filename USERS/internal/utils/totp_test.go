@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", base32 encoded
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestTOTPCodeVectors checks the SHA-1 test vectors of RFC 6238, appendix B,
+// cut down to the last six of their eight digits
+func TestTOTPCodeVectors(t *testing.T) {
+	key, err := base32NoPadding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, vector := range vectors {
+		if code := totpCode(key, vector.unix/totpPeriod); code != vector.code {
+			t.Errorf("code at %d = %s, want %s", vector.unix, code, vector.code)
+		}
+		step, ok := ValidateTOTP(rfc6238Secret, vector.code, time.Unix(vector.unix, 0))
+		if !ok || step != vector.unix/totpPeriod {
+			t.Errorf("ValidateTOTP at %d = %d, %t, want %d, true", vector.unix, step, ok, vector.unix/totpPeriod)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	key, _ := base32NoPadding.DecodeString(rfc6238Secret)
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		step, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current+offset), now)
+		if !ok || step != current+offset {
+			t.Errorf("code %d steps away = %d, %t, want %d, true", offset, step, ok, current+offset)
+		}
+	}
+	for _, offset := range []int64{-totpSkew - 1, totpSkew + 1} {
+		if _, ok := ValidateTOTP(rfc6238Secret, totpCode(key, current+offset), now); ok {
+			t.Errorf("code %d steps away was accepted", offset)
+		}
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	if _, ok := ValidateTOTP(rfc6238Secret, "28708", now); ok {
+		t.Error("a five digit code was accepted")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, "94287082", now); ok {
+		t.Error("an eight digit code was accepted")
+	}
+	if _, ok := ValidateTOTP("not base32!", "287082", now); ok {
+		t.Error("a code was accepted for a malformed secret")
+	}
+	if _, ok := ValidateTOTP(strings.ToLower(rfc6238Secret), "287082", now); !ok {
+		t.Error("a lowercase secret was refused")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := base32NoPadding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret has %d bytes, want 20", len(key))
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(TOTPURI("ann@example.com", rfc6238Secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" {
+		t.Errorf("uri = %s, want an otpauth://totp/ one", uri)
+	}
+	query := uri.Query()
+	want := map[string]string{
+		"secret":    rfc6238Secret,
+		"issuer":    totpIssuer,
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for name, value := range want {
+		if query.Get(name) != value {
+			t.Errorf("%s = %q, want %q", name, query.Get(name), value)
+		}
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodes {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodes)
+	}
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not like abcde-fghij", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	hash := HashRecoveryCode("abcde-fghij")
+	for _, typed := range []string{"ABCDE-FGHIJ", "abcdefghij", " abcde-fghij\n"} {
+		if HashRecoveryCode(typed) != hash {
+			t.Errorf("%q does not hash like abcde-fghij", typed)
+		}
+	}
+	if HashRecoveryCode("abcde-fghik") == hash {
+		t.Error("different codes hash the same")
+	}
+}
@@ -1,59 +1,100 @@
 package utils
 
 import (
-	"crypto/sha256"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	genprotos "github.com/ruziba3vich/users/genprotos/users_submodule/protos"
 	"github.com/ruziba3vich/users/internal/config"
 )
 
 type (
 	TokenGenerator struct {
-		secretKey string
+		keys            *KeySet
+		accessTokenTTL  time.Duration
+		refreshTokenTTL time.Duration
 	}
-	PasswordHasher struct{}
 )
 
-// NewTokenGenerator creates a new TokenGenerator
-func NewTokenGenerator(cfg *config.Config) *TokenGenerator {
-	return &TokenGenerator{
-		secretKey: cfg.GetSecretKey(),
+// NewTokenGenerator creates a new TokenGenerator signing with the keys of
+// the configured keys directory
+func NewTokenGenerator(cfg *config.Config) (*TokenGenerator, error) {
+	keys, err := LoadKeySet(cfg.TokenConfig.KeysDir, cfg.TokenConfig.SigningKeyId)
+	if err != nil {
+		return nil, err
 	}
+	return &TokenGenerator{
+		keys:            keys,
+		accessTokenTTL:  cfg.TokenConfig.AccessTokenTTL,
+		refreshTokenTTL: cfg.TokenConfig.RefreshTokenTTL,
+	}, nil
 }
 
-// GenerateToken generates a JWT token for a user
-func (t *TokenGenerator) GenerateToken(userId string, username string) (string, error) {
+// GenerateToken generates a short-lived RS256 access token for a user, carrying
+// the session it belongs to and the houses the user belongs to. The kid header
+// names the key it was signed with. It returns the token together with its
+// expiry
+func (t *TokenGenerator) GenerateToken(userId, username, sessionId string, houses []string) (string, time.Time, error) {
+	if houses == nil {
+		houses = []string{}
+	}
+	expiresAt := time.Now().Add(t.accessTokenTTL)
 	claims := jwt.MapClaims{
 		"sub":      userId,
 		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+		"sid":      sessionId,
+		"houses":   houses,
+		"exp":      expiresAt.Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(t.secretKey))
+	kid, key := t.keys.SigningKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	tokenString, err := token.SignedString(key)
 	if err != nil {
-		return "", fmt.Errorf("could not create token: %s", err.Error())
+		return "", time.Time{}, fmt.Errorf("could not create token: %s", err.Error())
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
+}
+
+// NewRefreshToken generates an opaque refresh token
+func (t *TokenGenerator) NewRefreshToken() (string, error) {
+	return randomToken()
 }
 
-// HashPassword hashes a password using SHA-256
-func (p *PasswordHasher) HashPassword(password string) string {
-	hasher := sha256.New()
-	hasher.Write([]byte(password))
-	return hex.EncodeToString(hasher.Sum(nil))
+// NewOneTimeToken generates an opaque token for a link mailed to a user
+func (t *TokenGenerator) NewOneTimeToken() (string, error) {
+	return randomToken()
 }
 
-// CheckPasswordHash compares a plain password with its hash
-func (p *PasswordHasher) CheckPasswordHash(password, hash string) bool {
-	return p.HashPassword(password) == hash
+// NewSessionId generates the id of a new login session
+func (t *TokenGenerator) NewSessionId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("could not create session id: %s", err.Error())
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// JWKS returns the keys tokens are verified with
+func (t *TokenGenerator) JWKS() *genprotos.JWKS {
+	return t.keys.JWKS()
 }
 
-// NewPasswordHasher creates a new PasswordHasher
-func NewPasswordHasher() *PasswordHasher {
-	return &PasswordHasher{}
+// RefreshTokenTTL is how long a session lives without being refreshed
+func (t *TokenGenerator) RefreshTokenTTL() time.Duration {
+	return t.refreshTokenTTL
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("could not create token: %s", err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
 }